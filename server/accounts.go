@@ -0,0 +1,92 @@
+package main
+
+import (
+	"strings"
+)
+
+const defaultAccountName = "default"
+
+// digitalSambaAccount is one DigitalSamba subscription the plugin talks to.
+type digitalSambaAccount struct {
+	client       *DigitalSambaClient
+	dashboardURL string
+	teamName     string
+	customDomain string
+}
+
+// TeamAccount is the configuration of a DigitalSamba account used by a
+// single Mattermost team instead of the default account.
+type TeamAccount struct {
+	APIKey       string `json:"api_key"`
+	DashboardURL string `json:"dashboard_url"`
+	TeamName     string `json:"team_name"`
+	CustomDomain string `json:"custom_domain"`
+}
+
+func newDigitalSambaAccount(dashboardURL, apiKey, teamName, customDomain string) *digitalSambaAccount {
+	return &digitalSambaAccount{
+		client:       NewDigitalSambaClient(dashboardURL, apiKey),
+		dashboardURL: dashboardURL,
+		teamName:     strings.TrimSpace(teamName),
+		customDomain: normalizeCustomDomain(customDomain),
+	}
+}
+
+// initAccounts rebuilds the account pool from the configuration. Team
+// accounts without their own dashboard URL use the default one.
+func (p *Plugin) initAccounts(config *configuration) error {
+	teamAccounts, err := config.GetTeamAccounts()
+	if err != nil {
+		return err
+	}
+
+	defaultAccount := newDigitalSambaAccount(config.GetDashboardURL(), config.DigitalSambaAPIKey, config.DigitalSambaTeamName, config.DigitalSambaCustomDomain)
+
+	accounts := make(map[string]*digitalSambaAccount, len(teamAccounts))
+	for teamID, teamAccount := range teamAccounts {
+		dashboardURL := normalizeDashboardURL(teamAccount.DashboardURL)
+		if dashboardURL == "" {
+			dashboardURL = defaultAccount.dashboardURL
+		}
+		accounts[teamID] = newDigitalSambaAccount(dashboardURL, teamAccount.APIKey, teamAccount.TeamName, teamAccount.CustomDomain)
+	}
+
+	p.accountsLock.Lock()
+	defer p.accountsLock.Unlock()
+
+	p.defaultAccount = defaultAccount
+	p.teamAccounts = accounts
+
+	return nil
+}
+
+// getAccount returns the DigitalSamba account of a Mattermost team, falling
+// back to the default account for teams without one and for DM and GM
+// channels, which have no team.
+func (p *Plugin) getAccount(teamID string) *digitalSambaAccount {
+	p.accountsLock.RLock()
+	defer p.accountsLock.RUnlock()
+
+	if account, ok := p.teamAccounts[teamID]; ok && teamID != "" {
+		return account
+	}
+
+	return p.defaultAccount
+}
+
+// listAccounts returns every configured account keyed by "default" or the
+// team ID it belongs to.
+func (p *Plugin) listAccounts() map[string]*digitalSambaAccount {
+	p.accountsLock.RLock()
+	defer p.accountsLock.RUnlock()
+
+	accounts := make(map[string]*digitalSambaAccount, len(p.teamAccounts)+1)
+	if p.defaultAccount != nil {
+		accounts[defaultAccountName] = p.defaultAccount
+	}
+	for teamID, account := range p.teamAccounts {
+		accounts[teamID] = account
+	}
+
+	return accounts
+}
@@ -2,10 +2,33 @@ package main
 
 import (
 	"encoding/json"
-	"fmt"
+	"errors"
 	"net/http"
 
+	"github.com/gorilla/mux"
 	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin"
+)
+
+// maxRequestBodySize limits the size of request bodies accepted by the API.
+const maxRequestBodySize = 1 << 20
+
+// Error codes returned in APIError.Code.
+const (
+	errorCodeBadRequest       = "bad_request"
+	errorCodeUnauthorized     = "unauthorized"
+	errorCodeForbidden        = "forbidden"
+	errorCodeNotFound         = "not_found"
+	errorCodeMethodNotAllowed = "method_not_allowed"
+	errorCodeRequestTooLarge  = "request_too_large"
+	errorCodeInternal         = "internal_error"
+
+	// errorCodeJoinRequestRequired means the meeting is private and the
+	// user must be admitted through a join request first.
+	errorCodeJoinRequestRequired = "join_request_required"
+	// errorCodeRecordingConsentRequired asks the user to consent to
+	// recording before they get a token.
+	errorCodeRecordingConsentRequired = "recording_consent_required"
 )
 
 type StartMeetingRequest struct {
@@ -14,180 +37,346 @@ type StartMeetingRequest struct {
 	MeetingTopic string `json:"meeting_topic"`
 	Personal     bool   `json:"personal"`
 	RootID       string `json:"root_id"`
+
+	// Passcode protects the guest join page of the meeting. It is never
+	// stored in clear.
+	Passcode string `json:"passcode,omitempty"`
 }
 
 type TokenRequest struct {
 	RoomID string `json:"room_id"`
 }
 
+// APIError is the body of every error response.
+type APIError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (p *Plugin) initRouter() *mux.Router {
+	router := mux.NewRouter()
+	router.NotFoundHandler = http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		writeError(w, http.StatusNotFound, errorCodeNotFound, "Not found")
+	})
+	router.MethodNotAllowedHandler = http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		writeError(w, http.StatusMethodNotAllowed, errorCodeMethodNotAllowed, "Method not allowed")
+	})
+	router.Use(limitRequestBody)
+	router.HandleFunc("/api/v1/openapi.json", p.handleOpenAPISpec).Methods(http.MethodGet)
+	p.initInterPluginRouter(router)
+	router.HandleFunc("/api/v1/webhooks/digitalsamba", p.handleDigitalSambaWebhook).Methods(http.MethodPost)
+
+	// The guest join page is public, the passcode stands in for a login
+	router.HandleFunc("/join/{id}", p.handleGuestJoinPage).Methods(http.MethodGet)
+	router.HandleFunc("/join/{id}", p.handleGuestJoin).Methods(http.MethodPost)
+
+	apiRouter := router.PathPrefix("/api/v1").Subrouter()
+	apiRouter.Use(p.requireUser)
+
+	apiRouter.HandleFunc("/meetings", p.handleStartMeeting).Methods(http.MethodPost)
+	apiRouter.HandleFunc("/meetings/{id}", p.handleGetMeeting).Methods(http.MethodGet)
+	apiRouter.HandleFunc("/meetings/{id}/end", p.handleEndMeeting).Methods(http.MethodPost)
+	apiRouter.HandleFunc("/meetings/{id}/participants", p.handleListParticipants).Methods(http.MethodGet)
+	apiRouter.HandleFunc("/meetings/{id}/invite", p.handleCreateInvite).Methods(http.MethodPost)
+	apiRouter.HandleFunc("/meetings/{id}/decline", p.handleDeclineCall).Methods(http.MethodPost)
+	apiRouter.HandleFunc("/meetings/{id}/join", p.handleJoinMeeting).Methods(http.MethodGet)
+	apiRouter.HandleFunc("/meetings/{id}/access", p.handleGetMeetingAccess).Methods(http.MethodGet)
+	apiRouter.HandleFunc("/meetings/{id}/join-requests", p.handleCreateJoinRequest).Methods(http.MethodPost)
+	apiRouter.HandleFunc("/meetings/{id}/recording/start", p.handleStartRecording).Methods(http.MethodPost)
+	apiRouter.HandleFunc("/meetings/{id}/recording/stop", p.handleStopRecording).Methods(http.MethodPost)
+	apiRouter.HandleFunc("/meetings/{id}/consent", p.handleRecordingConsent).Methods(http.MethodPost)
+	apiRouter.HandleFunc("/token", p.handleGetToken).Methods(http.MethodPost)
+	apiRouter.HandleFunc("/user-config", p.handleGetUserConfig).Methods(http.MethodGet)
+	apiRouter.HandleFunc("/user-config", p.handleUpdateUserConfig).Methods(http.MethodPost)
+	apiRouter.HandleFunc("/actions/start-meeting", p.handleStartMeetingAction).Methods(http.MethodPost)
+	apiRouter.HandleFunc("/actions/join-request", p.handleJoinRequestAction).Methods(http.MethodPost)
+	apiRouter.HandleFunc("/actions/recording", p.handleRecordingAction).Methods(http.MethodPost)
+
+	// Deprecated: use GET /user-config
+	apiRouter.HandleFunc("/config", p.handleGetUserConfig).Methods(http.MethodGet)
+
+	adminRouter := apiRouter.PathPrefix("/admin").Subrouter()
+	adminRouter.Use(p.requireSystemAdmin)
+	adminRouter.HandleFunc("/connection-status", p.handleConnectionStatus).Methods(http.MethodGet)
+	adminRouter.HandleFunc("/webhooks/dead-letters", p.handleWebhookDeadLetters).Methods(http.MethodGet)
+	adminRouter.HandleFunc("/audit", p.handleAuditExport).Methods(http.MethodGet)
+
+	return router
+}
+
+func (p *Plugin) ServeHTTP(c *plugin.Context, w http.ResponseWriter, r *http.Request) {
+	p.router.ServeHTTP(w, r)
+}
+
+// requireUser rejects requests that do not come from a logged-in Mattermost user.
+func (p *Plugin) requireUser(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Mattermost-User-Id") == "" {
+			writeError(w, http.StatusUnauthorized, errorCodeUnauthorized, "Not authenticated")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (p *Plugin) requireSystemAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !p.API.HasPermissionTo(r.Header.Get("Mattermost-User-Id"), model.PermissionManageSystem) {
+			writeError(w, http.StatusForbidden, errorCodeForbidden, "Forbidden")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func limitRequestBody(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, maxRequestBodySize)
+		next.ServeHTTP(w, r)
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	writeJSON(w, status, &APIError{Code: code, Message: message})
+}
+
+// writeInternalError logs the details of an error and returns a generic
+// message, so internal details never reach the client.
+func (p *Plugin) writeInternalError(w http.ResponseWriter, message string, err error) {
+	p.API.LogError(message, "error", err.Error())
+	writeError(w, http.StatusInternalServerError, errorCodeInternal, message)
+}
+
+// decodeJSON decodes the request body and writes the error response itself
+// when the body is too large or malformed.
+func decodeJSON(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			writeError(w, http.StatusRequestEntityTooLarge, errorCodeRequestTooLarge, "Request body too large")
+			return false
+		}
+		writeError(w, http.StatusBadRequest, errorCodeBadRequest, "Invalid request body")
+		return false
+	}
+	return true
+}
+
 func (p *Plugin) handleStartMeeting(w http.ResponseWriter, r *http.Request) {
 	userID := r.Header.Get("Mattermost-User-Id")
-	if userID == "" {
-		http.Error(w, "Not authenticated", http.StatusUnauthorized)
+
+	var req StartMeetingRequest
+	if !decodeJSON(w, r, &req) {
 		return
 	}
 
-	var req StartMeetingRequest
-	
-	// Handle both POST body and PostActionIntegrationRequest
-	if r.Method == http.MethodPost {
-		var actionReq model.PostActionIntegrationRequest
-		if err := json.NewDecoder(r.Body).Decode(&actionReq); err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+	meetingInfo, status, err := p.startMeetingForUser(userID, &req)
+	if err != nil {
+		if status == http.StatusInternalServerError {
+			p.writeInternalError(w, "Failed to start meeting", err)
 			return
 		}
-		
-		// Extract data from context
-		if context, ok := actionReq.Context["context"].(map[string]interface{}); ok {
-			req.ChannelID = actionReq.ChannelId
-			req.RootID = ""
-			if meetingID, ok := context["meeting_id"].(string); ok {
-				req.MeetingID = meetingID
-			}
-			if meetingTopic, ok := context["meeting_topic"].(string); ok {
-				req.MeetingTopic = meetingTopic
-			}
-			if personal, ok := context["personal"].(bool); ok {
-				req.Personal = personal
-			}
-		}
-		
-		// Delete the ephemeral post
-		if actionReq.PostId != "" {
-			p.deleteEphemeralPost(userID, actionReq.PostId)
-		}
-	} else {
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
-			return
+		writeError(w, status, codeForStatus(status), err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, meetingInfo)
+}
+
+// handleStartMeetingAction handles the buttons of the "ask" meeting type prompt.
+func (p *Plugin) handleStartMeetingAction(w http.ResponseWriter, r *http.Request) {
+	userID := r.Header.Get("Mattermost-User-Id")
+
+	var actionReq model.PostActionIntegrationRequest
+	if !decodeJSON(w, r, &actionReq) {
+		return
+	}
+
+	req := StartMeetingRequest{ChannelID: actionReq.ChannelId}
+	if meetingID, ok := actionReq.Context["meeting_id"].(string); ok {
+		req.MeetingID = meetingID
+	}
+	if meetingTopic, ok := actionReq.Context["meeting_topic"].(string); ok {
+		req.MeetingTopic = meetingTopic
+	}
+	if personal, ok := actionReq.Context["personal"].(bool); ok {
+		req.Personal = personal
+	}
+	if rootID, ok := actionReq.Context["root_id"].(string); ok {
+		req.RootID = rootID
+	}
+
+	// Delete the ephemeral post
+	if actionReq.PostId != "" {
+		p.deleteEphemeralPost(userID, actionReq.PostId)
+	}
+
+	if _, _, err := p.startMeetingForUser(userID, &req); err != nil {
+		p.API.LogError("Failed to start meeting", "error", err.Error())
+		writeJSON(w, http.StatusOK, &model.PostActionIntegrationResponse{
+			EphemeralText: "Failed to start meeting",
+		})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, &model.PostActionIntegrationResponse{})
+}
+
+// startMeetingForUser checks that the user may post in the channel and starts
+// the meeting. The returned status is meant for the HTTP response on error.
+func (p *Plugin) startMeetingForUser(userID string, req *StartMeetingRequest) (*MeetingInfo, int, error) {
+	if req.ChannelID == "" {
+		return nil, http.StatusBadRequest, errors.New("channel_id is required")
+	}
+
+	if !p.API.HasPermissionToChannel(userID, req.ChannelID, model.PermissionCreatePost) {
+		return nil, http.StatusForbidden, errors.New("you cannot start meetings in this channel")
+	}
+
+	if req.Passcode != "" {
+		if err := validatePasscode(req.Passcode); err != nil {
+			return nil, http.StatusBadRequest, err
 		}
 	}
 
 	user, appErr := p.API.GetUser(userID)
 	if appErr != nil {
-		http.Error(w, "Failed to get user", http.StatusInternalServerError)
-		return
+		return nil, http.StatusInternalServerError, appErr
 	}
 
 	channel, appErr := p.API.GetChannel(req.ChannelID)
 	if appErr != nil {
-		http.Error(w, "Failed to get channel", http.StatusInternalServerError)
-		return
+		return nil, http.StatusNotFound, errors.New("channel not found")
 	}
 
-	meetingInfo, err := p.startMeeting(user, channel, req.MeetingID, req.MeetingTopic, req.Personal, req.RootID)
+	meetingInfo, err := p.startMeeting(user, channel, req.MeetingID, req.MeetingTopic, req.Personal, req.RootID, req.Passcode)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to start meeting: %v", err), http.StatusInternalServerError)
-		return
+		return nil, http.StatusInternalServerError, err
 	}
 
-	resp := meetingInfo
-	
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(resp)
+	return meetingInfo, http.StatusOK, nil
 }
 
-func (p *Plugin) handleConfig(w http.ResponseWriter, r *http.Request) {
+func (p *Plugin) handleGetUserConfig(w http.ResponseWriter, r *http.Request) {
 	userID := r.Header.Get("Mattermost-User-Id")
-	if userID == "" {
-		http.Error(w, "Not authenticated", http.StatusUnauthorized)
-		return
-	}
 
 	config, err := p.getUserConfig(userID)
 	if err != nil {
-		http.Error(w, "Failed to get user config", http.StatusInternalServerError)
+		p.writeInternalError(w, "Failed to get user config", err)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(config)
+	writeJSON(w, http.StatusOK, config)
 }
 
-func (p *Plugin) handleUserConfig(w http.ResponseWriter, r *http.Request) {
+func (p *Plugin) handleUpdateUserConfig(w http.ResponseWriter, r *http.Request) {
 	userID := r.Header.Get("Mattermost-User-Id")
-	if userID == "" {
-		http.Error(w, "Not authenticated", http.StatusUnauthorized)
+
+	var config UserConfig
+	if !decodeJSON(w, r, &config) {
 		return
 	}
 
-	if r.Method == http.MethodGet {
-		config, err := p.getUserConfig(userID)
-		if err != nil {
-			http.Error(w, "Failed to get user config", http.StatusInternalServerError)
-			return
-		}
-
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(config)
+	if config.NamingScheme != "" && !isValidNamingScheme(config.NamingScheme) {
+		writeError(w, http.StatusBadRequest, errorCodeBadRequest, "Invalid naming scheme")
 		return
 	}
 
-	if r.Method == http.MethodPost {
-		var config UserConfig
-		if err := json.NewDecoder(r.Body).Decode(&config); err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
-			return
-		}
-
-		if err := p.setUserConfig(userID, &config); err != nil {
-			http.Error(w, "Failed to save user config", http.StatusInternalServerError)
-			return
-		}
-
-		w.WriteHeader(http.StatusOK)
+	if err := p.setUserConfig(userID, &config); err != nil {
+		p.writeInternalError(w, "Failed to save user config", err)
 		return
 	}
 
-	http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	writeJSON(w, http.StatusOK, &config)
 }
 
 func (p *Plugin) handleGetToken(w http.ResponseWriter, r *http.Request) {
 	userID := r.Header.Get("Mattermost-User-Id")
-	if userID == "" {
-		http.Error(w, "Not authenticated", http.StatusUnauthorized)
-		return
-	}
 
 	var req TokenRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	if req.RoomID == "" {
+		writeError(w, http.StatusBadRequest, errorCodeBadRequest, "room_id is required")
 		return
 	}
 
 	user, appErr := p.API.GetUser(userID)
 	if appErr != nil {
-		http.Error(w, "Failed to get user", http.StatusInternalServerError)
+		p.writeInternalError(w, "Failed to get user", appErr)
 		return
 	}
 
-	// Create moderator token for the user
-	tokenReq := &CreateTokenRequest{
-		RoomID:    req.RoomID,
-		UserID:    user.Id,
-		UserName:  user.GetDisplayName(model.ShowNicknameFullName),
-		UserEmail: user.Email,
-		Role:      "moderator", // All internal users are moderators
+	record, err := p.getMeetingRecord(req.RoomID)
+	if err != nil {
+		p.writeInternalError(w, "Failed to get meeting", err)
+		return
 	}
-	
-	// Add avatar URL if available
-	siteURL := p.API.GetConfig().ServiceSettings.SiteURL
-	if siteURL != nil && *siteURL != "" {
-		tokenReq.AvatarURL = fmt.Sprintf("%s/api/v4/users/%s/image?_=%d", *siteURL, user.Id, user.LastPictureUpdate)
+
+	token, err := p.issueMeetingToken(user, record)
+	var accessErr *meetingAccessError
+	if errors.As(err, &accessErr) {
+		writeError(w, accessErr.status, accessErr.code, accessErr.message)
+		return
 	}
-	
-	token, err := p.digitalSambaClient.CreateToken(tokenReq)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to create token: %v", err), http.StatusInternalServerError)
+		p.writeInternalError(w, "Failed to create token", err)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{
+	writeJSON(w, http.StatusOK, map[string]string{
 		"token": token.Token,
 	})
 }
 
+func (p *Plugin) handleConnectionStatus(w http.ResponseWriter, r *http.Request) {
+	statuses, err := p.getConnectionStatuses()
+	if err != nil {
+		p.writeInternalError(w, "Failed to get connection status", err)
+		return
+	}
+	if statuses == nil {
+		statuses = []*ConnectionStatus{}
+	}
+
+	writeJSON(w, http.StatusOK, statuses)
+}
+
+func (p *Plugin) handleWebhookDeadLetters(w http.ResponseWriter, r *http.Request) {
+	deadLetters, err := p.getWebhookDeadLetters()
+	if err != nil {
+		p.writeInternalError(w, "Failed to get webhook dead letters", err)
+		return
+	}
+	if deadLetters == nil {
+		deadLetters = []*WebhookDeadLetter{}
+	}
+
+	writeJSON(w, http.StatusOK, deadLetters)
+}
+
+func codeForStatus(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return errorCodeBadRequest
+	case http.StatusUnauthorized:
+		return errorCodeUnauthorized
+	case http.StatusForbidden:
+		return errorCodeForbidden
+	case http.StatusNotFound:
+		return errorCodeNotFound
+	default:
+		return errorCodeInternal
+	}
+}
+
 func (p *Plugin) deleteEphemeralPost(userID, postID string) {
 	p.API.DeleteEphemeralPost(userID, postID)
-}
\ No newline at end of file
+}
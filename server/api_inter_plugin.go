@@ -0,0 +1,125 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// InterPluginStartMeetingRequest is the body of POST /inter-plugin/meetings.
+// The calling plugin names the user the meeting is started for.
+type InterPluginStartMeetingRequest struct {
+	UserID       string `json:"user_id"`
+	ChannelID    string `json:"channel_id"`
+	MeetingID    string `json:"meeting_id"`
+	MeetingTopic string `json:"meeting_topic"`
+	RootID       string `json:"root_id"`
+}
+
+// InterPluginEndMeetingRequest is the body of POST /inter-plugin/meetings/{id}/end.
+type InterPluginEndMeetingRequest struct {
+	UserID string `json:"user_id"`
+}
+
+// initInterPluginRouter registers the routes other plugins call through
+// PluginHTTP. Other plugins are trusted to act on behalf of the user they name.
+func (p *Plugin) initInterPluginRouter(router *mux.Router) {
+	interPluginRouter := router.PathPrefix("/api/v1/inter-plugin").Subrouter()
+	interPluginRouter.Use(requirePlugin)
+
+	interPluginRouter.HandleFunc("/meetings", p.handleInterPluginStartMeeting).Methods(http.MethodPost)
+	interPluginRouter.HandleFunc("/meetings/{id}", p.handleInterPluginGetMeeting).Methods(http.MethodGet)
+	interPluginRouter.HandleFunc("/meetings/{id}/end", p.handleInterPluginEndMeeting).Methods(http.MethodPost)
+}
+
+// requirePlugin only lets through requests made by another plugin. The
+// server sets Mattermost-Plugin-ID on inter-plugin requests and strips it
+// from requests coming from outside.
+func requirePlugin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Mattermost-Plugin-ID") == "" {
+			writeError(w, http.StatusForbidden, errorCodeForbidden, "Only other plugins can use this endpoint")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (p *Plugin) handleInterPluginStartMeeting(w http.ResponseWriter, r *http.Request) {
+	var req InterPluginStartMeetingRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	if req.UserID == "" || req.ChannelID == "" {
+		writeError(w, http.StatusBadRequest, errorCodeBadRequest, "user_id and channel_id are required")
+		return
+	}
+
+	user, appErr := p.API.GetUser(req.UserID)
+	if appErr != nil {
+		writeError(w, http.StatusNotFound, errorCodeNotFound, "User not found")
+		return
+	}
+
+	channel, appErr := p.API.GetChannel(req.ChannelID)
+	if appErr != nil {
+		writeError(w, http.StatusNotFound, errorCodeNotFound, "Channel not found")
+		return
+	}
+
+	p.API.LogDebug("Starting meeting for plugin", "plugin_id", r.Header.Get("Mattermost-Plugin-ID"), "channel_id", channel.Id, "user_id", user.Id)
+
+	meetingInfo, err := p.startMeeting(user, channel, req.MeetingID, req.MeetingTopic, false, req.RootID, "")
+	if err != nil {
+		p.writeInternalError(w, "Failed to start meeting", err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, meetingInfo)
+}
+
+func (p *Plugin) handleInterPluginGetMeeting(w http.ResponseWriter, r *http.Request) {
+	record, err := p.getMeetingRecord(mux.Vars(r)["id"])
+	if err != nil {
+		p.writeInternalError(w, "Failed to get meeting", err)
+		return
+	}
+	if record == nil {
+		writeError(w, http.StatusNotFound, errorCodeNotFound, "Meeting not found")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, p.meetingInfoFromRecord(record))
+}
+
+func (p *Plugin) handleInterPluginEndMeeting(w http.ResponseWriter, r *http.Request) {
+	var req InterPluginEndMeetingRequest
+	if r.ContentLength != 0 && !decodeJSON(w, r, &req) {
+		return
+	}
+
+	record, err := p.getMeetingRecord(mux.Vars(r)["id"])
+	if err != nil {
+		p.writeInternalError(w, "Failed to get meeting", err)
+		return
+	}
+	if record == nil {
+		writeError(w, http.StatusNotFound, errorCodeNotFound, "Meeting not found")
+		return
+	}
+
+	if req.UserID != "" {
+		if _, appErr := p.API.GetUser(req.UserID); appErr != nil {
+			writeError(w, http.StatusNotFound, errorCodeNotFound, "User not found")
+			return
+		}
+	}
+
+	if err := p.endMeeting(record, req.UserID); err != nil {
+		p.writeInternalError(w, "Failed to end meeting", err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, p.meetingInfoFromRecord(record))
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	_ "embed"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+//go:embed openapi.json
+var openAPISpec []byte
+
+// InviteRequest is the body of POST /meetings/{id}/invite.
+type InviteRequest struct {
+	Name string `json:"name"`
+}
+
+// InviteResponse is a join link for someone outside the channel.
+type InviteResponse struct {
+	URL       string `json:"url"`
+	ExpiresAt int64  `json:"expires_at,omitempty"`
+}
+
+func (p *Plugin) handleOpenAPISpec(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(openAPISpec)
+}
+
+// getMeetingForRequest loads the meeting named in the path and checks that
+// the user can read its channel. It writes the error response itself.
+func (p *Plugin) getMeetingForRequest(w http.ResponseWriter, r *http.Request) (*MeetingRecord, bool) {
+	userID := r.Header.Get("Mattermost-User-Id")
+	roomID := mux.Vars(r)["id"]
+
+	record, err := p.getMeetingRecord(roomID)
+	if err != nil {
+		p.writeInternalError(w, "Failed to get meeting", err)
+		return nil, false
+	}
+	if record == nil || !p.API.HasPermissionToChannel(userID, record.ChannelID, model.PermissionReadChannel) {
+		writeError(w, http.StatusNotFound, errorCodeNotFound, "Meeting not found")
+		return nil, false
+	}
+
+	return record, true
+}
+
+func (p *Plugin) handleGetMeeting(w http.ResponseWriter, r *http.Request) {
+	record, ok := p.getMeetingForRequest(w, r)
+	if !ok {
+		return
+	}
+
+	writeJSON(w, http.StatusOK, p.meetingInfoFromRecord(record))
+}
+
+func (p *Plugin) handleEndMeeting(w http.ResponseWriter, r *http.Request) {
+	userID := r.Header.Get("Mattermost-User-Id")
+
+	record, ok := p.getMeetingForRequest(w, r)
+	if !ok {
+		return
+	}
+
+	if !p.canManageMeeting(userID, record) {
+		writeError(w, http.StatusForbidden, errorCodeForbidden, "Only the meeting creator or a channel admin can end the meeting")
+		return
+	}
+
+	if err := p.endMeeting(record, userID); err != nil {
+		p.writeInternalError(w, "Failed to end meeting", err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, p.meetingInfoFromRecord(record))
+}
+
+func (p *Plugin) handleListParticipants(w http.ResponseWriter, r *http.Request) {
+	record, ok := p.getMeetingForRequest(w, r)
+	if !ok {
+		return
+	}
+
+	if record.EndedAt != 0 {
+		writeJSON(w, http.StatusOK, []Participant{})
+		return
+	}
+
+	participants, err := p.getAccount(record.TeamID).client.ListLiveParticipants(record.RoomID)
+	if err != nil {
+		p.writeInternalError(w, "Failed to list participants", err)
+		return
+	}
+	if participants == nil {
+		participants = []Participant{}
+	}
+
+	writeJSON(w, http.StatusOK, participants)
+}
+
+func (p *Plugin) handleCreateInvite(w http.ResponseWriter, r *http.Request) {
+	userID := r.Header.Get("Mattermost-User-Id")
+
+	record, ok := p.getMeetingForRequest(w, r)
+	if !ok {
+		return
+	}
+
+	if !p.API.HasPermissionToChannel(userID, record.ChannelID, model.PermissionCreatePost) {
+		writeError(w, http.StatusForbidden, errorCodeForbidden, "You cannot invite people to this meeting")
+		return
+	}
+
+	// Users admitted to a private meeting attend it, they cannot bring
+	// others along
+	if record.Private && !p.canJoinDirectly(userID, record) {
+		writeError(w, http.StatusForbidden, errorCodeForbidden, "You cannot invite people to this meeting")
+		return
+	}
+
+	if record.EndedAt != 0 {
+		writeError(w, http.StatusBadRequest, errorCodeBadRequest, "The meeting has ended")
+		return
+	}
+
+	var req InviteRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		writeError(w, http.StatusBadRequest, errorCodeBadRequest, "name is required")
+		return
+	}
+
+	// A passcode protected meeting issues the token only once the guest
+	// entered the passcode
+	if record.PasscodeHash != "" {
+		p.audit(auditActionInviteCreate, userID, record.ChannelID, record.RoomID, map[string]string{"name": req.Name, "passcode": "true"})
+		writeJSON(w, http.StatusOK, &InviteResponse{
+			URL: p.guestJoinURL(record.RoomID) + "?name=" + url.QueryEscape(req.Name),
+		})
+		return
+	}
+
+	// and a meeting asking for consent once the guest consented on the join
+	// page
+	if record.RequireConsent {
+		invite, err := p.createGuestInvite(record, req.Name, userID)
+		if err != nil {
+			p.writeInternalError(w, "Failed to create invite", err)
+			return
+		}
+		p.audit(auditActionInviteCreate, userID, record.ChannelID, record.RoomID, map[string]string{"name": req.Name, "consent": "true"})
+		writeJSON(w, http.StatusOK, invite)
+		return
+	}
+
+	invite, err := p.createInvite(record, req.Name, false)
+	if err != nil {
+		p.writeInternalError(w, "Failed to create invite", err)
+		return
+	}
+
+	p.audit(auditActionInviteCreate, userID, record.ChannelID, record.RoomID, map[string]string{"name": req.Name})
+
+	writeJSON(w, http.StatusOK, invite)
+}
+
+// createInvite issues an attendee token for a guest and returns a join link
+// carrying it. Guests of a meeting asking for consent must have consented.
+func (p *Plugin) createInvite(record *MeetingRecord, name string, consented bool) (*InviteResponse, error) {
+	if record.RequireConsent && !consented {
+		return nil, errGuestConsentRequired
+	}
+
+	token, err := p.getAccount(record.TeamID).client.CreateToken(&CreateTokenRequest{
+		RoomID:   record.RoomID,
+		UserName: name,
+		Role:     tokenRoleAttendee,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	invite := &InviteResponse{
+		URL: fmt.Sprintf("%s?token=%s", record.MeetingURL, url.QueryEscape(token.Token)),
+	}
+	if token.ExpiresAt != nil {
+		invite.ExpiresAt = token.ExpiresAt.UnixMilli()
+	}
+
+	return invite, nil
+}
+
+// canManageMeeting reports whether the user started the meeting or is an
+// admin of its channel.
+func (p *Plugin) canManageMeeting(userID string, record *MeetingRecord) bool {
+	if record.CreatorID == userID {
+		return true
+	}
+
+	channel, appErr := p.API.GetChannel(record.ChannelID)
+	if appErr != nil {
+		return false
+	}
+
+	return p.canManageChannelSettings(userID, channel)
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// attendee is the attendance of one participant, merged over rejoins.
+type attendee struct {
+	name          string
+	joined        time.Time
+	left          time.Time
+	timeInMeeting time.Duration
+}
+
+// attendanceInterval is one stay of a participant in the session.
+type attendanceInterval struct {
+	joined time.Time
+	left   time.Time
+}
+
+// postAttendanceReport replies in the meeting thread with the times,
+// attendees and peak participant count of a session. Without a session ID
+// the latest session of the room is reported.
+func (p *Plugin) postAttendanceReport(record *MeetingRecord, sessionID string) {
+	client := p.getAccount(record.TeamID).client
+
+	if sessionID == "" {
+		sessions, err := client.ListSessions(record.RoomID)
+		if err != nil {
+			p.API.LogWarn("Failed to list sessions", "room_id", record.RoomID, "error", err.Error())
+			return
+		}
+		if len(sessions) == 0 {
+			return
+		}
+		sessionID = sessions[0].ID
+	}
+
+	if record.ReportedSessionID == sessionID {
+		return
+	}
+
+	participants, err := client.ListSessionParticipants(sessionID)
+	if err != nil {
+		p.API.LogWarn("Failed to list session participants", "room_id", record.RoomID, "session_id", sessionID, "error", err.Error())
+		return
+	}
+
+	// The participant list alone is enough for a report
+	stats, err := client.GetSessionStatistics(sessionID)
+	if err != nil {
+		p.API.LogWarn("Failed to get session statistics", "room_id", record.RoomID, "session_id", sessionID, "error", err.Error())
+		stats = &SessionStatistics{ID: sessionID}
+	}
+
+	if err := p.updateMeetingRecord(record, func(record *MeetingRecord) bool {
+		record.ReportedSessionID = sessionID
+		return true
+	}); err != nil {
+		p.API.LogWarn("Failed to store meeting record", "room_id", record.RoomID, "error", err.Error())
+	}
+
+	p.postThreadReply(record, formatAttendanceReport(stats, participants, p.newParticipantNameResolver()))
+}
+
+// formatAttendanceReport renders the report as markdown. Times DigitalSamba
+// leaves out are derived from the participants' join and leave times.
+func formatAttendanceReport(stats *SessionStatistics, participants []Participant, participantName func(externalID, name string) string) string {
+	var start, end time.Time
+	if stats.StartTime != nil {
+		start = *stats.StartTime
+	}
+	if stats.EndTime != nil {
+		end = *stats.EndTime
+	}
+
+	var intervals []attendanceInterval
+	attendees := map[string]*attendee{}
+	var order []string
+	for _, participant := range participants {
+		if participant.JoinTime == nil {
+			continue
+		}
+
+		joined := *participant.JoinTime
+		left := end
+		if participant.LeaveTime != nil {
+			left = *participant.LeaveTime
+		}
+		if left.IsZero() || left.Before(joined) {
+			left = joined
+		}
+		intervals = append(intervals, attendanceInterval{joined: joined, left: left})
+
+		if start.IsZero() || joined.Before(start) {
+			start = joined
+		}
+		if stats.EndTime == nil && left.After(end) {
+			end = left
+		}
+
+		key := participant.ExternalID
+		if key == "" {
+			key = participant.Name
+		}
+		a, ok := attendees[key]
+		if !ok {
+			a = &attendee{name: participantName(participant.ExternalID, participant.Name), joined: joined, left: left}
+			attendees[key] = a
+			order = append(order, key)
+		}
+		if joined.Before(a.joined) {
+			a.joined = joined
+		}
+		if left.After(a.left) {
+			a.left = left
+		}
+		a.timeInMeeting += left.Sub(joined)
+	}
+
+	peak := stats.MaxParticipantCount
+	if peak == 0 {
+		peak = peakParticipants(intervals)
+	}
+
+	duration := end.Sub(start)
+	if stats.Duration > 0 {
+		duration = time.Duration(stats.Duration) * time.Second
+	}
+
+	var sb strings.Builder
+	sb.WriteString("#### Attendance report\n\n")
+	if !start.IsZero() {
+		fmt.Fprintf(&sb, "* Started: %s\n", start.UTC().Format("2006-01-02 15:04 MST"))
+	}
+	if !end.IsZero() {
+		fmt.Fprintf(&sb, "* Ended: %s\n", end.UTC().Format("2006-01-02 15:04 MST"))
+	}
+	fmt.Fprintf(&sb, "* Duration: %s\n", formatReportDuration(duration))
+	fmt.Fprintf(&sb, "* Peak participants: %d\n", peak)
+
+	if len(order) == 0 {
+		return sb.String()
+	}
+
+	sort.SliceStable(order, func(i, j int) bool {
+		return attendees[order[i]].joined.Before(attendees[order[j]].joined)
+	})
+
+	sb.WriteString("\n| Attendee | Joined | Left | Time in meeting |\n| --- | --- | --- | --- |\n")
+	for _, key := range order {
+		a := attendees[key]
+		fmt.Fprintf(&sb, "| %s | %s | %s | %s |\n",
+			strings.ReplaceAll(a.name, "|", "\\|"), a.joined.UTC().Format("15:04"), a.left.UTC().Format("15:04"), formatReportDuration(a.timeInMeeting))
+	}
+
+	return sb.String()
+}
+
+// peakParticipants returns the largest number of overlapping stays.
+func peakParticipants(intervals []attendanceInterval) int {
+	type change struct {
+		at    time.Time
+		delta int
+	}
+
+	changes := make([]change, 0, len(intervals)*2)
+	for _, interval := range intervals {
+		changes = append(changes, change{interval.joined, 1}, change{interval.left, -1})
+	}
+
+	// Leaving before joining at the same instant does not count as overlap
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].at.Equal(changes[j].at) {
+			return changes[i].delta < changes[j].delta
+		}
+		return changes[i].at.Before(changes[j].at)
+	})
+
+	current, peak := 0, 0
+	for _, c := range changes {
+		current += c.delta
+		if current > peak {
+			peak = current
+		}
+	}
+
+	return peak
+}
+
+func formatReportDuration(d time.Duration) string {
+	if d < time.Minute {
+		return "<1m"
+	}
+
+	d = d.Round(time.Minute)
+	if d < time.Hour {
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	}
+
+	return fmt.Sprintf("%dh %dm", int(d.Hours()), int(d.Minutes())%60)
+}
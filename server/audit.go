@@ -0,0 +1,337 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+// Audited actions.
+const (
+	auditActionMeetingCreate        = "meeting.create"
+	auditActionMeetingEnd           = "meeting.end"
+	auditActionTokenIssue           = "token.issue"
+	auditActionInviteCreate         = "invite.create"
+	auditActionInviteUse            = "invite.use"
+	auditActionChannelSettingUpdate = "channel_setting.update"
+	auditActionConfigUpdate         = "config.update"
+	auditActionPersonalRoomRotate   = "personal_room.rotate"
+	auditActionPersonalRoomDelete   = "personal_room.delete"
+	auditActionJoinRequestCreate    = "join_request.create"
+	auditActionJoinRequestAdmit     = "join_request.admit"
+	auditActionJoinRequestDeny      = "join_request.deny"
+	auditActionPasscodeLockout      = "passcode.lockout"
+	auditActionBreakoutCreate       = "breakout.create"
+	auditActionStreamStart          = "stream.start"
+	auditActionStreamStop           = "stream.stop"
+	auditActionRecordingStart       = "recording.start"
+	auditActionRecordingStop        = "recording.stop"
+	auditActionRecordingConsent     = "recording.consent"
+)
+
+const (
+	auditFormatJSONL = "jsonl"
+	auditFormatCSV   = "csv"
+)
+
+// Audit entries are stored under a key per entry, starting with the hour they
+// were written in, so that writing never contends with other servers and
+// entries can be found by hour.
+const auditKeyPrefix = "audit_"
+const auditKeyLayout = "2006010215"
+
+// defaultAuditExportDays is how far back an export goes by default.
+const defaultAuditExportDays = 7
+
+// maxAuditExportDays bounds an export, which reads every entry in its range.
+const maxAuditExportDays = 366
+
+// AuditEntry is one record of the audit trail.
+type AuditEntry struct {
+	ID        string            `json:"id"`
+	Timestamp int64             `json:"timestamp"`
+	Action    string            `json:"action"`
+	UserID    string            `json:"user_id,omitempty"`
+	ChannelID string            `json:"channel_id,omitempty"`
+	RoomID    string            `json:"room_id,omitempty"`
+	Details   map[string]string `json:"details,omitempty"`
+}
+
+// auditKey is the key of the hour of t, which the keys of its entries start
+// with.
+func auditKey(t time.Time) string {
+	return auditKeyPrefix + t.UTC().Format(auditKeyLayout)
+}
+
+func auditEntryKey(entry *AuditEntry) string {
+	return auditKey(time.UnixMilli(entry.Timestamp)) + "_" + entry.ID
+}
+
+// audit appends an entry to the audit trail. Failures are logged, never
+// returned: auditing must not break the operation it records.
+func (p *Plugin) audit(action, userID, channelID, roomID string, details map[string]string) {
+	entry := &AuditEntry{
+		ID:        model.NewId(),
+		Timestamp: model.GetMillis(),
+		Action:    action,
+		UserID:    userID,
+		ChannelID: channelID,
+		RoomID:    roomID,
+		Details:   details,
+	}
+
+	b, err := json.Marshal(entry)
+	if err == nil {
+		if appErr := p.API.KVSet(auditEntryKey(entry), b); appErr == nil {
+			return
+		}
+	}
+
+	p.API.LogError("Failed to write audit entry", "action", action, "user_id", userID, "room_id", roomID)
+}
+
+// getAuditEntries returns the entries between since and until, oldest first.
+func (p *Plugin) getAuditEntries(since, until time.Time) ([]*AuditEntry, error) {
+	keys, err := p.listKeys(auditKeyPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	first, last := auditKey(since), auditKey(until)
+	var entries []*AuditEntry
+	for _, key := range keys {
+		// The hour layout sorts like the time it stands for
+		hourKey := key[:min(len(key), len(first))]
+		if hourKey < first || hourKey > last {
+			continue
+		}
+
+		data, appErr := p.API.KVGet(key)
+		if appErr != nil {
+			return nil, appErr
+		}
+		if data == nil {
+			continue
+		}
+
+		var entry AuditEntry
+		if err := json.Unmarshal(data, &entry); err != nil {
+			return nil, err
+		}
+
+		if entry.Timestamp >= since.UnixMilli() && entry.Timestamp <= until.UnixMilli() {
+			entries = append(entries, &entry)
+		}
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Timestamp < entries[j].Timestamp
+	})
+
+	return entries, nil
+}
+
+// deleteExpiredAuditEntries deletes the audit entries of the hours older
+// than the retention period.
+func (p *Plugin) deleteExpiredAuditEntries() {
+	retentionDays := p.getConfiguration().DigitalSambaAuditRetentionDays
+	if retentionDays <= 0 {
+		return
+	}
+
+	cutoff := auditKey(time.Now().AddDate(0, 0, -retentionDays))
+
+	keys, err := p.listKeys(auditKeyPrefix)
+	if err != nil {
+		p.API.LogWarn("Failed to list audit entries", "error", err.Error())
+		return
+	}
+
+	var expired []string
+	for _, key := range keys {
+		// The hour layout sorts like the time it stands for
+		if key < cutoff {
+			expired = append(expired, key)
+		}
+	}
+
+	for _, key := range expired {
+		if appErr := p.API.KVDelete(key); appErr != nil {
+			p.API.LogWarn("Failed to delete audit entries", "key", key, "error", appErr.Error())
+		}
+	}
+}
+
+// formatAuditEntries renders the entries as JSON Lines or CSV.
+func formatAuditEntries(entries []*AuditEntry, format string) ([]byte, error) {
+	var buf bytes.Buffer
+
+	switch format {
+	case auditFormatJSONL:
+		encoder := json.NewEncoder(&buf)
+		for _, entry := range entries {
+			if err := encoder.Encode(entry); err != nil {
+				return nil, err
+			}
+		}
+	case auditFormatCSV:
+		w := csv.NewWriter(&buf)
+		_ = w.Write([]string{"id", "timestamp", "action", "user_id", "channel_id", "room_id", "details"})
+		for _, entry := range entries {
+			_ = w.Write([]string{
+				entry.ID,
+				time.UnixMilli(entry.Timestamp).UTC().Format(time.RFC3339),
+				entry.Action,
+				entry.UserID,
+				entry.ChannelID,
+				entry.RoomID,
+				formatAuditDetails(entry.Details),
+			})
+		}
+		w.Flush()
+		if err := w.Error(); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown format %q, use %s or %s", format, auditFormatJSONL, auditFormatCSV)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// formatAuditDetails flattens the details into "key=value" pairs in key
+// order, for the CSV export.
+func formatAuditDetails(details map[string]string) string {
+	keys := make([]string, 0, len(details))
+	for key := range details {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, key := range keys {
+		pairs = append(pairs, key+"="+details[key])
+	}
+
+	return strings.Join(pairs, "; ")
+}
+
+// changedSettings returns the names of the configuration fields that differ.
+func changedSettings(oldConfig, newConfig *configuration) []string {
+	oldValue := reflect.ValueOf(*oldConfig)
+	newValue := reflect.ValueOf(*newConfig)
+
+	var changed []string
+	for i := 0; i < oldValue.NumField(); i++ {
+		if !reflect.DeepEqual(oldValue.Field(i).Interface(), newValue.Field(i).Interface()) {
+			changed = append(changed, oldValue.Type().Field(i).Name)
+		}
+	}
+
+	return changed
+}
+
+func (p *Plugin) handleAuditExport(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	format := query.Get("format")
+	if format == "" {
+		format = auditFormatJSONL
+	}
+
+	until := time.Now()
+	since := until.AddDate(0, 0, -defaultAuditExportDays)
+	for name, t := range map[string]*time.Time{"since": &since, "until": &until} {
+		value := query.Get(name)
+		if value == "" {
+			continue
+		}
+		millis, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, errorCodeBadRequest, name+" must be a Unix time in milliseconds")
+			return
+		}
+		*t = time.UnixMilli(millis)
+	}
+
+	if until.Sub(since) > maxAuditExportDays*24*time.Hour {
+		writeError(w, http.StatusBadRequest, errorCodeBadRequest, fmt.Sprintf("The export range cannot exceed %d days", maxAuditExportDays))
+		return
+	}
+
+	entries, err := p.getAuditEntries(since, until)
+	if err != nil {
+		p.writeInternalError(w, "Failed to get audit entries", err)
+		return
+	}
+
+	data, err := formatAuditEntries(entries, format)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, errorCodeBadRequest, err.Error())
+		return
+	}
+
+	contentType := "application/x-ndjson"
+	if format == auditFormatCSV {
+		contentType = "text/csv"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=digitalsamba-audit.%s", format))
+	_, _ = w.Write(data)
+}
+
+// runAuditCommand sends the admin the audit trail of the last days as a file
+// in a direct message from the bot.
+func (p *Plugin) runAuditCommand(args *model.CommandArgs, fields []string) (*model.CommandResponse, *model.AppError) {
+	days := defaultAuditExportDays
+	format := auditFormatJSONL
+	for _, field := range fields {
+		if n, err := strconv.Atoi(field); err == nil && n > 0 && n <= maxAuditExportDays {
+			days = n
+		} else {
+			format = field
+		}
+	}
+
+	until := time.Now()
+	entries, err := p.getAuditEntries(until.AddDate(0, 0, -days), until)
+	if err != nil {
+		return p.sendEphemeralResponse(args, "Failed to get audit entries")
+	}
+
+	data, err := formatAuditEntries(entries, format)
+	if err != nil {
+		return p.sendEphemeralResponse(args, "Usage: `/digitalsamba admin audit [days] [jsonl|csv]`")
+	}
+
+	channel, appErr := p.API.GetDirectChannel(args.UserId, p.botID)
+	if appErr != nil {
+		return p.sendEphemeralResponse(args, "Failed to open a direct message with the bot")
+	}
+
+	fileInfo, appErr := p.API.UploadFile(data, channel.Id, fmt.Sprintf("digitalsamba-audit-%s.%s", until.UTC().Format("20060102"), format))
+	if appErr != nil {
+		return p.sendEphemeralResponse(args, "Failed to upload the audit export")
+	}
+
+	post := &model.Post{
+		UserId:    p.botID,
+		ChannelId: channel.Id,
+		Message:   fmt.Sprintf("Audit trail of the last %d days: %d entries.", days, len(entries)),
+		FileIds:   []string{fileInfo.Id},
+	}
+	if _, appErr := p.API.CreatePost(post); appErr != nil {
+		return p.sendEphemeralResponse(args, "Failed to send the audit export")
+	}
+
+	return p.sendEphemeralResponse(args, "The audit export was sent to you in a direct message from the DigitalSamba bot.")
+}
@@ -0,0 +1,352 @@
+package main
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+const maxBreakoutRooms = 20
+
+// maxBreakoutMembers bounds the channel members shuffled into breakout rooms.
+const maxBreakoutMembers = 500
+
+const breakoutCommandUsage = "Use `/digitalsamba breakout <number> [random | @user1 @user2 ...]` in the channel or thread of a running meeting. `random` spreads the channel members over the rooms; a list of users is spread in order, and `|` separates the users of each room, e.g. `@alice @bob | @carol`."
+
+// breakoutAssignment names the users sent to each breakout room.
+type breakoutAssignment [][]string
+
+// parseBreakoutCommand reads `<number> [random | users]`. The returned
+// usernames are without the leading @.
+func parseBreakoutCommand(fields []string) (int, bool, breakoutAssignment, error) {
+	if len(fields) == 0 {
+		return 0, false, nil, errors.New(breakoutCommandUsage)
+	}
+
+	count, err := strconv.Atoi(fields[0])
+	if err != nil || count < 1 || count > maxBreakoutRooms {
+		return 0, false, nil, fmt.Errorf("the number of breakout rooms must be between 1 and %d", maxBreakoutRooms)
+	}
+
+	rest := fields[1:]
+	if len(rest) == 1 && rest[0] == "random" {
+		return count, true, nil, nil
+	}
+	if len(rest) == 0 {
+		return count, false, nil, nil
+	}
+
+	var groups breakoutAssignment
+	var group []string
+	explicit := false
+	for _, field := range rest {
+		if field == "|" {
+			groups = append(groups, group)
+			group = nil
+			explicit = true
+			continue
+		}
+		if !strings.HasPrefix(field, "@") || len(field) < 2 {
+			return 0, false, nil, errors.New(breakoutCommandUsage)
+		}
+		group = append(group, strings.TrimPrefix(field, "@"))
+	}
+	groups = append(groups, group)
+
+	if !explicit {
+		return count, false, spreadOverRooms(groups[0], count), nil
+	}
+
+	if len(groups) > count {
+		return 0, false, nil, fmt.Errorf("%d groups of users do not fit in %d breakout rooms", len(groups), count)
+	}
+	for len(groups) < count {
+		groups = append(groups, nil)
+	}
+
+	return count, false, groups, nil
+}
+
+// spreadOverRooms deals the users over the rooms like cards.
+func spreadOverRooms(users []string, count int) breakoutAssignment {
+	assignment := make(breakoutAssignment, count)
+	for i, user := range users {
+		assignment[i%count] = append(assignment[i%count], user)
+	}
+	return assignment
+}
+
+func shuffle(values []string) {
+	for i := len(values) - 1; i > 0; i-- {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(i+1)))
+		if err != nil {
+			return
+		}
+		j := int(n.Int64())
+		values[i], values[j] = values[j], values[i]
+	}
+}
+
+// randomBreakoutAssignment spreads the channel's members, except bots and
+// the meeting creator, at random over the rooms.
+func (p *Plugin) randomBreakoutAssignment(record *MeetingRecord, count int) (breakoutAssignment, error) {
+	users, appErr := p.API.GetUsersInChannel(record.ChannelID, "username", 0, maxBreakoutMembers)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	var usernames []string
+	for _, user := range users {
+		if user.IsBot || user.Id == record.CreatorID || user.DeleteAt != 0 {
+			continue
+		}
+		usernames = append(usernames, user.Username)
+	}
+	shuffle(usernames)
+
+	return spreadOverRooms(usernames, count), nil
+}
+
+// findActiveMeeting returns the running meeting of a thread, or the newest
+// running meeting of the channel outside a thread.
+func (p *Plugin) findActiveMeeting(channelID, rootID string) (*MeetingRecord, error) {
+	roomIDs, err := p.getChannelMeetings(channelID)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := len(roomIDs) - 1; i >= 0; i-- {
+		record, err := p.getMeetingRecord(roomIDs[i])
+		if err != nil || record == nil || record.EndedAt != 0 {
+			continue
+		}
+		if rootID == "" || record.PostID == rootID {
+			return record, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// createBreakoutRooms adds the breakout rooms to a meeting and posts the link
+// of each one as a reply in the meeting thread, mentioning the users sent
+// there. When one fails, the rooms created before it are kept with the
+// meeting, so that they end with it.
+func (p *Plugin) createBreakoutRooms(record *MeetingRecord, userID string, count int, assignment breakoutAssignment) error {
+	account := p.getAccount(record.TeamID)
+	first := len(record.Breakouts) + 1
+
+	created := 0
+	var createErr error
+	for i := 0; i < count; i++ {
+		var members []string
+		if i < len(assignment) {
+			members = assignment[i]
+		}
+
+		name := fmt.Sprintf("%s - Breakout %d", record.Topic, first+i)
+		if createErr = p.createBreakoutRoom(account, record, name, members); createErr != nil {
+			break
+		}
+		created++
+	}
+
+	if len(record.Breakouts) >= first {
+		breakouts := record.Breakouts[first-1:]
+		if err := p.updateMeetingRecord(record, func(record *MeetingRecord) bool {
+			for _, roomID := range breakouts {
+				if !slices.Contains(record.Breakouts, roomID) {
+					record.Breakouts = append(record.Breakouts, roomID)
+				}
+			}
+			return true
+		}); err != nil {
+			return err
+		}
+	}
+
+	if created > 0 {
+		p.audit(auditActionBreakoutCreate, userID, record.ChannelID, record.RoomID, map[string]string{
+			"count": strconv.Itoa(created),
+		})
+	}
+
+	return createErr
+}
+
+// createBreakoutRoom creates one breakout room, adds it to the meeting
+// record and posts it. The caller saves the meeting record.
+func (p *Plugin) createBreakoutRoom(account *digitalSambaAccount, record *MeetingRecord, name string, members []string) error {
+	breakout, err := account.client.CreateBreakoutRoom(record.RoomID, &CreateBreakoutRoomRequest{Name: name})
+	if err != nil {
+		return fmt.Errorf("failed to create breakout room: %w", err)
+	}
+	record.Breakouts = append(record.Breakouts, breakout.ID)
+
+	// A breakout without its own friendly URL is still its own room, which
+	// its ID identifies. The room of the meeting would be the wrong one.
+	meetingID := breakout.FriendlyURL
+	if meetingID == "" {
+		meetingID = breakout.ID
+	}
+
+	meetingURL, err := account.meetingURL(&Room{
+		ID:          breakout.ID,
+		FriendlyURL: meetingID,
+		RoomURL:     breakout.RoomURL,
+	})
+	if err != nil {
+		return err
+	}
+
+	post, err := p.postBreakoutRoom(record, breakout, meetingID, name, meetingURL, members)
+	if err != nil {
+		return err
+	}
+
+	// Breakouts share the entitlement and the account of their meeting
+	breakoutRecord := &MeetingRecord{
+		MeetingID:    meetingID,
+		RoomID:       breakout.ID,
+		FriendlyURL:  breakout.FriendlyURL,
+		MeetingURL:   meetingURL,
+		Topic:        name,
+		ChannelID:    record.ChannelID,
+		TeamID:       record.TeamID,
+		CreatorID:    record.CreatorID,
+		PostID:       post.Id,
+		CreatedAt:    model.GetMillis(),
+		Private:      record.Private,
+		PasscodeHash: record.PasscodeHash,
+		ParentRoomID: record.RoomID,
+
+		RequireConsent: record.RequireConsent,
+		ConsentMessage: record.ConsentMessage,
+	}
+	if err := p.saveMeetingRecord(breakoutRecord); err != nil {
+		p.API.LogWarn("Failed to store breakout room", "room_id", breakout.ID, "error", err.Error())
+	}
+
+	return nil
+}
+
+// postBreakoutRoom posts a breakout room in the meeting thread. The post is
+// a meeting post, so the webapp joins it with a token like any meeting.
+func (p *Plugin) postBreakoutRoom(record *MeetingRecord, breakout *BreakoutRoom, meetingID, name, meetingURL string, members []string) (*model.Post, error) {
+	message := fmt.Sprintf("**%s**: [Join](%s)", name, meetingURL)
+	if len(members) > 0 {
+		mentions := make([]string, 0, len(members))
+		for _, member := range members {
+			mentions = append(mentions, "@"+member)
+		}
+		message += "\n" + strings.Join(mentions, " ")
+	}
+
+	post := &model.Post{
+		UserId:    p.botID,
+		ChannelId: record.ChannelID,
+		RootId:    record.PostID,
+		Message:   message,
+		Type:      "custom_digitalsamba",
+		Props: map[string]interface{}{
+			"meeting_id":     meetingID,
+			"room_id":        breakout.ID,
+			"meeting_url":    meetingURL,
+			"meeting_topic":  name,
+			"private":        record.Private,
+			"parent_room_id": record.RoomID,
+			"breakout":       true,
+		},
+	}
+
+	createdPost, appErr := p.API.CreatePost(post)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	return createdPost, nil
+}
+
+// endBreakoutRooms marks the breakout rooms of an ended meeting as ended.
+// They close in DigitalSamba together with the meeting's session.
+func (p *Plugin) endBreakoutRooms(record *MeetingRecord, userID string) {
+	for _, roomID := range record.Breakouts {
+		breakout, err := p.getMeetingRecord(roomID)
+		if err != nil || breakout == nil || breakout.EndedAt != 0 {
+			continue
+		}
+
+		if err := p.updateMeetingRecord(breakout, func(breakout *MeetingRecord) bool {
+			breakout.EndedAt = record.EndedAt
+			breakout.EndedBy = userID
+			return true
+		}); err != nil {
+			p.API.LogWarn("Failed to end breakout room", "room_id", roomID, "error", err.Error())
+			continue
+		}
+		p.markMeetingPostEnded(breakout)
+	}
+}
+
+func (p *Plugin) runBreakoutCommand(args *model.CommandArgs, fields []string) (*model.CommandResponse, *model.AppError) {
+	if !p.getConfiguration().DigitalSambaEnableBreakoutRooms {
+		return p.sendEphemeralResponse(args, "Breakout rooms are disabled on this server.")
+	}
+
+	count, random, assignment, err := parseBreakoutCommand(fields)
+	if err != nil {
+		return p.sendEphemeralResponse(args, err.Error())
+	}
+
+	record, err := p.findActiveMeeting(args.ChannelId, args.RootId)
+	if err != nil {
+		return p.sendEphemeralResponse(args, "Failed to find the meeting")
+	}
+	if record == nil {
+		return p.sendEphemeralResponse(args, "There is no running meeting here. "+breakoutCommandUsage)
+	}
+
+	if !p.canManageMeeting(args.UserId, record) {
+		return p.sendEphemeralResponse(args, "Only the meeting creator or a channel admin can create breakout rooms")
+	}
+
+	if random {
+		if assignment, err = p.randomBreakoutAssignment(record, count); err != nil {
+			return p.sendEphemeralResponse(args, "Failed to get the channel members")
+		}
+	} else if unknown := p.unknownChannelUsers(record.ChannelID, assignment); len(unknown) > 0 {
+		return p.sendEphemeralResponse(args, fmt.Sprintf("These users are not members of the channel: %s", strings.Join(unknown, ", ")))
+	}
+
+	if err := p.createBreakoutRooms(record, args.UserId, count, assignment); err != nil {
+		p.API.LogError("Failed to create breakout rooms", "room_id", record.RoomID, "error", err.Error())
+		return p.sendEphemeralResponse(args, fmt.Sprintf("Failed to create breakout rooms: %v", err))
+	}
+
+	return &model.CommandResponse{}, nil
+}
+
+// unknownChannelUsers returns the assigned usernames that are not members of
+// the channel.
+func (p *Plugin) unknownChannelUsers(channelID string, assignment breakoutAssignment) []string {
+	var unknown []string
+	for _, group := range assignment {
+		for _, username := range group {
+			user, appErr := p.API.GetUserByUsername(username)
+			if appErr != nil {
+				unknown = append(unknown, "@"+username)
+				continue
+			}
+			if _, appErr := p.API.GetChannelMember(channelID, user.Id); appErr != nil {
+				unknown = append(unknown, "@"+username)
+			}
+		}
+	}
+	return unknown
+}
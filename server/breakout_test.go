@@ -0,0 +1,78 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseBreakoutCommand(t *testing.T) {
+	tests := []struct {
+		name           string
+		fields         []string
+		wantCount      int
+		wantRandom     bool
+		wantAssignment breakoutAssignment
+		wantErr        string
+	}{
+		{name: "no arguments", wantErr: "Use `/digitalsamba breakout"},
+		{name: "count only", fields: []string{"3"}, wantCount: 3},
+		{name: "random", fields: []string{"2", "random"}, wantCount: 2, wantRandom: true},
+		{name: "not a number", fields: []string{"two"}, wantErr: "between 1 and 20"},
+		{name: "zero rooms", fields: []string{"0"}, wantErr: "between 1 and 20"},
+		{name: "too many rooms", fields: []string{"21"}, wantErr: "between 1 and 20"},
+		{name: "most rooms", fields: []string{"20"}, wantCount: 20},
+		{
+			name:           "users dealt over the rooms",
+			fields:         []string{"2", "@alice", "@bob", "@carol"},
+			wantCount:      2,
+			wantAssignment: breakoutAssignment{{"alice", "carol"}, {"bob"}},
+		},
+		{
+			name:           "more rooms than users",
+			fields:         []string{"3", "@alice"},
+			wantCount:      3,
+			wantAssignment: breakoutAssignment{{"alice"}, nil, nil},
+		},
+		{
+			name:           "explicit groups",
+			fields:         []string{"2", "@alice", "@bob", "|", "@carol"},
+			wantCount:      2,
+			wantAssignment: breakoutAssignment{{"alice", "bob"}, {"carol"}},
+		},
+		{
+			name:           "fewer groups than rooms",
+			fields:         []string{"3", "@alice", "|", "@bob"},
+			wantCount:      3,
+			wantAssignment: breakoutAssignment{{"alice"}, {"bob"}, nil},
+		},
+		{
+			name:           "empty group",
+			fields:         []string{"2", "|", "@bob"},
+			wantCount:      2,
+			wantAssignment: breakoutAssignment{nil, {"bob"}},
+		},
+		{name: "more groups than rooms", fields: []string{"1", "@alice", "|", "@bob"}, wantErr: "2 groups of users do not fit in 1 breakout rooms"},
+		{name: "user without @", fields: []string{"2", "alice"}, wantErr: "Use `/digitalsamba breakout"},
+		{name: "lone @", fields: []string{"2", "@"}, wantErr: "Use `/digitalsamba breakout"},
+		{name: "random with users", fields: []string{"2", "random", "@alice"}, wantErr: "Use `/digitalsamba breakout"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			count, random, assignment, err := parseBreakoutCommand(tt.fields)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("parseBreakoutCommand(%q) error = %v, want an error containing %q", tt.fields, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseBreakoutCommand(%q) error = %v", tt.fields, err)
+			}
+			if count != tt.wantCount || random != tt.wantRandom || !reflect.DeepEqual(assignment, tt.wantAssignment) {
+				t.Errorf("parseBreakoutCommand(%q) = %d, %v, %q, want %d, %v, %q", tt.fields, count, random, assignment, tt.wantCount, tt.wantRandom, tt.wantAssignment)
+			}
+		})
+	}
+}
@@ -0,0 +1,377 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/pluginapi/i18n"
+)
+
+// ringingCallsKey holds the deadline of every ringing call, keyed by room ID.
+// The deadlines survive restarts and are checked on one server only.
+const ringingCallsKey = "ringing_calls"
+
+// ringTimeoutCheckInterval is how often the deadlines are checked, which is
+// how late a call can be marked as missed.
+const ringTimeoutCheckInterval = 30 * time.Second
+
+// maxCallInvitees is the number of people besides the caller that fit in a
+// group message channel.
+const maxCallInvitees = 7
+
+// parseCallArgs splits "@alice @bob some topic" into usernames and a topic.
+// Mentions are only read up to the first word that is not one.
+func parseCallArgs(fields []string) (usernames []string, topic string) {
+	i := 0
+	for ; i < len(fields); i++ {
+		if !strings.HasPrefix(fields[i], "@") || len(fields[i]) == 1 {
+			break
+		}
+		usernames = append(usernames, strings.ToLower(strings.TrimPrefix(fields[i], "@")))
+	}
+
+	return usernames, strings.Join(fields[i:], " ")
+}
+
+func (p *Plugin) runCallCommand(args *model.CommandArgs, fields []string) (*model.CommandResponse, *model.AppError) {
+	usernames, topic := parseCallArgs(fields)
+	if len(usernames) == 0 {
+		return p.sendEphemeralResponse(args, "Usage: `/digitalsamba call @user1 [@user2 ...] [topic]`")
+	}
+
+	caller, appErr := p.API.GetUser(args.UserId)
+	if appErr != nil {
+		return p.sendEphemeralResponse(args, "Failed to get user information")
+	}
+
+	invitees, err := p.getCallInvitees(caller, usernames)
+	if err != nil {
+		return p.sendEphemeralResponse(args, err.Error())
+	}
+
+	if _, err := p.startCall(caller, invitees, topic); err != nil {
+		p.API.LogError("Failed to start call", "user_id", caller.Id, "error", err.Error())
+		return p.sendEphemeralResponse(args, "Failed to start the call")
+	}
+
+	return &model.CommandResponse{}, nil
+}
+
+// getCallInvitees resolves the mentioned usernames, leaving out the caller
+// and duplicates.
+func (p *Plugin) getCallInvitees(caller *model.User, usernames []string) ([]*model.User, error) {
+	seen := map[string]bool{caller.Username: true}
+	var unique []string
+	for _, username := range usernames {
+		if !seen[username] {
+			seen[username] = true
+			unique = append(unique, username)
+		}
+	}
+
+	if len(unique) == 0 {
+		return nil, fmt.Errorf("mention at least one other user to call")
+	}
+	if len(unique) > maxCallInvitees {
+		return nil, fmt.Errorf("you can call at most %d people at once", maxCallInvitees)
+	}
+
+	users, appErr := p.API.GetUsersByUsernames(unique)
+	if appErr != nil {
+		return nil, fmt.Errorf("failed to look up users")
+	}
+
+	found := map[string]bool{}
+	for _, user := range users {
+		found[user.Username] = true
+		if user.DeleteAt != 0 || user.IsBot {
+			return nil, fmt.Errorf("@%s cannot be called", user.Username)
+		}
+	}
+	for _, username := range unique {
+		if !found[username] {
+			return nil, fmt.Errorf("user @%s not found", username)
+		}
+	}
+
+	return users, nil
+}
+
+// startCall opens or reuses the DM or group channel with the invitees,
+// starts a meeting there and sends each invitee a direct message from the bot
+// with a join link.
+func (p *Plugin) startCall(caller *model.User, invitees []*model.User, topic string) (*MeetingInfo, error) {
+	var channel *model.Channel
+	var appErr *model.AppError
+	if len(invitees) == 1 {
+		channel, appErr = p.API.GetDirectChannel(caller.Id, invitees[0].Id)
+	} else {
+		userIDs := []string{caller.Id}
+		for _, invitee := range invitees {
+			userIDs = append(userIDs, invitee.Id)
+		}
+		channel, appErr = p.API.GetGroupChannel(userIDs)
+	}
+	if appErr != nil {
+		return nil, fmt.Errorf("failed to open the channel: %w", appErr)
+	}
+
+	meetingInfo, err := p.startMeeting(caller, channel, "", topic, false, "", "")
+	if err != nil {
+		return nil, err
+	}
+
+	record, err := p.getMeetingRecord(meetingInfo.RoomID)
+	if err != nil || record == nil {
+		return meetingInfo, err
+	}
+
+	// Webapp users are rung by startMeeting, the direct message reaches
+	// everyone else
+	for _, invitee := range invitees {
+		p.sendCallInvite(caller, invitee, record)
+	}
+
+	return meetingInfo, nil
+}
+
+// joinMeetingURL opens the meeting with a token issued when the link is
+// followed, so that no token is ever stored in a post.
+func (p *Plugin) joinMeetingURL(roomID string) string {
+	return fmt.Sprintf("%s/plugins/digitalsamba/api/v1/meetings/%s/join", *p.API.GetConfig().ServiceSettings.SiteURL, url.PathEscape(roomID))
+}
+
+func (p *Plugin) sendCallInvite(caller, invitee *model.User, record *MeetingRecord) {
+	joinURL := p.joinMeetingURL(record.RoomID)
+
+	l := p.b.GetUserLocalizer(invitee.Id)
+	message := p.b.LocalizeWithConfig(l, &i18n.LocalizeConfig{
+		DefaultMessage: &i18n.Message{
+			ID:    "digitalsamba.call.invite",
+			Other: "@{{.Caller}} is calling you: **{{.Topic}}**\n\n[Join call]({{.JoinURL}})",
+		},
+		TemplateData: map[string]string{
+			"Caller":  caller.Username,
+			"Topic":   record.Topic,
+			"JoinURL": joinURL,
+		},
+	})
+
+	p.sendDirectMessage(invitee.Id, message)
+}
+
+// handleJoinMeeting sends the user into the meeting with a token, after the
+// same checks as POST /token. Users who must ask to join or consent first
+// are sent to the meeting post, where the webapp asks them.
+func (p *Plugin) handleJoinMeeting(w http.ResponseWriter, r *http.Request) {
+	userID := r.Header.Get("Mattermost-User-Id")
+
+	record, ok := p.getMeetingForRequest(w, r)
+	if !ok {
+		return
+	}
+
+	permalink := fmt.Sprintf("%s/_redirect/pl/%s", *p.API.GetConfig().ServiceSettings.SiteURL, record.PostID)
+	if record.EndedAt != 0 {
+		http.Redirect(w, r, permalink, http.StatusFound)
+		return
+	}
+
+	user, appErr := p.API.GetUser(userID)
+	if appErr != nil {
+		p.writeInternalError(w, "Failed to get user", appErr)
+		return
+	}
+
+	token, err := p.issueMeetingToken(user, record)
+	var accessErr *meetingAccessError
+	if errors.As(err, &accessErr) {
+		http.Redirect(w, r, permalink, http.StatusFound)
+		return
+	}
+	if err != nil {
+		p.writeInternalError(w, "Failed to create token", err)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	http.Redirect(w, r, fmt.Sprintf("%s?token=%s", record.MeetingURL, url.QueryEscape(token.Token)), http.StatusFound)
+}
+
+// markCallAnswered records that an invitee joined a call.
+func (p *Plugin) markCallAnswered(record *MeetingRecord, userID string) {
+	if len(record.Invitees) == 0 || record.AnsweredAt != 0 || userID == "" || userID == record.CreatorID {
+		return
+	}
+
+	if err := p.updateMeetingRecord(record, func(record *MeetingRecord) bool {
+		if record.AnsweredAt != 0 {
+			return false
+		}
+		record.AnsweredAt = model.GetMillis()
+		return true
+	}); err != nil {
+		p.API.LogWarn("Failed to mark call as answered", "room_id", record.RoomID, "error", err.Error())
+	}
+
+	p.stopRinging(record, []string{userID})
+}
+
+// checkCallAnswered stops the ringing and marks a call as missed when no
+// invitee has joined it. Without participant events from DigitalSamba, the
+// live participant list decides.
+func (p *Plugin) checkCallAnswered(roomID string) {
+	record, err := p.getMeetingRecord(roomID)
+	if err != nil || record == nil || record.EndedAt != 0 || record.Unanswered {
+		return
+	}
+
+	pending := pendingCallees(record)
+	p.stopRinging(record, pending)
+
+	// Declines were already posted in the thread
+	if record.AnsweredAt != 0 || len(pending) == 0 {
+		return
+	}
+
+	participants, err := p.getAccount(record.TeamID).client.ListLiveParticipants(record.RoomID)
+	if err == nil {
+		for _, participant := range participants {
+			if participant.ExternalID != "" && participant.ExternalID != record.CreatorID {
+				p.markCallAnswered(record, participant.ExternalID)
+				return
+			}
+		}
+	}
+
+	// An invitee who joined in the meantime answered the call
+	missed := false
+	if err := p.updateMeetingRecord(record, func(record *MeetingRecord) bool {
+		missed = record.AnsweredAt == 0 && !record.Unanswered
+		record.Unanswered = record.Unanswered || missed
+		return missed
+	}); err != nil {
+		p.API.LogWarn("Failed to mark call as unanswered", "room_id", record.RoomID, "error", err.Error())
+		return
+	}
+	if !missed {
+		return
+	}
+
+	l := p.b.GetServerLocalizer()
+	message := p.b.LocalizeDefaultMessage(l, &i18n.Message{
+		ID:    "digitalsamba.call.missed",
+		Other: "Missed call",
+	})
+
+	if post, appErr := p.API.GetPost(record.PostID); appErr == nil {
+		post.AddProp("call_unanswered", true)
+		if _, appErr := p.API.UpdatePost(post); appErr != nil {
+			p.API.LogWarn("Failed to update call post", "post_id", post.Id, "error", appErr.Error())
+		}
+	}
+
+	p.postThreadReply(record, message)
+}
+
+// checkRingTimeouts checks the calls whose deadline passed. It runs as a
+// cluster job.
+func (p *Plugin) checkRingTimeouts() {
+	data, appErr := p.API.KVGet(ringingCallsKey)
+	if appErr != nil {
+		p.API.LogWarn("Failed to get ringing calls", "error", appErr.Error())
+		return
+	}
+	if data == nil {
+		return
+	}
+
+	var deadlines map[string]int64
+	if err := json.Unmarshal(data, &deadlines); err != nil {
+		p.API.LogWarn("Failed to read ringing calls", "error", err.Error())
+		return
+	}
+
+	now := model.GetMillis()
+	var due []string
+	for roomID, deadline := range deadlines {
+		if deadline <= now {
+			due = append(due, roomID)
+		}
+	}
+	if len(due) == 0 {
+		return
+	}
+
+	for _, roomID := range due {
+		p.checkCallAnswered(roomID)
+	}
+
+	err := p.updateRingingCalls(func(deadlines map[string]int64) {
+		for _, roomID := range due {
+			delete(deadlines, roomID)
+		}
+	})
+	if err != nil {
+		p.API.LogWarn("Failed to update ringing calls", "error", err.Error())
+	}
+}
+
+// updateRingingCalls changes the call deadlines, retrying on concurrent
+// updates.
+func (p *Plugin) updateRingingCalls(update func(deadlines map[string]int64)) error {
+	for i := 0; i < 5; i++ {
+		oldData, appErr := p.API.KVGet(ringingCallsKey)
+		if appErr != nil {
+			return appErr
+		}
+
+		deadlines := map[string]int64{}
+		if oldData != nil {
+			if err := json.Unmarshal(oldData, &deadlines); err != nil {
+				return err
+			}
+		}
+
+		update(deadlines)
+
+		newData, err := json.Marshal(deadlines)
+		if err != nil {
+			return err
+		}
+
+		if bytes.Equal(oldData, newData) {
+			return nil
+		}
+
+		ok, appErr := p.API.KVCompareAndSet(ringingCallsKey, oldData, newData)
+		if appErr != nil {
+			return appErr
+		}
+		if ok {
+			return nil
+		}
+	}
+
+	return errors.New("too many concurrent updates to the ringing calls")
+}
+
+// postThreadReply posts a message from the bot in the thread of the meeting post.
+func (p *Plugin) postThreadReply(record *MeetingRecord, message string) {
+	post := &model.Post{
+		UserId:    p.botID,
+		ChannelId: record.ChannelID,
+		RootId:    record.PostID,
+		Message:   message,
+	}
+	if _, appErr := p.API.CreatePost(post); appErr != nil {
+		p.API.LogWarn("Failed to post thread reply", "room_id", record.RoomID, "error", appErr.Error())
+	}
+}
@@ -0,0 +1,304 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+const channelSettingsKeyPrefix = "channel_config_"
+const channelRoomKeyPrefix = "channel_room_"
+
+// ChannelSettings holds the meeting defaults a channel admin has set for a
+// channel. Unset fields fall through to the user and server settings.
+type ChannelSettings struct {
+	NamingScheme    string `json:"naming_scheme,omitempty"`
+	TemplateID      string `json:"template_id,omitempty"`
+	Recording       *bool  `json:"recording,omitempty"`
+	MaxParticipants int    `json:"max_participants,omitempty"`
+	GuestAccess     *bool  `json:"guest_access,omitempty"`
+	PersistentRoom  *bool  `json:"persistent_room,omitempty"`
+	Transcripts     *bool  `json:"transcripts,omitempty"`
+	// RecordingConsent requires users to acknowledge the consent message
+	// before they join. ConsentMessage replaces the configured message.
+	RecordingConsent *bool  `json:"recording_consent,omitempty"`
+	ConsentMessage   string `json:"consent_message,omitempty"`
+}
+
+// ChannelRoom is the DigitalSamba room kept for a channel with a persistent room.
+type ChannelRoom struct {
+	RoomID      string `json:"room_id"`
+	FriendlyURL string `json:"friendly_url"`
+}
+
+// meetingSettings are the effective settings for a meeting, resolved in the
+// order channel settings, then user settings, then server configuration.
+type meetingSettings struct {
+	NamingScheme     string
+	TemplateID       string
+	RecordingEnabled bool
+	MaxParticipants  int
+	GuestAccess      bool
+	PersistentRoom   bool
+	BreakoutRooms    bool
+	// ConsentMessage is the custom recording consent message, empty for the
+	// localized default.
+	ConsentMessage string
+	RequireConsent bool
+}
+
+func (p *Plugin) getChannelSettings(channelID string) (*ChannelSettings, error) {
+	data, appErr := p.API.KVGet(channelSettingsKeyPrefix + channelID)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	if data == nil {
+		return &ChannelSettings{}, nil
+	}
+
+	var settings ChannelSettings
+	if err := json.Unmarshal(data, &settings); err != nil {
+		return nil, err
+	}
+
+	return &settings, nil
+}
+
+func (p *Plugin) setChannelSettings(channelID string, settings *ChannelSettings) error {
+	b, err := json.Marshal(settings)
+	if err != nil {
+		return err
+	}
+
+	if appErr := p.API.KVSet(channelSettingsKeyPrefix+channelID, b); appErr != nil {
+		return appErr
+	}
+
+	return nil
+}
+
+func (p *Plugin) getChannelRoom(channelID string) (*ChannelRoom, error) {
+	data, appErr := p.API.KVGet(channelRoomKeyPrefix + channelID)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	if data == nil {
+		return nil, nil
+	}
+
+	var room ChannelRoom
+	if err := json.Unmarshal(data, &room); err != nil {
+		return nil, err
+	}
+
+	return &room, nil
+}
+
+func (p *Plugin) setChannelRoom(channelID string, room *ChannelRoom) error {
+	b, err := json.Marshal(room)
+	if err != nil {
+		return err
+	}
+
+	if appErr := p.API.KVSet(channelRoomKeyPrefix+channelID, b); appErr != nil {
+		return appErr
+	}
+
+	return nil
+}
+
+// resolveMeetingSettings merges the server configuration, the user's settings
+// and the channel's settings. Channel settings win over user settings, which
+// win over the server configuration. The channel's participant limit can only
+// lower the server limit, never raise it. Meetings in private channels are
+// private unless the channel allows guests, and a private template always
+// makes the room private.
+func (p *Plugin) resolveMeetingSettings(userID string, channel *model.Channel) *meetingSettings {
+	config := p.getConfiguration()
+
+	settings := &meetingSettings{
+		NamingScheme:     config.DigitalSambaNamingScheme,
+		RecordingEnabled: config.DigitalSambaEnableRecording,
+		MaxParticipants:  config.DigitalSambaMaxParticipants,
+		GuestAccess:      true,
+		BreakoutRooms:    config.DigitalSambaEnableBreakoutRooms,
+		ConsentMessage:   strings.TrimSpace(config.DigitalSambaRecordingConsentMessage),
+		RequireConsent:   config.DigitalSambaRequireRecordingConsent,
+	}
+
+	if userConfig, err := p.getUserConfig(userID); err == nil && userConfig.NamingScheme != "" {
+		settings.NamingScheme = userConfig.NamingScheme
+	}
+
+	if channel == nil {
+		return settings
+	}
+
+	if channel.Type == model.ChannelTypePrivate {
+		settings.GuestAccess = false
+	}
+
+	channelSettings, err := p.getChannelSettings(channel.Id)
+	if err != nil {
+		p.API.LogWarn("Failed to load channel settings", "channel_id", channel.Id, "error", err.Error())
+		return settings
+	}
+
+	if channelSettings.NamingScheme != "" {
+		settings.NamingScheme = channelSettings.NamingScheme
+	}
+	if channelSettings.TemplateID != "" {
+		settings.TemplateID = channelSettings.TemplateID
+	}
+	if channelSettings.Recording != nil {
+		settings.RecordingEnabled = *channelSettings.Recording
+	}
+	if channelSettings.MaxParticipants > 0 && channelSettings.MaxParticipants < settings.MaxParticipants {
+		settings.MaxParticipants = channelSettings.MaxParticipants
+	}
+	if channelSettings.GuestAccess != nil {
+		settings.GuestAccess = *channelSettings.GuestAccess
+	}
+	if channelSettings.PersistentRoom != nil {
+		settings.PersistentRoom = *channelSettings.PersistentRoom
+	}
+	if channelSettings.RecordingConsent != nil {
+		settings.RequireConsent = *channelSettings.RecordingConsent
+	}
+	if channelSettings.ConsentMessage != "" {
+		settings.ConsentMessage = channelSettings.ConsentMessage
+	}
+	if settings.TemplateID != "" && slices.Contains(config.GetPrivateTemplates(), settings.TemplateID) {
+		settings.GuestAccess = false
+	}
+
+	return settings
+}
+
+// canManageChannelSettings reports whether the user is a channel admin of the
+// channel or a system admin.
+func (p *Plugin) canManageChannelSettings(userID string, channel *model.Channel) bool {
+	if p.API.HasPermissionTo(userID, model.PermissionManageSystem) {
+		return true
+	}
+
+	if channel.Type == model.ChannelTypeDirect || channel.Type == model.ChannelTypeGroup {
+		return false
+	}
+
+	member, appErr := p.API.GetChannelMember(channel.Id, userID)
+	if appErr != nil {
+		return false
+	}
+
+	return member.SchemeAdmin
+}
+
+// updateChannelSetting applies a single "setting value" pair from the slash
+// command. The value "default" clears the setting. Only the consent message
+// may contain spaces.
+func updateChannelSetting(settings *ChannelSettings, setting, value string) error {
+	reset := value == "default"
+
+	parseBool := func() (*bool, error) {
+		if reset {
+			return nil, nil
+		}
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s value. Use 'true', 'false' or 'default'", setting)
+		}
+		return &b, nil
+	}
+
+	switch setting {
+	case "naming_scheme":
+		if reset {
+			settings.NamingScheme = ""
+			return nil
+		}
+		if !isValidNamingScheme(value) {
+			return fmt.Errorf("invalid naming scheme. Valid values are: %s, default", strings.Join(validNamingSchemes, ", "))
+		}
+		settings.NamingScheme = value
+	case "template":
+		if reset {
+			settings.TemplateID = ""
+			return nil
+		}
+		settings.TemplateID = value
+	case "recording":
+		b, err := parseBool()
+		if err != nil {
+			return err
+		}
+		settings.Recording = b
+	case "max_participants":
+		if reset {
+			settings.MaxParticipants = 0
+			return nil
+		}
+		n, convErr := strconv.Atoi(value)
+		if convErr != nil || n < 1 || n > 2000 {
+			return fmt.Errorf("maximum participants must be a number between 1 and 2000")
+		}
+		settings.MaxParticipants = n
+	case "guest_access":
+		b, err := parseBool()
+		if err != nil {
+			return err
+		}
+		settings.GuestAccess = b
+	case "persistent_room":
+		b, err := parseBool()
+		if err != nil {
+			return err
+		}
+		settings.PersistentRoom = b
+	case "transcripts":
+		b, err := parseBool()
+		if err != nil {
+			return err
+		}
+		settings.Transcripts = b
+	case "recording_consent":
+		b, err := parseBool()
+		if err != nil {
+			return err
+		}
+		settings.RecordingConsent = b
+	case "consent_message":
+		if reset {
+			settings.ConsentMessage = ""
+			return nil
+		}
+		if len(value) > maxConsentMessageLength {
+			return fmt.Errorf("the consent message must not be longer than %d characters", maxConsentMessageLength)
+		}
+		settings.ConsentMessage = value
+	default:
+		return fmt.Errorf("invalid setting. Valid settings are: naming_scheme, template, recording, max_participants, guest_access, persistent_room, transcripts, recording_consent, consent_message")
+	}
+
+	return nil
+}
+
+func formatOptionalBool(b *bool) string {
+	if b == nil {
+		return "default"
+	}
+	return strconv.FormatBool(*b)
+}
+
+func formatOptionalString(s string) string {
+	if s == "" {
+		return "default"
+	}
+	return s
+}
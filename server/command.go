@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 
 	"github.com/mattermost/mattermost/server/public/model"
@@ -11,13 +12,36 @@ import (
 
 const commandHelp = `* |/digitalsamba| - Start a meeting with a random name
 * |/digitalsamba [topic]| - Start a meeting with specified topic
+* |/digitalsamba start --passcode <code> [topic]| - Start a meeting that guests outside the channel join with a passcode
+* |/digitalsamba call @user1 [@user2 ...] [topic]| - Call people in a direct or group message
+* |/digitalsamba me [topic]| - Start a meeting in your personal room
+* |/digitalsamba me link| - Show the stable link of your personal room
+* |/digitalsamba me rotate| - Replace your personal room's link, for example when it leaked
+* |/digitalsamba me settings [setting] [value]| - View or update your personal room settings
+  * |setting| can be "lobby" ("true", "false"), "recording" ("true", "false", "default") or "passcode" (a passcode, "off")
+* |/digitalsamba breakout <number> [random or @user1 @user2 ...]| - Create breakout rooms for the running meeting and post their links in its thread
+  * |random| spreads the channel members over the rooms; listed users are spread in order, "|" separates the users of each room
+* |/digitalsamba stream start [target]| - Stream the running meeting live to a stream target configured by the system admin
+* |/digitalsamba stream stop| - Stop the live stream of the running meeting
+* |/digitalsamba record start| - Record the running meeting, when recording is enabled for it
+* |/digitalsamba record stop| - Stop recording the running meeting
 * |/digitalsamba settings| - View your current settings
 * |/digitalsamba settings [setting] [value]| - Update your settings
   * |setting| can be "naming_scheme" or "embed"
-  * |naming_scheme| values: "words", "uuid", "mattermost", "ask"
+  * |naming_scheme| values: "words", "uuid", "mattermost", "template", "ask"
   * |embed| values: "true", "false"
+* |/digitalsamba settings naming_scheme| - Preview the meeting names of each naming scheme in this channel
+* |/digitalsamba channel-settings| - View the meeting defaults of the current channel
+* |/digitalsamba channel-settings [setting] [value]| - Update the channel's meeting defaults (channel admins only)
+  * |setting| can be "naming_scheme", "template", "recording", "max_participants", "guest_access", "persistent_room", "transcripts", "recording_consent" or "consent_message"
+  * |value| "default" removes the channel override
+* |/digitalsamba admin test| - Check the connection to DigitalSamba (system admins only)
+* |/digitalsamba admin webhooks| - Show failed outgoing webhook deliveries (system admins only)
+* |/digitalsamba admin audit [days] [jsonl|csv]| - Export the audit trail (system admins only)
 * |/digitalsamba help| - Show this help text`
 
+const adminCommandUsage = "Invalid admin command. Use `/digitalsamba admin test`, `/digitalsamba admin webhooks` or `/digitalsamba admin audit [days] [jsonl|csv]`."
+
 func (p *Plugin) createDigitalSambaCommand() (*model.Command, error) {
 	iconData := ""
 
@@ -26,7 +50,7 @@ func (p *Plugin) createDigitalSambaCommand() (*model.Command, error) {
 		DisplayName:          "DigitalSamba",
 		Description:          "Start and manage DigitalSamba meetings",
 		AutoComplete:         true,
-		AutoCompleteDesc:     "Available commands: start, settings, help",
+		AutoCompleteDesc:     "Available commands: start, call, me, breakout, stream, record, settings, channel-settings, help",
 		AutoCompleteHint:     "[command]",
 		AutocompleteData:     getAutocompleteData(),
 		AutocompleteIconData: iconData,
@@ -34,12 +58,44 @@ func (p *Plugin) createDigitalSambaCommand() (*model.Command, error) {
 }
 
 func getAutocompleteData() *model.AutocompleteData {
-	command := model.NewAutocompleteData("digitalsamba", "[command]", "Available commands: start, settings, help")
+	command := model.NewAutocompleteData("digitalsamba", "[command]", "Available commands: start, call, me, breakout, stream, record, settings, channel-settings, help")
 
-	start := model.NewAutocompleteData("start", "[topic]", "Start a meeting")
-	start.AddTextArgument("Topic of the meeting", "[topic]", "")
+	start := model.NewAutocompleteData("start", "[--passcode <code>] [topic]", "Start a meeting")
+	start.AddTextArgument("Topic of the meeting, optionally after a passcode for guests", "[--passcode <code>] [topic]", "")
 	command.AddCommand(start)
 
+	call := model.NewAutocompleteData("call", "@user1 [@user2 ...] [topic]", "Call people in a direct or group message")
+	call.AddTextArgument("Users to call, followed by an optional topic", "@user1 [@user2 ...] [topic]", "")
+	command.AddCommand(call)
+
+	breakout := model.NewAutocompleteData("breakout", "<number> [random | @user1 @user2 ...]", "Create breakout rooms for the running meeting")
+	breakout.AddTextArgument("Number of rooms, then random or the users to send to them", "<number> [random | @user1 @user2 ...]", "")
+	command.AddCommand(breakout)
+
+	stream := model.NewAutocompleteData("stream", "[start|stop]", "Stream the running meeting live")
+	streamStart := model.NewAutocompleteData("start", "[target]", "Start streaming to a stream target")
+	streamStart.AddTextArgument("Name of the stream target, needed when there are several", "[target]", "")
+	stream.AddCommand(streamStart)
+	stream.AddCommand(model.NewAutocompleteData("stop", "", "Stop the live stream"))
+	command.AddCommand(stream)
+
+	record := model.NewAutocompleteData("record", "[start|stop]", "Record the running meeting")
+	record.AddCommand(model.NewAutocompleteData("start", "", "Start recording the meeting"))
+	record.AddCommand(model.NewAutocompleteData("stop", "", "Stop recording the meeting"))
+	command.AddCommand(record)
+
+	me := model.NewAutocompleteData("me", "[topic]", "Start a meeting in your personal room")
+	me.AddCommand(model.NewAutocompleteData("link", "", "Show the link of your personal room"))
+	me.AddCommand(model.NewAutocompleteData("rotate", "", "Replace the link of your personal room"))
+	meSettings := model.NewAutocompleteData("settings", "[setting] [value]", "View or update your personal room settings")
+	meSettings.AddStaticListArgument("setting", false, []model.AutocompleteListItem{
+		{Item: "lobby", HelpText: "Only let people with a Mattermost token in"},
+		{Item: "recording", HelpText: "Allow or forbid recording in your personal room"},
+		{Item: "passcode", HelpText: "Set the passcode guests enter, or turn it off"},
+	})
+	me.AddCommand(meSettings)
+	command.AddCommand(me)
+
 	settings := model.NewAutocompleteData("settings", "[setting] [value]", "Update your personal settings")
 	settings.AddStaticListArgument("setting", true, []model.AutocompleteListItem{
 		{Item: "naming_scheme", HelpText: "Set the naming scheme for meetings"},
@@ -47,6 +103,30 @@ func getAutocompleteData() *model.AutocompleteData {
 	})
 	command.AddCommand(settings)
 
+	channelSettings := model.NewAutocompleteData("channel-settings", "[setting] [value]", "Update the meeting defaults of this channel")
+	channelSettings.AddStaticListArgument("setting", false, []model.AutocompleteListItem{
+		{Item: "naming_scheme", HelpText: "Set the naming scheme for meetings in this channel"},
+		{Item: "template", HelpText: "Set the DigitalSamba room template for this channel"},
+		{Item: "recording", HelpText: "Allow or forbid recording in this channel"},
+		{Item: "max_participants", HelpText: "Limit the number of participants"},
+		{Item: "guest_access", HelpText: "Allow people without a Mattermost token to join"},
+		{Item: "persistent_room", HelpText: "Reuse the same room for every meeting in this channel"},
+		{Item: "transcripts", HelpText: "Post meeting transcripts in this channel"},
+		{Item: "recording_consent", HelpText: "Require users to consent to recording before they join"},
+		{Item: "consent_message", HelpText: "Set the recording consent message of this channel"},
+	})
+	command.AddCommand(channelSettings)
+
+	admin := model.NewAutocompleteData("admin", "[command]", "Administer the DigitalSamba plugin")
+	admin.RoleID = model.SystemAdminRoleId
+	adminTest := model.NewAutocompleteData("test", "", "Check API reachability, latency and quota")
+	admin.AddCommand(adminTest)
+	adminWebhooks := model.NewAutocompleteData("webhooks", "", "Show failed outgoing webhook deliveries")
+	admin.AddCommand(adminWebhooks)
+	adminAudit := model.NewAutocompleteData("audit", "[days] [jsonl|csv]", "Export the audit trail")
+	admin.AddCommand(adminAudit)
+	command.AddCommand(admin)
+
 	help := model.NewAutocompleteData("help", "", "Display usage information")
 	command.AddCommand(help)
 
@@ -63,7 +143,7 @@ func (p *Plugin) ExecuteCommand(c *plugin.Context, args *model.CommandArgs) (*mo
 
 	if len(fields) == 1 {
 		// Just "/digitalsamba" - start a meeting
-		return p.runStartMeetingCommand(args, "")
+		return p.runStartMeetingCommand(args, "", "")
 	}
 
 	subcommand := fields[1]
@@ -75,20 +155,43 @@ func (p *Plugin) ExecuteCommand(c *plugin.Context, args *model.CommandArgs) (*mo
 		if len(fields) == 2 {
 			return p.runShowSettingsCommand(args)
 		}
+		if len(fields) == 3 && fields[2] == "naming_scheme" {
+			return p.runPreviewNamingSchemesCommand(args)
+		}
 		if len(fields) >= 4 {
 			return p.runUpdateSettingsCommand(args, fields[2], strings.Join(fields[3:], " "))
 		}
 		return p.sendEphemeralResponse(args, "Invalid settings command. Use `/digitalsamba settings` to view or `/digitalsamba settings [setting] [value]` to update.")
+	case "channel-settings":
+		if len(fields) == 2 {
+			return p.runShowChannelSettingsCommand(args)
+		}
+		if len(fields) == 4 || (len(fields) > 4 && fields[2] == "consent_message") {
+			return p.runUpdateChannelSettingsCommand(args, fields[2], strings.Join(fields[3:], " "))
+		}
+		return p.sendEphemeralResponse(args, "Invalid channel-settings command. Use `/digitalsamba channel-settings` to view or `/digitalsamba channel-settings [setting] [value]` to update.")
+	case "admin":
+		return p.runAdminCommand(args, fields[2:])
+	case "call":
+		return p.runCallCommand(args, fields[2:])
+	case "me":
+		return p.runPersonalRoomCommand(args, fields[2:])
+	case "breakout":
+		return p.runBreakoutCommand(args, fields[2:])
+	case "stream":
+		return p.runStreamCommand(args, fields[2:])
+	case "record":
+		return p.runRecordCommand(args, fields[2:])
 	case "start":
-		topic := ""
-		if len(fields) > 2 {
-			topic = strings.Join(fields[2:], " ")
+		topic, passcode, err := parseStartMeetingFlags(fields[2:])
+		if err != nil {
+			return p.sendEphemeralResponse(args, err.Error())
 		}
-		return p.runStartMeetingCommand(args, topic)
+		return p.runStartMeetingCommand(args, topic, passcode)
 	default:
 		// Treat everything else as a meeting topic
 		topic := strings.Join(fields[1:], " ")
-		return p.runStartMeetingCommand(args, topic)
+		return p.runStartMeetingCommand(args, topic, "")
 	}
 }
 
@@ -129,18 +232,10 @@ func (p *Plugin) runUpdateSettingsCommand(args *model.CommandArgs, setting, valu
 
 	switch setting {
 	case "naming_scheme":
-		validSchemes := []string{"words", "uuid", "mattermost", "ask"}
-		valid := false
-		for _, scheme := range validSchemes {
-			if value == scheme {
-				valid = true
-				userConfig.NamingScheme = value
-				break
-			}
-		}
-		if !valid {
-			return p.sendEphemeralResponse(args, fmt.Sprintf("Invalid naming scheme. Valid values are: %s", strings.Join(validSchemes, ", ")))
+		if !isValidNamingScheme(value) {
+			return p.sendEphemeralResponse(args, fmt.Sprintf("Invalid naming scheme. Valid values are: %s", strings.Join(validNamingSchemes, ", ")))
 		}
+		userConfig.NamingScheme = value
 	case "embed":
 		if value == "true" {
 			userConfig.Embedded = true
@@ -160,24 +255,140 @@ func (p *Plugin) runUpdateSettingsCommand(args *model.CommandArgs, setting, valu
 	return p.sendEphemeralResponse(args, "Settings updated successfully")
 }
 
-func (p *Plugin) runStartMeetingCommand(args *model.CommandArgs, topic string) (*model.CommandResponse, *model.AppError) {
-	user, appErr := p.API.GetUser(args.UserId)
-	if appErr != nil {
-		return p.sendEphemeralResponse(args, "Failed to get user information")
+func (p *Plugin) runShowChannelSettingsCommand(args *model.CommandArgs) (*model.CommandResponse, *model.AppError) {
+	channelSettings, err := p.getChannelSettings(args.ChannelId)
+	if err != nil {
+		return p.sendEphemeralResponse(args, "Failed to get channel settings")
 	}
 
+	maxParticipants := "default"
+	if channelSettings.MaxParticipants > 0 {
+		maxParticipants = strconv.Itoa(channelSettings.MaxParticipants)
+	}
+
+	l := p.b.GetUserLocalizer(args.UserId)
+	message := p.b.LocalizeWithConfig(l, &i18n.LocalizeConfig{
+		DefaultMessage: &i18n.Message{
+			ID: "digitalsamba.command.channel_settings.current",
+			Other: `Channel DigitalSamba Settings:
+* Naming Scheme: {{.NamingScheme}}
+* Room Template: {{.Template}}
+* Recording: {{.Recording}}
+* Max Participants: {{.MaxParticipants}}
+* Guest Access: {{.GuestAccess}}
+* Persistent Room: {{.PersistentRoom}}
+* Transcripts: {{.Transcripts}}
+* Recording Consent: {{.RecordingConsent}}
+* Consent Message: {{.ConsentMessage}}
+
+Settings marked "default" use the user's settings or the server configuration.`,
+		},
+		TemplateData: map[string]string{
+			"NamingScheme":     formatOptionalString(channelSettings.NamingScheme),
+			"Template":         formatOptionalString(channelSettings.TemplateID),
+			"Recording":        formatOptionalBool(channelSettings.Recording),
+			"MaxParticipants":  maxParticipants,
+			"GuestAccess":      formatOptionalBool(channelSettings.GuestAccess),
+			"PersistentRoom":   formatOptionalBool(channelSettings.PersistentRoom),
+			"Transcripts":      formatOptionalBool(channelSettings.Transcripts),
+			"RecordingConsent": formatOptionalBool(channelSettings.RecordingConsent),
+			"ConsentMessage":   formatOptionalString(channelSettings.ConsentMessage),
+		},
+	})
+
+	return p.sendEphemeralResponse(args, message)
+}
+
+func (p *Plugin) runUpdateChannelSettingsCommand(args *model.CommandArgs, setting, value string) (*model.CommandResponse, *model.AppError) {
 	channel, appErr := p.API.GetChannel(args.ChannelId)
 	if appErr != nil {
 		return p.sendEphemeralResponse(args, "Failed to get channel information")
 	}
 
-	if topic == "" {
-		userConfig, err := p.getUserConfig(args.UserId)
+	if !p.canManageChannelSettings(args.UserId, channel) {
+		return p.sendEphemeralResponse(args, "Only channel admins can change the channel settings")
+	}
+
+	channelSettings, err := p.getChannelSettings(channel.Id)
+	if err != nil {
+		return p.sendEphemeralResponse(args, "Failed to get channel settings")
+	}
+
+	if err := updateChannelSetting(channelSettings, setting, value); err != nil {
+		return p.sendEphemeralResponse(args, err.Error())
+	}
+
+	if err := p.setChannelSettings(channel.Id, channelSettings); err != nil {
+		return p.sendEphemeralResponse(args, "Failed to update channel settings")
+	}
+
+	p.audit(auditActionChannelSettingUpdate, args.UserId, channel.Id, "", map[string]string{"setting": setting, "value": value})
+
+	return p.sendEphemeralResponse(args, "Channel settings updated successfully")
+}
+
+func (p *Plugin) runAdminCommand(args *model.CommandArgs, fields []string) (*model.CommandResponse, *model.AppError) {
+	if !p.API.HasPermissionTo(args.UserId, model.PermissionManageSystem) {
+		return p.sendEphemeralResponse(args, "Only system admins can use admin commands")
+	}
+
+	if len(fields) == 0 {
+		return p.sendEphemeralResponse(args, adminCommandUsage)
+	}
+
+	switch fields[0] {
+	case "test":
+		return p.sendEphemeralResponse(args, p.formatAccountTest())
+	case "audit":
+		return p.runAuditCommand(args, fields[1:])
+	case "webhooks":
+		deadLetters, err := p.getWebhookDeadLetters()
 		if err != nil {
-			return p.sendEphemeralResponse(args, "Failed to get user settings")
+			return p.sendEphemeralResponse(args, "Failed to get failed webhook deliveries")
+		}
+		return p.sendEphemeralResponse(args, formatWebhookDeadLetters(deadLetters))
+	default:
+		return p.sendEphemeralResponse(args, adminCommandUsage)
+	}
+}
+
+// parseStartMeetingFlags splits the arguments of `/digitalsamba start` into
+// the topic and the optional `--passcode <code>` flag.
+func parseStartMeetingFlags(fields []string) (string, string, error) {
+	var topicFields []string
+	passcode := ""
+	for i := 0; i < len(fields); i++ {
+		if fields[i] != "--passcode" {
+			topicFields = append(topicFields, fields[i])
+			continue
+		}
+		if i+1 >= len(fields) {
+			return "", "", fmt.Errorf("missing passcode. Use `/digitalsamba start --passcode <code> [topic]`")
+		}
+		i++
+		passcode = fields[i]
+		if err := validatePasscode(passcode); err != nil {
+			return "", "", fmt.Errorf("invalid passcode: %v", err)
 		}
+	}
+	return strings.Join(topicFields, " "), passcode, nil
+}
+
+func (p *Plugin) runStartMeetingCommand(args *model.CommandArgs, topic string, passcode string) (*model.CommandResponse, *model.AppError) {
+	user, appErr := p.API.GetUser(args.UserId)
+	if appErr != nil {
+		return p.sendEphemeralResponse(args, "Failed to get user information")
+	}
+
+	channel, appErr := p.API.GetChannel(args.ChannelId)
+	if appErr != nil {
+		return p.sendEphemeralResponse(args, "Failed to get channel information")
+	}
 
-		if userConfig.NamingScheme == digitalSambaNameSchemeAsk {
+	// The meeting type buttons cannot carry a passcode
+	if topic == "" && passcode == "" {
+		settings := p.resolveMeetingSettings(args.UserId, channel)
+		if settings.NamingScheme == digitalSambaNameSchemeAsk {
 			if err := p.askMeetingType(user, channel, args.RootId); err != nil {
 				return p.sendEphemeralResponse(args, "Failed to display meeting options")
 			}
@@ -185,13 +396,13 @@ func (p *Plugin) runStartMeetingCommand(args *model.CommandArgs, topic string) (
 		}
 	}
 
-	meetingID, err := p.startMeeting(user, channel, "", topic, false, args.RootId)
+	meetingInfo, err := p.startMeeting(user, channel, "", topic, false, args.RootId, passcode)
 	if err != nil {
 		return p.sendEphemeralResponse(args, fmt.Sprintf("Failed to start meeting: %v", err))
 	}
 
 	return &model.CommandResponse{
-		Text: fmt.Sprintf("Meeting started: %s", meetingID),
+		Text: fmt.Sprintf("Meeting started: %s", meetingInfo.MeetingID),
 	}, nil
 }
 
@@ -221,6 +432,18 @@ func (p *Plugin) trackCommand(args *model.CommandArgs) {
 		event = "help_command"
 	case "settings":
 		event = "settings_command"
+	case "channel-settings":
+		event = "channel_settings_command"
+	case "admin":
+		event = "admin_command"
+	case "call":
+		event = "call_command"
+	case "breakout":
+		event = "breakout_command"
+	case "stream":
+		event = "stream_command"
+	case "record":
+		event = "record_command"
 	default:
 		event = "start_meeting_command"
 	}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseStartMeetingFlags(t *testing.T) {
+	tests := []struct {
+		name         string
+		fields       []string
+		wantTopic    string
+		wantPasscode string
+		wantErr      string
+	}{
+		{name: "no arguments"},
+		{name: "topic only", fields: []string{"Weekly", "sync"}, wantTopic: "Weekly sync"},
+		{name: "passcode only", fields: []string{"--passcode", "1234"}, wantPasscode: "1234"},
+		{
+			name:         "passcode before the topic",
+			fields:       []string{"--passcode", "s3cret", "Weekly", "sync"},
+			wantTopic:    "Weekly sync",
+			wantPasscode: "s3cret",
+		},
+		{
+			name:         "passcode inside the topic",
+			fields:       []string{"Weekly", "--passcode", "s3cret", "sync"},
+			wantTopic:    "Weekly sync",
+			wantPasscode: "s3cret",
+		},
+		{
+			name:         "last passcode wins",
+			fields:       []string{"--passcode", "1111", "--passcode", "2222"},
+			wantPasscode: "2222",
+		},
+		{name: "missing passcode", fields: []string{"Weekly", "--passcode"}, wantErr: "missing passcode"},
+		{name: "passcode too short", fields: []string{"--passcode", "123"}, wantErr: "invalid passcode"},
+		{name: "passcode too long", fields: []string{"--passcode", strings.Repeat("1", 33)}, wantErr: "invalid passcode"},
+		{
+			// Flags are only recognized as whole words
+			name:      "flag lookalike",
+			fields:    []string{"--passcode=1234", "sync"},
+			wantTopic: "--passcode=1234 sync",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			topic, passcode, err := parseStartMeetingFlags(tt.fields)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("parseStartMeetingFlags(%q) error = %v, want an error containing %q", tt.fields, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseStartMeetingFlags(%q) error = %v", tt.fields, err)
+			}
+			if topic != tt.wantTopic || passcode != tt.wantPasscode {
+				t.Errorf("parseStartMeetingFlags(%q) = %q, %q, want %q, %q", tt.fields, topic, passcode, tt.wantTopic, tt.wantPasscode)
+			}
+		})
+	}
+}
@@ -1,21 +1,42 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
 	"strings"
+
+	"github.com/mattermost/mattermost/server/public/model"
 )
 
 type configuration struct {
-	DigitalSambaAPIKey          string
-	DigitalSambaDashboardURL    string
-	DigitalSambaTeamName        string
-	DigitalSambaEmbedded        bool
-	DigitalSambaShowPrejoinPage bool
-	DigitalSambaNamingScheme    string
-	DigitalSambaRoomExpiry      int
-	DigitalSambaMaxParticipants int
-	DigitalSambaEnableRecording bool
-	DigitalSambaEnableBreakoutRooms bool
+	DigitalSambaAPIKey                  string
+	DigitalSambaDashboardURL            string
+	DigitalSambaTeamName                string
+	DigitalSambaCustomDomain            string
+	DigitalSambaEmbedded                bool
+	DigitalSambaShowPrejoinPage         bool
+	DigitalSambaNamingScheme            string
+	DigitalSambaNamingTemplate          string
+	DigitalSambaWordPattern             string
+	DigitalSambaWordLists               string
+	DigitalSambaWordDenylist            string
+	DigitalSambaRoomExpiry              int
+	DigitalSambaMaxParticipants         int
+	DigitalSambaEnableRecording         bool
+	DigitalSambaRecordingConsentMessage string
+	DigitalSambaRequireRecordingConsent bool
+	DigitalSambaEnableBreakoutRooms     bool
+	DigitalSambaPrivateTemplates        string
+	DigitalSambaPasscodeTemplates       string
+	DigitalSambaTeamAccounts            string
+	DigitalSambaStreamTargets           string
+	DigitalSambaOutgoingWebhooks        string
+	DigitalSambaWebhookSecret           string
+	DigitalSambaIncomingWebhookSecret   string
+	DigitalSambaCallTimeout             int
+	DigitalSambaExportMeetingContent    bool
+	DigitalSambaTranscriptRetentionDays int
+	DigitalSambaAuditRetentionDays      int
 }
 
 func (c *configuration) IsValid() error {
@@ -33,33 +54,242 @@ func (c *configuration) IsValid() error {
 		return fmt.Errorf("DigitalSamba Dashboard URL must start with http:// or https://")
 	}
 
+	// Validate meeting URL settings
+	if err := validateMeetingURLSettings(c.DigitalSambaTeamName, c.DigitalSambaCustomDomain); err != nil {
+		return err
+	}
+
 	// Validate room expiry
 	if c.DigitalSambaRoomExpiry < 0 {
 		return fmt.Errorf("room expiry time cannot be negative")
 	}
 
+	// Validate call timeout
+	if c.DigitalSambaCallTimeout < 0 {
+		return fmt.Errorf("call timeout cannot be negative")
+	}
+
+	// Validate transcript retention
+	if c.DigitalSambaTranscriptRetentionDays < 0 {
+		return fmt.Errorf("transcript retention cannot be negative")
+	}
+
+	// Validate audit retention
+	if c.DigitalSambaAuditRetentionDays < 0 {
+		return fmt.Errorf("audit retention cannot be negative")
+	}
+
 	// Validate max participants
 	if c.DigitalSambaMaxParticipants < 1 || c.DigitalSambaMaxParticipants > 2000 {
 		return fmt.Errorf("maximum participants must be between 1 and 2000")
 	}
 
 	// Validate naming scheme
-	validSchemes := []string{"words", "uuid", "mattermost", "ask"}
-	valid := false
-	for _, scheme := range validSchemes {
-		if c.DigitalSambaNamingScheme == scheme {
-			valid = true
-			break
+	if !isValidNamingScheme(c.DigitalSambaNamingScheme) {
+		return fmt.Errorf("invalid naming scheme: %s", c.DigitalSambaNamingScheme)
+	}
+
+	// Validate random word names
+	if _, ok := wordPatterns[c.GetWordPatternName()]; !ok {
+		return fmt.Errorf("invalid word pattern: %s", c.DigitalSambaWordPattern)
+	}
+	if _, err := c.GetWordLists(); err != nil {
+		return err
+	}
+
+	// Validate naming template
+	if err := validateNamingTemplate(c.GetNamingTemplate()); err != nil {
+		return err
+	}
+
+	// Validate team accounts
+	teamAccounts, err := c.GetTeamAccounts()
+	if err != nil {
+		return err
+	}
+	for teamID, account := range teamAccounts {
+		if account.APIKey == "" {
+			return fmt.Errorf("DigitalSamba API Key is required for team %s", teamID)
+		}
+		accountURL := strings.TrimSpace(account.DashboardURL)
+		if accountURL != "" && !strings.HasPrefix(accountURL, "http://") && !strings.HasPrefix(accountURL, "https://") {
+			return fmt.Errorf("DigitalSamba Dashboard URL for team %s must start with http:// or https://", teamID)
+		}
+		if err := validateMeetingURLSettings(account.TeamName, account.CustomDomain); err != nil {
+			return fmt.Errorf("team %s: %w", teamID, err)
 		}
 	}
-	if !valid {
-		return fmt.Errorf("invalid naming scheme: %s", c.DigitalSambaNamingScheme)
+
+	// Validate stream targets
+	streamTargets, err := c.GetStreamTargets()
+	if err != nil {
+		return err
+	}
+	for name, target := range streamTargets {
+		if strings.TrimSpace(name) == "" || strings.ContainsAny(name, " \t\n") {
+			return fmt.Errorf("stream target name %q must be a single word", name)
+		}
+		if !strings.HasPrefix(target.URL, "rtmp://") && !strings.HasPrefix(target.URL, "rtmps://") {
+			return fmt.Errorf("stream target %s URL must start with rtmp:// or rtmps://", name)
+		}
+		for _, channelID := range target.Channels {
+			if !model.IsValidId(channelID) {
+				return fmt.Errorf("stream target %s has an invalid channel ID %q", name, channelID)
+			}
+		}
+	}
+
+	// Validate outgoing webhooks
+	for _, webhookURL := range c.GetOutgoingWebhookURLs() {
+		if !strings.HasPrefix(webhookURL, "http://") && !strings.HasPrefix(webhookURL, "https://") {
+			return fmt.Errorf("outgoing webhook URL %q must start with http:// or https://", webhookURL)
+		}
 	}
 
 	return nil
 }
 
+// GetNamingTemplate returns the template of the "template" naming scheme.
+func (c *configuration) GetNamingTemplate() string {
+	if strings.TrimSpace(c.DigitalSambaNamingTemplate) == "" {
+		return defaultNamingTemplate
+	}
+	return strings.TrimSpace(c.DigitalSambaNamingTemplate)
+}
+
+// GetWordPatternName returns the configured order of random name words.
+func (c *configuration) GetWordPatternName() string {
+	if c.DigitalSambaWordPattern == "" {
+		return defaultWordPattern
+	}
+	return c.DigitalSambaWordPattern
+}
+
+// GetWordPattern returns the word parts of random names.
+func (c *configuration) GetWordPattern() []string {
+	if pattern, ok := wordPatterns[c.GetWordPatternName()]; ok {
+		return pattern
+	}
+	return wordPatterns[defaultWordPattern]
+}
+
+// GetWordLists parses the custom word lists setting, a JSON object mapping
+// language codes to word lists.
+func (c *configuration) GetWordLists() (map[string]*wordList, error) {
+	wordLists := map[string]*wordList{}
+	if strings.TrimSpace(c.DigitalSambaWordLists) == "" {
+		return wordLists, nil
+	}
+
+	if err := json.Unmarshal([]byte(c.DigitalSambaWordLists), &wordLists); err != nil {
+		return nil, fmt.Errorf("word lists must be a JSON object mapping languages to word lists: %w", err)
+	}
+
+	for language, list := range wordLists {
+		if list == nil {
+			return nil, fmt.Errorf("word list for %q is empty", language)
+		}
+		if err := list.validate(); err != nil {
+			return nil, fmt.Errorf("word list for %q: %w", language, err)
+		}
+	}
+
+	return wordLists, nil
+}
+
+// defaultWordDenylist keeps offensive words out of random names when the
+// setting is empty. It matches the default of the setting. Words that are
+// common inside harmless words, like "ass" in "Class", are left out, since
+// a match anywhere in a name rejects it.
+const defaultWordDenylist = "fuck, shit, cunt, bitch, bastard, whore, slut, piss, dick, cock, prick, pussy, twat, wank, dildo, penis, porn, jizz, tits, boob, bollocks, damn, rapist, nazi, hitler, nigger, nigga, faggot, retard, kike"
+
+// GetWordDenylist returns the lower case words that random names must not
+// contain, separated by commas or new lines in the setting. An empty setting
+// uses defaultWordDenylist.
+func (c *configuration) GetWordDenylist() []string {
+	setting := c.DigitalSambaWordDenylist
+	if strings.TrimSpace(setting) == "" {
+		setting = defaultWordDenylist
+	}
+
+	var words []string
+	for _, word := range strings.FieldsFunc(setting, func(r rune) bool { return r == ',' || r == '\n' }) {
+		if word = strings.ToLower(strings.TrimSpace(word)); word != "" {
+			words = append(words, word)
+		}
+	}
+	return words
+}
+
+// GetPrivateTemplates returns the template IDs whose rooms are always
+// private.
+func (c *configuration) GetPrivateTemplates() []string {
+	return splitTemplateIDs(c.DigitalSambaPrivateTemplates)
+}
+
+// GetPasscodeTemplates returns the template IDs whose meetings always need a
+// passcode.
+func (c *configuration) GetPasscodeTemplates() []string {
+	return splitTemplateIDs(c.DigitalSambaPasscodeTemplates)
+}
+
+func splitTemplateIDs(value string) []string {
+	var templateIDs []string
+	for _, templateID := range strings.Split(value, ",") {
+		if templateID = strings.TrimSpace(templateID); templateID != "" {
+			templateIDs = append(templateIDs, templateID)
+		}
+	}
+	return templateIDs
+}
+
 func (c *configuration) GetDashboardURL() string {
-	url := strings.TrimSpace(c.DigitalSambaDashboardURL)
+	return normalizeDashboardURL(c.DigitalSambaDashboardURL)
+}
+
+// GetTeamAccounts parses the team accounts setting, a JSON object mapping
+// Mattermost team IDs to DigitalSamba credentials.
+func (c *configuration) GetTeamAccounts() (map[string]TeamAccount, error) {
+	teamAccounts := map[string]TeamAccount{}
+	if strings.TrimSpace(c.DigitalSambaTeamAccounts) == "" {
+		return teamAccounts, nil
+	}
+
+	if err := json.Unmarshal([]byte(c.DigitalSambaTeamAccounts), &teamAccounts); err != nil {
+		return nil, fmt.Errorf("team accounts must be a JSON object mapping team IDs to accounts: %w", err)
+	}
+
+	return teamAccounts, nil
+}
+
+// GetStreamTargets parses the stream targets setting, a JSON object mapping
+// target names to RTMP ingest URLs and stream keys.
+func (c *configuration) GetStreamTargets() (map[string]StreamTarget, error) {
+	streamTargets := map[string]StreamTarget{}
+	if strings.TrimSpace(c.DigitalSambaStreamTargets) == "" {
+		return streamTargets, nil
+	}
+
+	if err := json.Unmarshal([]byte(c.DigitalSambaStreamTargets), &streamTargets); err != nil {
+		return nil, fmt.Errorf("stream targets must be a JSON object mapping names to targets: %w", err)
+	}
+
+	return streamTargets, nil
+}
+
+func normalizeDashboardURL(dashboardURL string) string {
+	url := strings.TrimSpace(dashboardURL)
 	return strings.TrimRight(url, "/")
-}
\ No newline at end of file
+}
+
+// GetOutgoingWebhookURLs returns the outgoing webhook URLs, one per line in
+// the setting.
+func (c *configuration) GetOutgoingWebhookURLs() []string {
+	var urls []string
+	for _, line := range strings.Split(c.DigitalSambaOutgoingWebhooks, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			urls = append(urls, line)
+		}
+	}
+	return urls
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+const connectionStatusKey = "connection_status"
+
+// ConnectionStatus is the result of the last connectivity check of one
+// DigitalSamba account.
+type ConnectionStatus struct {
+	Account   string `json:"account"`
+	TeamName  string `json:"team_name"`
+	OK        bool   `json:"ok"`
+	Error     string `json:"error,omitempty"`
+	LatencyMS int64  `json:"latency_ms"`
+	RoomCount int    `json:"room_count"`
+	CheckedAt int64  `json:"checked_at"`
+}
+
+// checkAccount does a cheap authenticated call against the account and
+// measures how long it takes.
+func checkAccount(name string, account *digitalSambaAccount) *ConnectionStatus {
+	status := &ConnectionStatus{
+		Account:   name,
+		TeamName:  account.teamName,
+		CheckedAt: model.GetMillis(),
+	}
+
+	start := time.Now()
+	rooms, err := account.client.ListRooms(1)
+	status.LatencyMS = time.Since(start).Milliseconds()
+	if err != nil {
+		status.Error = err.Error()
+		return status
+	}
+
+	status.OK = true
+	status.RoomCount = rooms.TotalCount
+	return status
+}
+
+// checkConnectivity checks every configured account, stores the results for
+// the System Console and notifies system admins when an account starts
+// failing or its error changes.
+func (p *Plugin) checkConnectivity() {
+	previous, err := p.getConnectionStatuses()
+	if err != nil {
+		p.API.LogWarn("Failed to load previous connection status", "error", err.Error())
+	}
+	previousByAccount := map[string]*ConnectionStatus{}
+	for _, status := range previous {
+		previousByAccount[status.Account] = status
+	}
+
+	accounts := p.listAccounts()
+	names := make([]string, 0, len(accounts))
+	for name := range accounts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	statuses := make([]*ConnectionStatus, 0, len(names))
+	var failures []string
+	for _, name := range names {
+		status := checkAccount(name, accounts[name])
+		statuses = append(statuses, status)
+
+		if status.OK {
+			continue
+		}
+
+		p.API.LogError("DigitalSamba connectivity check failed", "account", name, "error", status.Error)
+		if prev, ok := previousByAccount[name]; !ok || prev.OK || prev.Error != status.Error {
+			failures = append(failures, fmt.Sprintf("* **%s**: %s", name, status.Error))
+		}
+	}
+
+	b, err := json.Marshal(statuses)
+	if err == nil {
+		if appErr := p.API.KVSet(connectionStatusKey, b); appErr != nil {
+			p.API.LogWarn("Failed to store connection status", "error", appErr.Error())
+		}
+	}
+
+	if len(failures) > 0 {
+		p.notifySystemAdmins("The DigitalSamba plugin cannot reach the DigitalSamba API with the saved configuration. Check the API key and Dashboard URL in **System Console > Plugins > DigitalSamba**.\n\n" + strings.Join(failures, "\n"))
+	}
+}
+
+func (p *Plugin) getConnectionStatuses() ([]*ConnectionStatus, error) {
+	data, appErr := p.API.KVGet(connectionStatusKey)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	if data == nil {
+		return nil, nil
+	}
+
+	var statuses []*ConnectionStatus
+	if err := json.Unmarshal(data, &statuses); err != nil {
+		return nil, err
+	}
+
+	return statuses, nil
+}
+
+// notifySystemAdmins sends a direct message from the bot to every active
+// system admin.
+func (p *Plugin) notifySystemAdmins(message string) {
+	if p.botID == "" {
+		return
+	}
+
+	admins, appErr := p.API.GetUsers(&model.UserGetOptions{
+		Role:    model.SystemAdminRoleId,
+		Active:  true,
+		PerPage: 100,
+	})
+	if appErr != nil {
+		p.API.LogWarn("Failed to list system admins", "error", appErr.Error())
+		return
+	}
+
+	for _, admin := range admins {
+		p.sendDirectMessage(admin.Id, message)
+	}
+}
+
+func (p *Plugin) sendDirectMessage(userID, message string) {
+	channel, appErr := p.API.GetDirectChannel(userID, p.botID)
+	if appErr != nil {
+		p.API.LogWarn("Failed to get direct channel", "user_id", userID, "error", appErr.Error())
+		return
+	}
+
+	post := &model.Post{
+		UserId:    p.botID,
+		ChannelId: channel.Id,
+		Message:   message,
+	}
+	if _, appErr := p.API.CreatePost(post); appErr != nil {
+		p.API.LogWarn("Failed to send direct message", "user_id", userID, "error", appErr.Error())
+	}
+}
+
+// formatAccountTest runs a connectivity check against every account and
+// formats the result, including usage and quota figures, for /digitalsamba admin test.
+func (p *Plugin) formatAccountTest() string {
+	accounts := p.listAccounts()
+	names := make([]string, 0, len(accounts))
+	for name := range accounts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var sb strings.Builder
+	sb.WriteString("#### DigitalSamba connectivity\n")
+	for _, name := range names {
+		account := accounts[name]
+		status := checkAccount(name, account)
+
+		fmt.Fprintf(&sb, "\n**Account: %s**\n", name)
+		fmt.Fprintf(&sb, "* API URL: %s\n", account.dashboardURL)
+		fmt.Fprintf(&sb, "* Team name: %s\n", formatOptionalString(account.teamName))
+		if account.customDomain != "" {
+			fmt.Fprintf(&sb, "* Custom domain: %s\n", account.customDomain)
+		}
+		if !status.OK {
+			fmt.Fprintf(&sb, "* Status: :x: unreachable (%d ms)\n* Error: %s\n", status.LatencyMS, status.Error)
+			continue
+		}
+		fmt.Fprintf(&sb, "* Status: :white_check_mark: reachable (%d ms)\n", status.LatencyMS)
+		fmt.Fprintf(&sb, "* Rooms: %d\n", status.RoomCount)
+
+		stats, err := account.client.GetCurrentStatistics()
+		if err != nil {
+			fmt.Fprintf(&sb, "* Usage and quota: unavailable (%s)\n", err.Error())
+			continue
+		}
+		keys := make([]string, 0, len(stats))
+		for key := range stats {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			fmt.Fprintf(&sb, "* %s: %v\n", key, stats[key])
+		}
+	}
+
+	return sb.String()
+}
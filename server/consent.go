@@ -0,0 +1,198 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/pluginapi/i18n"
+)
+
+const recordingConsentKeyPrefix = "recording_consent_"
+
+// guestInviteKeyPrefix holds the invites of guests who still have to consent
+// to recording on the join page.
+const guestInviteKeyPrefix = "guest_invite_"
+
+// guestInviteTTL is how long a guest can use an invite to a meeting asking
+// for consent.
+const guestInviteTTL = 24 * time.Hour
+
+// maxConsentMessageLength bounds the consent message a channel admin sets.
+const maxConsentMessageLength = 1000
+
+var errGuestConsentRequired = errors.New("the guest must consent to recording")
+
+// RecordingConsent is a user's acknowledgment that a meeting may be
+// recorded. Persistent and personal rooms are reused by later meetings, so a
+// consent is given for the meeting started with a post.
+type RecordingConsent struct {
+	RoomID      string `json:"room_id"`
+	PostID      string `json:"post_id"`
+	UserID      string `json:"user_id"`
+	ConsentedAt int64  `json:"consented_at"`
+}
+
+// GuestInvite is an invite to a meeting asking for consent. The guest's
+// token is issued only once they consented on the join page.
+type GuestInvite struct {
+	RoomID    string `json:"room_id"`
+	Name      string `json:"name"`
+	CreatedBy string `json:"created_by"`
+}
+
+func recordingConsentKey(roomID, postID, userID string) string {
+	return recordingConsentKeyPrefix + roomID + "_" + postID + "_" + userID
+}
+
+// consentMeeting is the meeting a consent is given for. Consenting to a
+// meeting covers its breakout rooms, like admission by its lobby.
+func (p *Plugin) consentMeeting(record *MeetingRecord) *MeetingRecord {
+	return p.lobbyMeeting(record)
+}
+
+func (p *Plugin) hasRecordingConsent(userID string, record *MeetingRecord) (bool, error) {
+	meeting := p.consentMeeting(record)
+	data, appErr := p.API.KVGet(recordingConsentKey(meeting.RoomID, meeting.PostID, userID))
+	if appErr != nil {
+		return false, appErr
+	}
+	return data != nil, nil
+}
+
+// saveRecordingConsent stores the user's consent to the meeting and audits
+// it.
+func (p *Plugin) saveRecordingConsent(record *MeetingRecord, userID string) (*RecordingConsent, error) {
+	meeting := p.consentMeeting(record)
+	consent := &RecordingConsent{
+		RoomID:      meeting.RoomID,
+		PostID:      meeting.PostID,
+		UserID:      userID,
+		ConsentedAt: model.GetMillis(),
+	}
+
+	b, err := json.Marshal(consent)
+	if err != nil {
+		return nil, err
+	}
+	if appErr := p.API.KVSet(recordingConsentKey(consent.RoomID, consent.PostID, userID), b); appErr != nil {
+		return nil, appErr
+	}
+
+	p.audit(auditActionRecordingConsent, userID, record.ChannelID, consent.RoomID, nil)
+
+	return consent, nil
+}
+
+// recordingConsentMessage returns the custom consent message, or the default
+// one in the language of the localizer.
+func (p *Plugin) recordingConsentMessage(l *i18n.Localizer, custom string) string {
+	if custom != "" {
+		return custom
+	}
+	return p.b.LocalizeDefaultMessage(l, &i18n.Message{
+		ID:    "digitalsamba.recording_consent.default",
+		Other: "This meeting may be recorded. By joining, you consent to being recorded.",
+	})
+}
+
+// roomConsentMessage is the consent message DigitalSamba shows in a room.
+// Rooms that cannot be recorded have none. The room is shared by everyone,
+// so the default message is in the language of the server.
+func (p *Plugin) roomConsentMessage(settings *meetingSettings) string {
+	if !settings.RecordingEnabled {
+		return ""
+	}
+	return p.recordingConsentMessage(p.b.GetServerLocalizer(), settings.ConsentMessage)
+}
+
+// needsRecordingConsent reports whether the user must consent to recording
+// before getting a token for the meeting.
+func (p *Plugin) needsRecordingConsent(userID string, record *MeetingRecord) (bool, error) {
+	if !record.RequireConsent {
+		return false, nil
+	}
+	consented, err := p.hasRecordingConsent(userID, record)
+	if err != nil {
+		return false, err
+	}
+	return !consented, nil
+}
+
+func (p *Plugin) handleRecordingConsent(w http.ResponseWriter, r *http.Request) {
+	userID := r.Header.Get("Mattermost-User-Id")
+
+	record, ok := p.getMeetingForRequest(w, r)
+	if !ok {
+		return
+	}
+
+	if record.EndedAt != 0 {
+		writeError(w, http.StatusBadRequest, errorCodeBadRequest, "The meeting has ended")
+		return
+	}
+
+	if !record.RequireConsent {
+		writeError(w, http.StatusBadRequest, errorCodeBadRequest, "The meeting does not ask for recording consent")
+		return
+	}
+
+	consent, err := p.saveRecordingConsent(record, userID)
+	if err != nil {
+		p.writeInternalError(w, "Failed to store recording consent", err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, consent)
+}
+
+// createGuestInvite stores an invite and returns the link to the join page,
+// where the guest consents before getting a token.
+func (p *Plugin) createGuestInvite(record *MeetingRecord, name, userID string) (*InviteResponse, error) {
+	inviteID := model.NewId()
+	b, err := json.Marshal(&GuestInvite{RoomID: record.RoomID, Name: name, CreatedBy: userID})
+	if err != nil {
+		return nil, err
+	}
+	if appErr := p.API.KVSetWithExpiry(guestInviteKeyPrefix+inviteID, b, int64(guestInviteTTL.Seconds())); appErr != nil {
+		return nil, appErr
+	}
+
+	return &InviteResponse{
+		URL:       p.guestJoinURL(record.RoomID) + "?invite=" + inviteID,
+		ExpiresAt: time.Now().Add(guestInviteTTL).UnixMilli(),
+	}, nil
+}
+
+// getGuestInvite returns the invite to the room, if it exists.
+func (p *Plugin) getGuestInvite(roomID, inviteID string) (*GuestInvite, error) {
+	if !model.IsValidId(inviteID) {
+		return nil, nil
+	}
+
+	data, appErr := p.API.KVGet(guestInviteKeyPrefix + inviteID)
+	if appErr != nil {
+		return nil, appErr
+	}
+	if data == nil {
+		return nil, nil
+	}
+
+	var invite GuestInvite
+	if err := json.Unmarshal(data, &invite); err != nil {
+		return nil, err
+	}
+	if invite.RoomID != roomID {
+		return nil, nil
+	}
+
+	return &invite, nil
+}
+
+// guestConsentGiven reads the consent checkbox of the guest join page.
+func guestConsentGiven(r *http.Request) bool {
+	return strings.EqualFold(r.PostFormValue("consent"), "on")
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -20,11 +21,14 @@ type Room struct {
 	ID                string    `json:"id"`
 	Name              string    `json:"name"`
 	FriendlyURL       string    `json:"friendly_url"`
+	RoomURL           string    `json:"room_url"`
 	Privacy           string    `json:"privacy"`
 	MaxParticipants   int       `json:"max_participants"`
 	SessionDuration   int       `json:"session_duration"`
 	EnableRecording   bool      `json:"enable_recording"`
+	RecordingsEnabled bool      `json:"recordings_enabled"`
 	EnableBreakoutRooms bool    `json:"enable_breakout_rooms"`
+	ConsentMessage    string    `json:"consent_message"`
 	ExpiresAt         *time.Time `json:"expires_at,omitempty"`
 	CreatedAt         time.Time `json:"created_at"`
 }
@@ -34,6 +38,7 @@ type CreateRoomRequest struct {
 	Description       string     `json:"description,omitempty"`
 	FriendlyURL       string     `json:"friendly_url,omitempty"`
 	Privacy           string     `json:"privacy,omitempty"`
+	TemplateID        string     `json:"template_id,omitempty"`
 	MaxParticipants   int        `json:"max_participants,omitempty"`
 	RecordingsEnabled bool       `json:"recordings_enabled,omitempty"`
 	ChatEnabled       bool       `json:"chat_enabled,omitempty"`
@@ -54,6 +59,7 @@ type CreateRoomRequest struct {
 	EnableWhiteboard  bool       `json:"enable_whiteboard,omitempty"`
 	EnablePolling     bool       `json:"enable_polling,omitempty"`
 	EnableQA          bool       `json:"enable_qa,omitempty"`
+	BreakoutsEnabled  *bool      `json:"breakouts_enabled,omitempty"`
 }
 
 type RoomToken struct {
@@ -73,6 +79,35 @@ type CreateTokenRequest struct {
 	AvatarURL string `json:"avatar_url,omitempty"`
 }
 
+// DigitalSambaAPIError is returned when DigitalSamba answers with an error status.
+type DigitalSambaAPIError struct {
+	StatusCode int
+	URL        string
+	Body       string
+}
+
+func (e *DigitalSambaAPIError) Error() string {
+	return fmt.Sprintf("API error: status=%d, url=%s, body=%s", e.StatusCode, e.URL, e.Body)
+}
+
+// IsFriendlyURLConflict reports whether DigitalSamba rejected a room because
+// its friendly URL is already used by another room.
+func IsFriendlyURLConflict(err error) bool {
+	var apiErr *DigitalSambaAPIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+
+	if apiErr.StatusCode == http.StatusConflict {
+		return true
+	}
+
+	body := strings.ToLower(apiErr.Body)
+	return (apiErr.StatusCode == http.StatusBadRequest || apiErr.StatusCode == http.StatusUnprocessableEntity) &&
+		strings.Contains(body, "friendly_url") &&
+		(strings.Contains(body, "taken") || strings.Contains(body, "exists") || strings.Contains(body, "unique"))
+}
+
 func NewDigitalSambaClient(baseURL, apiKey string) *DigitalSambaClient {
 	return &DigitalSambaClient{
 		baseURL: baseURL,
@@ -116,7 +151,7 @@ func (c *DigitalSambaClient) doRequest(method, path string, body interface{}) (*
 	if resp.StatusCode >= 400 {
 		body, _ := io.ReadAll(resp.Body)
 		resp.Body.Close()
-		return nil, fmt.Errorf("API error: status=%d, url=%s, body=%s", resp.StatusCode, fullURL, string(body))
+		return nil, &DigitalSambaAPIError{StatusCode: resp.StatusCode, URL: fullURL, Body: string(body)}
 	}
 
 	return resp, nil
@@ -152,6 +187,32 @@ func (c *DigitalSambaClient) GetRoom(roomID string) (*Room, error) {
 	return &room, nil
 }
 
+// UpdateRoomRequest changes the settings of an existing room. Unset fields
+// keep their value.
+type UpdateRoomRequest struct {
+	Privacy           string  `json:"privacy,omitempty"`
+	RecordingsEnabled *bool   `json:"recordings_enabled,omitempty"`
+	// ConsentMessage is shown to everyone entering the room. An empty
+	// message removes it.
+	ConsentMessage    *string `json:"consent_message,omitempty"`
+}
+
+// UpdateRoom changes the settings of a room.
+func (c *DigitalSambaClient) UpdateRoom(roomID string, req *UpdateRoomRequest) (*Room, error) {
+	resp, err := c.doRequest("PATCH", "/rooms/"+roomID, req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var room Room
+	if err := json.NewDecoder(resp.Body).Decode(&room); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return &room, nil
+}
+
 func (c *DigitalSambaClient) DeleteRoom(roomID string) error {
 	resp, err := c.doRequest("DELETE", "/rooms/"+roomID, nil)
 	if err != nil {
@@ -198,4 +259,353 @@ func (c *DigitalSambaClient) CreateToken(req *CreateTokenRequest) (*RoomToken, e
 	}
 
 	return &token, nil
-}
\ No newline at end of file
+}
+
+type RoomList struct {
+	TotalCount int    `json:"total_count"`
+	Data       []Room `json:"data"`
+}
+
+func (c *DigitalSambaClient) ListRooms(limit int) (*RoomList, error) {
+	resp, err := c.doRequest("GET", fmt.Sprintf("/rooms?limit=%d", limit), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var rooms RoomList
+	if err := json.NewDecoder(resp.Body).Decode(&rooms); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return &rooms, nil
+}
+
+// GetCurrentStatistics returns the team's current usage and quota figures.
+// The fields vary by plan, so they are returned as a generic map.
+func (c *DigitalSambaClient) GetCurrentStatistics() (map[string]interface{}, error) {
+	resp, err := c.doRequest("GET", "/statistics/current", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var stats map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&stats); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return stats, nil
+}
+
+type Participant struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	Role       string     `json:"role"`
+	ExternalID string     `json:"external_id"`
+	JoinTime   *time.Time `json:"join_time,omitempty"`
+	LeaveTime  *time.Time `json:"leave_time,omitempty"`
+}
+
+type participantList struct {
+	Data []Participant `json:"data"`
+}
+
+// ListLiveParticipants returns the participants currently in the room.
+func (c *DigitalSambaClient) ListLiveParticipants(roomID string) ([]Participant, error) {
+	resp, err := c.doRequest("GET", fmt.Sprintf("/rooms/%s/live/participants", roomID), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var participants participantList
+	if err := json.NewDecoder(resp.Body).Decode(&participants); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return participants.Data, nil
+}
+
+type ChatMessage struct {
+	ID              string     `json:"id"`
+	Message         string     `json:"message"`
+	ParticipantName string     `json:"participant_name"`
+	ExternalID      string     `json:"external_id"`
+	CreatedAt       *time.Time `json:"created_at,omitempty"`
+}
+
+type chatMessageList struct {
+	Data []ChatMessage `json:"data"`
+}
+
+// ListChatMessages returns the public chat messages of the room, oldest first.
+func (c *DigitalSambaClient) ListChatMessages(roomID string) ([]ChatMessage, error) {
+	resp, err := c.doRequest("GET", fmt.Sprintf("/rooms/%s/chat?order=asc&limit=1000", roomID), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var messages chatMessageList
+	if err := json.NewDecoder(resp.Body).Decode(&messages); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return messages.Data, nil
+}
+
+type PollOption struct {
+	ID    string `json:"id"`
+	Text  string `json:"text"`
+	Votes int    `json:"votes"`
+}
+
+type Poll struct {
+	ID        string       `json:"id"`
+	Question  string       `json:"question"`
+	Status    string       `json:"status"`
+	Options   []PollOption `json:"options"`
+	CreatedAt *time.Time   `json:"created_at,omitempty"`
+}
+
+type pollList struct {
+	Data []Poll `json:"data"`
+}
+
+// ListPolls returns the polls of the room.
+func (c *DigitalSambaClient) ListPolls(roomID string) ([]Poll, error) {
+	resp, err := c.doRequest("GET", fmt.Sprintf("/rooms/%s/polls", roomID), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var polls pollList
+	if err := json.NewDecoder(resp.Body).Decode(&polls); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return polls.Data, nil
+}
+
+// GetPollResults returns the poll with the vote count of every option.
+func (c *DigitalSambaClient) GetPollResults(roomID, pollID string) (*Poll, error) {
+	resp, err := c.doRequest("GET", fmt.Sprintf("/rooms/%s/polls/%s/results", roomID, pollID), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var poll Poll
+	if err := json.NewDecoder(resp.Body).Decode(&poll); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return &poll, nil
+}
+
+type QuestionAnswer struct {
+	Answer          string `json:"answer"`
+	ParticipantName string `json:"participant_name"`
+}
+
+type Question struct {
+	ID              string           `json:"id"`
+	Question        string           `json:"question"`
+	ParticipantName string           `json:"participant_name"`
+	Answers         []QuestionAnswer `json:"answers"`
+	CreatedAt       *time.Time       `json:"created_at,omitempty"`
+}
+
+type questionList struct {
+	Data []Question `json:"data"`
+}
+
+// ListQuestions returns the Q&A questions of the room with their answers.
+func (c *DigitalSambaClient) ListQuestions(roomID string) ([]Question, error) {
+	resp, err := c.doRequest("GET", fmt.Sprintf("/rooms/%s/questions", roomID), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var questions questionList
+	if err := json.NewDecoder(resp.Body).Decode(&questions); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return questions.Data, nil
+}
+
+// ListSessionParticipants returns everyone who took part in a session.
+func (c *DigitalSambaClient) ListSessionParticipants(sessionID string) ([]Participant, error) {
+	resp, err := c.doRequest("GET", fmt.Sprintf("/sessions/%s/participants", sessionID), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var participants participantList
+	if err := json.NewDecoder(resp.Body).Decode(&participants); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return participants.Data, nil
+}
+
+// ExportTranscript downloads the transcript of a session. It returns the raw
+// file with its content type, which can be WebVTT, SRT or JSON.
+func (c *DigitalSambaClient) ExportTranscript(sessionID string) ([]byte, string, error) {
+	resp, err := c.doRequest("GET", fmt.Sprintf("/sessions/%s/transcripts/export", sessionID), nil)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read response: %w", err)
+	}
+
+	return data, resp.Header.Get("Content-Type"), nil
+}
+
+type Session struct {
+	ID                string     `json:"id"`
+	RoomID            string     `json:"room_id"`
+	StartTime         *time.Time `json:"start_time,omitempty"`
+	EndTime           *time.Time `json:"end_time,omitempty"`
+	ParticipantsCount int        `json:"participants_count"`
+	Live              bool       `json:"live"`
+}
+
+type sessionList struct {
+	Data []Session `json:"data"`
+}
+
+// ListSessions returns the sessions held in the room, newest first.
+func (c *DigitalSambaClient) ListSessions(roomID string) ([]Session, error) {
+	resp, err := c.doRequest("GET", fmt.Sprintf("/rooms/%s/sessions?order=desc", roomID), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var sessions sessionList
+	if err := json.NewDecoder(resp.Body).Decode(&sessions); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return sessions.Data, nil
+}
+
+type SessionStatistics struct {
+	ID                  string     `json:"id"`
+	StartTime           *time.Time `json:"start_time,omitempty"`
+	EndTime             *time.Time `json:"end_time,omitempty"`
+	Duration            int        `json:"duration"`
+	ParticipantsCount   int        `json:"participants_count"`
+	MaxParticipantCount int        `json:"max_participants_count"`
+}
+
+// GetSessionStatistics returns the times and participant counts of a session.
+func (c *DigitalSambaClient) GetSessionStatistics(sessionID string) (*SessionStatistics, error) {
+	resp, err := c.doRequest("GET", fmt.Sprintf("/sessions/%s/statistics", sessionID), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var stats SessionStatistics
+	if err := json.NewDecoder(resp.Body).Decode(&stats); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return &stats, nil
+}
+
+// EndSession ends a live session, which disconnects everyone in the room
+// while keeping the room.
+func (c *DigitalSambaClient) EndSession(sessionID string) error {
+	resp, err := c.doRequest("POST", fmt.Sprintf("/sessions/%s/end", sessionID), nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+type BreakoutRoom struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	FriendlyURL string `json:"friendly_url"`
+	RoomURL     string `json:"room_url"`
+}
+
+type CreateBreakoutRoomRequest struct {
+	Name string `json:"name"`
+}
+
+// CreateBreakoutRoom adds a breakout room to a room. Breakouts need a room
+// created with breakouts enabled.
+func (c *DigitalSambaClient) CreateBreakoutRoom(roomID string, req *CreateBreakoutRoomRequest) (*BreakoutRoom, error) {
+	resp, err := c.doRequest("POST", fmt.Sprintf("/rooms/%s/breakout-rooms", roomID), req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var breakout BreakoutRoom
+	if err := json.NewDecoder(resp.Body).Decode(&breakout); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return &breakout, nil
+}
+
+type StartStreamingRequest struct {
+	RTMPURL   string `json:"rtmp_url"`
+	StreamKey string `json:"stream_key"`
+}
+
+// StartStreaming streams the live session of a room to an RTMP server.
+func (c *DigitalSambaClient) StartStreaming(roomID string, req *StartStreamingRequest) error {
+	resp, err := c.doRequest("POST", fmt.Sprintf("/rooms/%s/streaming/start", roomID), req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// StopStreaming stops the live stream of a room.
+func (c *DigitalSambaClient) StopStreaming(roomID string) error {
+	resp, err := c.doRequest("POST", fmt.Sprintf("/rooms/%s/streaming/stop", roomID), nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// StartRecording starts recording the live session of a room. The room must
+// have been created with recordings enabled.
+func (c *DigitalSambaClient) StartRecording(roomID string) error {
+	resp, err := c.doRequest("POST", fmt.Sprintf("/rooms/%s/recordings/start", roomID), nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// StopRecording stops recording the live session of a room.
+func (c *DigitalSambaClient) StopRecording(roomID string) error {
+	resp, err := c.doRequest("POST", fmt.Sprintf("/rooms/%s/recordings/stop", roomID), nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+)
+
+// Events DigitalSamba sends to the plugin's incoming webhook.
+const (
+	digitalSambaEventSessionStarted    = "session_started"
+	digitalSambaEventSessionEnded      = "session_ended"
+	digitalSambaEventParticipantJoined = "participant_joined"
+	digitalSambaEventParticipantLeft   = "participant_left"
+	digitalSambaEventRecordingStarted  = "recording_started"
+	digitalSambaEventRecordingStopped  = "recording_stopped"
+	digitalSambaEventRecordingReady    = "recording_ready"
+	digitalSambaEventTranscriptReady   = "transcript_ready"
+)
+
+// DigitalSambaEvent is the body of a webhook call from DigitalSamba.
+type DigitalSambaEvent struct {
+	Event string                `json:"event"`
+	Data  DigitalSambaEventData `json:"data"`
+}
+
+type DigitalSambaEventData struct {
+	RoomID          string `json:"room_id"`
+	SessionID       string `json:"session_id"`
+	ParticipantID   string `json:"participant_id"`
+	ParticipantName string `json:"participant_name"`
+	ParticipantRole string `json:"participant_role"`
+	ExternalID      string `json:"external_id"`
+	RecordingID     string `json:"recording_id"`
+	RecordingURL    string `json:"recording_url"`
+	Timestamp       string `json:"timestamp"`
+}
+
+// handleDigitalSambaWebhook receives events from DigitalSamba. The webhook
+// must be registered in the DigitalSamba dashboard with the header
+// "Authorization: Bearer <incoming webhook secret>".
+func (p *Plugin) handleDigitalSambaWebhook(w http.ResponseWriter, r *http.Request) {
+	secret := p.getConfiguration().DigitalSambaIncomingWebhookSecret
+	provided := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if secret == "" || subtle.ConstantTimeCompare([]byte(provided), []byte(secret)) != 1 {
+		writeError(w, http.StatusUnauthorized, errorCodeUnauthorized, "Invalid webhook secret")
+		return
+	}
+
+	var event DigitalSambaEvent
+	if !decodeJSON(w, r, &event) {
+		return
+	}
+
+	record, err := p.getMeetingRecord(event.Data.RoomID)
+	if err != nil {
+		p.writeInternalError(w, "Failed to get meeting", err)
+		return
+	}
+	if record == nil {
+		// Rooms not started from Mattermost are none of our business
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	p.handleDigitalSambaEvent(&event, record)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (p *Plugin) handleDigitalSambaEvent(event *DigitalSambaEvent, record *MeetingRecord) {
+	switch event.Event {
+	case digitalSambaEventSessionEnded:
+		p.clearStreamStatus(record)
+		p.clearRecordingStatus(record)
+		p.emitWebhookEvent(webhookEventMeetingEnded, record, "", nil, nil)
+		go func() {
+			p.exportMeetingContent(record)
+			p.postAttendanceReport(record, event.Data.SessionID)
+		}()
+	case digitalSambaEventParticipantJoined:
+		p.markCallAnswered(record, event.Data.ExternalID)
+		// Only guest links issue tokens without a Mattermost user
+		if event.Data.ExternalID == "" {
+			p.audit(auditActionInviteUse, "", record.ChannelID, record.RoomID, map[string]string{
+				"participant_id":   event.Data.ParticipantID,
+				"participant_name": event.Data.ParticipantName,
+			})
+		}
+		p.emitWebhookEvent(webhookEventParticipantJoined, record, event.Data.ExternalID, participantFromEvent(event), nil)
+	case digitalSambaEventParticipantLeft:
+		p.emitWebhookEvent(webhookEventParticipantLeft, record, event.Data.ExternalID, participantFromEvent(event), nil)
+	case digitalSambaEventRecordingStarted:
+		p.handleRecordingEvent(record, event.Data.ExternalID, true)
+	case digitalSambaEventRecordingStopped:
+		p.handleRecordingEvent(record, event.Data.ExternalID, false)
+	case digitalSambaEventRecordingReady:
+		p.emitWebhookEvent(webhookEventRecordingReady, record, "", nil, &WebhookRecording{
+			ID:  event.Data.RecordingID,
+			URL: event.Data.RecordingURL,
+		})
+	case digitalSambaEventTranscriptReady:
+		go p.handleTranscriptReady(event, record)
+	default:
+		p.API.LogDebug("Ignoring DigitalSamba event", "event", event.Event, "room_id", record.RoomID)
+	}
+}
+
+func participantFromEvent(event *DigitalSambaEvent) *WebhookParticipant {
+	return &WebhookParticipant{
+		ID:     event.Data.ParticipantID,
+		Name:   event.Data.ParticipantName,
+		Role:   event.Data.ParticipantRole,
+		UserID: event.Data.ExternalID,
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// maxFriendlyURLLength is the longest friendly URL DigitalSamba accepts.
+const maxFriendlyURLLength = 32
+
+// friendlyURLSuffixLength is the length of the hex suffix that keeps
+// shortened and retried friendly URLs apart.
+const friendlyURLSuffixLength = 6
+
+// maxFriendlyURLAttempts bounds the rooms created for one meeting when
+// friendly URLs keep colliding.
+const maxFriendlyURLAttempts = 4
+
+// shortenFriendlyURL fits a meeting name into a friendly URL. Names that are
+// too long are cut at a word boundary and get a suffix derived from the full
+// name, so that long names sharing a prefix stay distinct.
+func shortenFriendlyURL(name string) string {
+	name = encodeDigitalSambaMeetingID(name)
+	if len(name) <= maxFriendlyURLLength {
+		return name
+	}
+
+	sum := sha256.Sum256([]byte(name))
+	return withFriendlyURLSuffix(name, hex.EncodeToString(sum[:])[:friendlyURLSuffixLength])
+}
+
+// withFriendlyURLSuffix appends "-suffix" to the name, cutting the name at a
+// word boundary so that the result fits into a friendly URL.
+func withFriendlyURLSuffix(name, suffix string) string {
+	maxLength := maxFriendlyURLLength - len(suffix) - 1
+	if len(name) > maxLength {
+		name = name[:maxLength]
+		// Avoid cutting a word in half unless that leaves too little
+		if i := strings.LastIndexAny(name, "-_"); i >= maxLength/2 {
+			name = name[:i]
+		}
+	}
+
+	return strings.TrimRight(name, "-_") + "-" + suffix
+}
+
+// createRoomWithFriendlyURL creates the room, retrying with a fresh random
+// suffix while DigitalSamba reports that the friendly URL is taken. The
+// returned room always carries the friendly URL it was created with.
+func createRoomWithFriendlyURL(client *DigitalSambaClient, req *CreateRoomRequest) (*Room, error) {
+	base := req.FriendlyURL
+	req.FriendlyURL = shortenFriendlyURL(base)
+
+	for attempt := 1; ; attempt++ {
+		room, err := client.CreateRoom(req)
+		if err == nil {
+			if room.FriendlyURL == "" {
+				room.FriendlyURL = req.FriendlyURL
+			}
+			return room, nil
+		}
+
+		if !IsFriendlyURLConflict(err) {
+			return nil, err
+		}
+		if attempt == maxFriendlyURLAttempts {
+			return nil, fmt.Errorf("no free friendly URL for %q after %d attempts: %w", base, attempt, err)
+		}
+
+		req.FriendlyURL = withFriendlyURLSuffix(encodeDigitalSambaMeetingID(base), randomHex(friendlyURLSuffixLength))
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func TestShortenFriendlyURL(t *testing.T) {
+	suffix := func(encoded string) string {
+		sum := sha256.Sum256([]byte(encoded))
+		return hex.EncodeToString(sum[:])[:friendlyURLSuffixLength]
+	}
+
+	longName := "engineering-weekly-planning-meeting-for-q3"
+	tests := []struct {
+		name string
+		want string
+	}{
+		{name: "standup", want: "standup"},
+		{name: "Team sync: Q3!", want: "Team-sync-Q3"},
+		{name: strings.Repeat("a", 32), want: strings.Repeat("a", 32)},
+		{
+			// Cut at the last word boundary that fits
+			name: longName,
+			want: "engineering-weekly-" + suffix(longName),
+		},
+		{
+			// Without a word boundary the name is cut where it must be
+			name: strings.Repeat("a", 40),
+			want: strings.Repeat("a", 25) + "-" + suffix(strings.Repeat("a", 40)),
+		},
+		{
+			// A boundary in the first half of the name is ignored
+			name: "ab-" + strings.Repeat("c", 40),
+			want: "ab-" + strings.Repeat("c", 22) + "-" + suffix("ab-"+strings.Repeat("c", 40)),
+		},
+		{
+			// The suffix is derived from the encoded name
+			name: "very long meeting name about the quarterly roadmap",
+			want: "very-long-meeting-name-" + suffix("very-long-meeting-name-about-the-quarterly-roadmap"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := shortenFriendlyURL(tt.name)
+			if got != tt.want {
+				t.Errorf("shortenFriendlyURL(%q) = %q, want %q", tt.name, got, tt.want)
+			}
+			if len(got) > maxFriendlyURLLength {
+				t.Errorf("shortenFriendlyURL(%q) = %q is longer than %d characters", tt.name, got, maxFriendlyURLLength)
+			}
+		})
+	}
+}
+
+func TestShortenFriendlyURLKeepsLongNamesApart(t *testing.T) {
+	a := shortenFriendlyURL("quarterly-planning-meeting-engineering")
+	b := shortenFriendlyURL("quarterly-planning-meeting-marketing")
+	if a == b {
+		t.Errorf("long names sharing a prefix both shortened to %q", a)
+	}
+}
@@ -0,0 +1,27 @@
+package main
+
+import "strings"
+
+// kvListPageSize is the number of keys read per KVList call.
+const kvListPageSize = 1000
+
+// listKeys returns every key in the plugin's KV store with the prefix.
+func (p *Plugin) listKeys(prefix string) ([]string, error) {
+	var matching []string
+	for page := 0; ; page++ {
+		keys, appErr := p.API.KVList(page, kvListPageSize)
+		if appErr != nil {
+			return nil, appErr
+		}
+
+		for _, key := range keys {
+			if strings.HasPrefix(key, prefix) {
+				matching = append(matching, key)
+			}
+		}
+
+		if len(keys) < kvListPageSize {
+			return matching, nil
+		}
+	}
+}
@@ -0,0 +1,503 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/pluginapi/i18n"
+)
+
+const joinRequestKeyPrefix = "join_request_"
+
+// joinRequestUpdateEvent tells a user that their request to join was
+// decided.
+const joinRequestUpdateEvent = "join_request_update"
+
+const (
+	joinRequestPending  = "pending"
+	joinRequestAdmitted = "admitted"
+	joinRequestDenied   = "denied"
+)
+
+const (
+	joinRequestDecisionAdmit = "admit"
+	joinRequestDecisionDeny  = "deny"
+)
+
+// maxModeratorLookup bounds the channel members searched for channel admins
+// to notify.
+const maxModeratorLookup = 200
+
+// JoinRequest is a user asking to be let into a private room they are not
+// entitled to. Persistent and personal rooms are reused, so a request is for
+// the meeting of one post and a decision never carries over to the next one.
+type JoinRequest struct {
+	RoomID      string `json:"room_id"`
+	PostID      string `json:"post_id"`
+	UserID      string `json:"user_id"`
+	Status      string `json:"status"`
+	RequestedAt int64  `json:"requested_at"`
+	DecidedBy   string `json:"decided_by,omitempty"`
+	DecidedAt   int64  `json:"decided_at,omitempty"`
+
+	// Notifications maps each moderator to the direct message that asked
+	// them. The messages are updated once the request is decided.
+	Notifications map[string]string `json:"notifications,omitempty"`
+}
+
+// MeetingAccess tells the webapp how a user can enter a meeting.
+type MeetingAccess struct {
+	CanJoin           bool   `json:"can_join"`
+	Private           bool   `json:"private"`
+	JoinRequestStatus string `json:"join_request_status,omitempty"`
+	// ConsentRequired asks the user to consent to recording, showing them
+	// ConsentMessage, before they join.
+	ConsentRequired bool   `json:"consent_required,omitempty"`
+	ConsentMessage  string `json:"consent_message,omitempty"`
+}
+
+// meetingAccessError is why a user gets no token for a meeting.
+type meetingAccessError struct {
+	status  int
+	code    string
+	message string
+}
+
+func (e *meetingAccessError) Error() string {
+	return e.message
+}
+
+func joinRequestKey(roomID, postID, userID string) string {
+	return joinRequestKeyPrefix + roomID + "_" + postID + "_" + userID
+}
+
+// lobbyMeeting returns the meeting whose lobby decides on the record: breakout
+// rooms share the lobby of their meeting.
+func (p *Plugin) lobbyMeeting(record *MeetingRecord) *MeetingRecord {
+	if record.ParentRoomID == "" {
+		return record
+	}
+
+	parent, err := p.getMeetingRecord(record.ParentRoomID)
+	if err != nil || parent == nil {
+		return record
+	}
+
+	return parent
+}
+
+// getJoinRequest returns the user's request to join the meeting, if any.
+func (p *Plugin) getJoinRequest(record *MeetingRecord, userID string) (*JoinRequest, error) {
+	lobby := p.lobbyMeeting(record)
+	data, appErr := p.API.KVGet(joinRequestKey(lobby.RoomID, lobby.PostID, userID))
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	if data == nil {
+		return nil, nil
+	}
+
+	var joinRequest JoinRequest
+	if err := json.Unmarshal(data, &joinRequest); err != nil {
+		return nil, err
+	}
+
+	return &joinRequest, nil
+}
+
+func (p *Plugin) saveJoinRequest(joinRequest *JoinRequest) error {
+	b, err := json.Marshal(joinRequest)
+	if err != nil {
+		return err
+	}
+
+	if appErr := p.API.KVSet(joinRequestKey(joinRequest.RoomID, joinRequest.PostID, joinRequest.UserID), b); appErr != nil {
+		return appErr
+	}
+
+	return nil
+}
+
+// canJoinDirectly reports whether the user is entitled to a token without
+// asking. Anyone who can read the channel may enter a public room; a private
+// room is reserved to the members of its channel.
+func (p *Plugin) canJoinDirectly(userID string, record *MeetingRecord) bool {
+	if record.CreatorID == userID || record.ChannelID == "" {
+		return true
+	}
+
+	if !record.Private {
+		return p.API.HasPermissionToChannel(userID, record.ChannelID, model.PermissionReadChannel)
+	}
+
+	_, appErr := p.API.GetChannelMember(record.ChannelID, userID)
+	return appErr == nil
+}
+
+// isAdmitted reports whether a moderator let the user into the meeting. A
+// user admitted to a meeting may also enter its breakout rooms.
+func (p *Plugin) isAdmitted(userID string, record *MeetingRecord) bool {
+	joinRequest, err := p.getJoinRequest(record, userID)
+	return err == nil && joinRequest != nil && joinRequest.Status == joinRequestAdmitted
+}
+
+// issueMeetingToken returns a token for the user after the checks every
+// token for a Mattermost user must pass. Only meetings started from
+// Mattermost are known, members of the meeting's channel join as moderators,
+// everyone else must have been admitted to a private meeting through its
+// lobby, and everyone must have consented to recording if the meeting asks
+// for it.
+func (p *Plugin) issueMeetingToken(user *model.User, record *MeetingRecord) (*RoomToken, error) {
+	if record == nil {
+		return nil, &meetingAccessError{http.StatusNotFound, errorCodeNotFound, "Meeting not found"}
+	}
+
+	role := tokenRoleModerator
+	if !p.canJoinDirectly(user.Id, record) {
+		if !record.Private {
+			return nil, &meetingAccessError{http.StatusForbidden, errorCodeForbidden, "Forbidden"}
+		}
+		if !p.isAdmitted(user.Id, record) {
+			return nil, &meetingAccessError{http.StatusForbidden, errorCodeJoinRequestRequired, "A moderator must admit you to this meeting"}
+		}
+		role = tokenRoleAttendee
+	}
+
+	needsConsent, err := p.needsRecordingConsent(user.Id, record)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get recording consent: %w", err)
+	}
+	if needsConsent {
+		return nil, &meetingAccessError{http.StatusForbidden, errorCodeRecordingConsentRequired, "You must consent to recording before joining this meeting"}
+	}
+
+	return p.getToken(p.getAccount(record.TeamID), user, record.RoomID, role)
+}
+
+// meetingModerators returns the users who decide on join requests: the
+// meeting creator and the admins of its channel.
+func (p *Plugin) meetingModerators(record *MeetingRecord) []string {
+	moderators := []string{record.CreatorID}
+
+	members, appErr := p.API.GetChannelMembers(record.ChannelID, 0, maxModeratorLookup)
+	if appErr != nil {
+		p.API.LogWarn("Failed to get channel members", "channel_id", record.ChannelID, "error", appErr.Error())
+		return moderators
+	}
+
+	for _, member := range members {
+		if member.SchemeAdmin && member.UserId != record.CreatorID {
+			moderators = append(moderators, member.UserId)
+		}
+	}
+
+	return moderators
+}
+
+func (p *Plugin) handleGetMeetingAccess(w http.ResponseWriter, r *http.Request) {
+	userID := r.Header.Get("Mattermost-User-Id")
+
+	record, ok := p.getMeetingForRequest(w, r)
+	if !ok {
+		return
+	}
+
+	access := &MeetingAccess{
+		CanJoin: p.canJoinDirectly(userID, record),
+		Private: record.Private,
+	}
+	if !access.CanJoin {
+		joinRequest, err := p.getJoinRequest(record, userID)
+		if err != nil {
+			p.writeInternalError(w, "Failed to get join request", err)
+			return
+		}
+		if joinRequest != nil {
+			access.JoinRequestStatus = joinRequest.Status
+			access.CanJoin = joinRequest.Status == joinRequestAdmitted
+		}
+	}
+
+	needsConsent, err := p.needsRecordingConsent(userID, record)
+	if err != nil {
+		p.writeInternalError(w, "Failed to get recording consent", err)
+		return
+	}
+	if needsConsent {
+		access.ConsentRequired = true
+		access.ConsentMessage = p.recordingConsentMessage(p.b.GetUserLocalizer(userID), record.ConsentMessage)
+	}
+
+	writeJSON(w, http.StatusOK, access)
+}
+
+func (p *Plugin) handleCreateJoinRequest(w http.ResponseWriter, r *http.Request) {
+	userID := r.Header.Get("Mattermost-User-Id")
+
+	record, ok := p.getMeetingForRequest(w, r)
+	if !ok {
+		return
+	}
+	// Asking to join a breakout room asks to join its meeting
+	record = p.lobbyMeeting(record)
+
+	if record.EndedAt != 0 {
+		writeError(w, http.StatusBadRequest, errorCodeBadRequest, "The meeting has ended")
+		return
+	}
+
+	if p.canJoinDirectly(userID, record) {
+		writeError(w, http.StatusBadRequest, errorCodeBadRequest, "You can join this meeting without asking")
+		return
+	}
+
+	joinRequest, err := p.getJoinRequest(record, userID)
+	if err != nil {
+		p.writeInternalError(w, "Failed to get join request", err)
+		return
+	}
+
+	// Asking again after a decision would let a denied user pester the
+	// moderators
+	if joinRequest != nil {
+		writeJSON(w, http.StatusOK, joinRequest)
+		return
+	}
+
+	user, appErr := p.API.GetUser(userID)
+	if appErr != nil {
+		p.writeInternalError(w, "Failed to get user", appErr)
+		return
+	}
+
+	joinRequest = &JoinRequest{
+		RoomID:        record.RoomID,
+		PostID:        record.PostID,
+		UserID:        userID,
+		Status:        joinRequestPending,
+		RequestedAt:   model.GetMillis(),
+		Notifications: map[string]string{},
+	}
+	for _, moderatorID := range p.meetingModerators(record) {
+		if post := p.sendJoinRequestNotification(moderatorID, user, record); post != nil {
+			joinRequest.Notifications[moderatorID] = post.Id
+		}
+	}
+
+	if err := p.saveJoinRequest(joinRequest); err != nil {
+		p.writeInternalError(w, "Failed to store join request", err)
+		return
+	}
+
+	p.audit(auditActionJoinRequestCreate, userID, record.ChannelID, record.RoomID, nil)
+
+	writeJSON(w, http.StatusOK, joinRequest)
+}
+
+// sendJoinRequestNotification asks a moderator in a direct message from the
+// bot to admit or deny the user.
+func (p *Plugin) sendJoinRequestNotification(moderatorID string, user *model.User, record *MeetingRecord) *model.Post {
+	channel, appErr := p.API.GetDirectChannel(moderatorID, p.botID)
+	if appErr != nil {
+		p.API.LogWarn("Failed to get direct channel", "user_id", moderatorID, "error", appErr.Error())
+		return nil
+	}
+
+	l := p.b.GetUserLocalizer(moderatorID)
+	apiURL := *p.API.GetConfig().ServiceSettings.SiteURL + "/plugins/digitalsamba/api/v1/actions/join-request"
+
+	action := func(id, name, decision string) *model.PostAction {
+		return &model.PostAction{
+			Id:   id,
+			Name: name,
+			Integration: &model.PostActionIntegration{
+				URL: apiURL,
+				Context: map[string]interface{}{
+					"room_id":  record.RoomID,
+					"post_id":  record.PostID,
+					"user_id":  user.Id,
+					"decision": decision,
+				},
+			},
+		}
+	}
+
+	attachment := &model.SlackAttachment{
+		Text: p.b.LocalizeWithConfig(l, &i18n.LocalizeConfig{
+			DefaultMessage: &i18n.Message{
+				ID:    "digitalsamba.join_request.notification",
+				Other: "@{{.Username}} asks to join **{{.Topic}}**.",
+			},
+			TemplateData: map[string]string{
+				"Username": user.Username,
+				"Topic":    record.Topic,
+			},
+		}),
+		Actions: []*model.PostAction{
+			action("admit", p.b.LocalizeDefaultMessage(l, &i18n.Message{ID: "digitalsamba.join_request.admit", Other: "Admit"}), joinRequestDecisionAdmit),
+			action("deny", p.b.LocalizeDefaultMessage(l, &i18n.Message{ID: "digitalsamba.join_request.deny", Other: "Deny"}), joinRequestDecisionDeny),
+		},
+	}
+
+	post := &model.Post{
+		UserId:    p.botID,
+		ChannelId: channel.Id,
+	}
+	model.ParseSlackAttachment(post, []*model.SlackAttachment{attachment})
+
+	createdPost, appErr := p.API.CreatePost(post)
+	if appErr != nil {
+		p.API.LogWarn("Failed to send join request", "user_id", moderatorID, "error", appErr.Error())
+		return nil
+	}
+
+	return createdPost
+}
+
+// handleJoinRequestAction handles the Admit and Deny buttons of a join
+// request.
+func (p *Plugin) handleJoinRequestAction(w http.ResponseWriter, r *http.Request) {
+	userID := r.Header.Get("Mattermost-User-Id")
+
+	var actionReq model.PostActionIntegrationRequest
+	if !decodeJSON(w, r, &actionReq) {
+		return
+	}
+
+	roomID, _ := actionReq.Context["room_id"].(string)
+	postID, _ := actionReq.Context["post_id"].(string)
+	requesterID, _ := actionReq.Context["user_id"].(string)
+	decision, _ := actionReq.Context["decision"].(string)
+
+	record, err := p.getPostActionMeeting(roomID, postID)
+	if err != nil || record == nil {
+		writeJSON(w, http.StatusOK, &model.PostActionIntegrationResponse{EphemeralText: "The meeting no longer exists"})
+		return
+	}
+
+	if !p.canManageMeeting(userID, record) {
+		writeJSON(w, http.StatusOK, &model.PostActionIntegrationResponse{EphemeralText: "Only the meeting creator or a channel admin can decide on join requests"})
+		return
+	}
+
+	joinRequest, err := p.getJoinRequest(record, requesterID)
+	if err != nil || joinRequest == nil {
+		writeJSON(w, http.StatusOK, &model.PostActionIntegrationResponse{EphemeralText: "The join request no longer exists"})
+		return
+	}
+
+	if joinRequest.Status != joinRequestPending {
+		writeJSON(w, http.StatusOK, &model.PostActionIntegrationResponse{EphemeralText: "This join request was already decided"})
+		return
+	}
+
+	if err := p.decideJoinRequest(record, joinRequest, userID, decision); err != nil {
+		p.API.LogError("Failed to decide join request", "room_id", roomID, "user_id", requesterID, "error", err.Error())
+		writeJSON(w, http.StatusOK, &model.PostActionIntegrationResponse{EphemeralText: "Failed to decide the join request"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, &model.PostActionIntegrationResponse{})
+}
+
+// decideJoinRequest admits or denies the requester, tells them and replaces
+// the buttons of every moderator's notification with the outcome.
+func (p *Plugin) decideJoinRequest(record *MeetingRecord, joinRequest *JoinRequest, deciderID, decision string) error {
+	switch decision {
+	case joinRequestDecisionAdmit:
+		joinRequest.Status = joinRequestAdmitted
+	case joinRequestDecisionDeny:
+		joinRequest.Status = joinRequestDenied
+	default:
+		return fmt.Errorf("unknown decision %q", decision)
+	}
+	joinRequest.DecidedBy = deciderID
+	joinRequest.DecidedAt = model.GetMillis()
+
+	if err := p.saveJoinRequest(joinRequest); err != nil {
+		return err
+	}
+	p.invalidateUserTokens(joinRequest.UserID)
+
+	auditAction := auditActionJoinRequestDeny
+	if joinRequest.Status == joinRequestAdmitted {
+		auditAction = auditActionJoinRequestAdmit
+	}
+	p.audit(auditAction, deciderID, record.ChannelID, record.RoomID, map[string]string{"requester_id": joinRequest.UserID})
+
+	p.API.PublishWebSocketEvent(joinRequestUpdateEvent, map[string]interface{}{
+		"room_id": record.RoomID,
+		"status":  joinRequest.Status,
+	}, &model.WebsocketBroadcast{UserId: joinRequest.UserID})
+
+	deciderName := deciderID
+	if decider, appErr := p.API.GetUser(deciderID); appErr == nil {
+		deciderName = decider.Username
+	}
+	requesterName := joinRequest.UserID
+	if requester, appErr := p.API.GetUser(joinRequest.UserID); appErr == nil {
+		requesterName = requester.Username
+	}
+
+	l := p.b.GetUserLocalizer(joinRequest.UserID)
+	var message string
+	if joinRequest.Status == joinRequestAdmitted {
+		message = p.b.LocalizeWithConfig(l, &i18n.LocalizeConfig{
+			DefaultMessage: &i18n.Message{
+				ID:    "digitalsamba.join_request.admitted",
+				Other: "You were let into **{{.Topic}}**. Use **Join Meeting** on the [meeting post]({{.Permalink}}) to enter.",
+			},
+			TemplateData: map[string]string{
+				"Topic":     record.Topic,
+				"Permalink": fmt.Sprintf("%s/_redirect/pl/%s", *p.API.GetConfig().ServiceSettings.SiteURL, record.PostID),
+			},
+		})
+	} else {
+		message = p.b.LocalizeWithConfig(l, &i18n.LocalizeConfig{
+			DefaultMessage: &i18n.Message{
+				ID:    "digitalsamba.join_request.denied",
+				Other: "Your request to join **{{.Topic}}** was declined.",
+			},
+			TemplateData: map[string]string{
+				"Topic": record.Topic,
+			},
+		})
+	}
+	p.sendDirectMessage(joinRequest.UserID, message)
+
+	for moderatorID, postID := range joinRequest.Notifications {
+		post, appErr := p.API.GetPost(postID)
+		if appErr != nil {
+			continue
+		}
+
+		ml := p.b.GetUserLocalizer(moderatorID)
+		outcome := p.b.LocalizeWithConfig(ml, &i18n.LocalizeConfig{
+			DefaultMessage: &i18n.Message{
+				ID:    "digitalsamba.join_request.decided",
+				Other: "@{{.Requester}} asked to join **{{.Topic}}**: {{.Status}} by @{{.Decider}}.",
+			},
+			TemplateData: map[string]string{
+				"Requester": requesterName,
+				"Topic":     record.Topic,
+				"Status":    joinRequest.Status,
+				"Decider":   deciderName,
+			},
+		})
+
+		attachments := post.Attachments()
+		for _, attachment := range attachments {
+			attachment.Text = outcome
+			attachment.Actions = nil
+		}
+		post.AddProp("attachments", attachments)
+		if _, appErr := p.API.UpdatePost(post); appErr != nil {
+			p.API.LogWarn("Failed to update join request post", "post_id", postID, "error", appErr.Error())
+		}
+	}
+
+	return nil
+}
@@ -56,6 +56,36 @@ const manifestStr = `
         "hosting": "",
         "secret": false
       },
+      {
+        "key": "DigitalSambaConnectionStatus",
+        "display_name": "Connection Status:",
+        "type": "custom",
+        "help_text": "Result of the last connectivity check. The check runs in the background every time the configuration is saved; run '/digitalsamba admin test' for details.",
+        "placeholder": "",
+        "default": null,
+        "hosting": "",
+        "secret": false
+      },
+      {
+        "key": "DigitalSambaTeamName",
+        "display_name": "DigitalSamba Team Name:",
+        "type": "text",
+        "help_text": "The name of your DigitalSamba team, i.e. the subdomain of your meeting links (myteam for https://myteam.digitalsamba.com). Used to build meeting links when the API does not return one. Either a team name or a custom domain is required.",
+        "placeholder": "myteam",
+        "default": null,
+        "hosting": "",
+        "secret": false
+      },
+      {
+        "key": "DigitalSambaCustomDomain",
+        "display_name": "Custom Meeting Domain:",
+        "type": "text",
+        "help_text": "Optional. The white-label domain your meetings are served from, e.g. meet.example.com. Used to build meeting links when the API does not return one and no team name is set.",
+        "placeholder": "meet.example.com",
+        "default": null,
+        "hosting": "",
+        "secret": false
+      },
       {
         "key": "DigitalSambaEmbedded",
         "display_name": "Embed DigitalSamba video inside Mattermost:",
@@ -85,7 +115,7 @@ const manifestStr = `
         "default": "words",
         "options": [
           {
-            "display_name": "Random English words in title case (e.g. PlayfulDragonsObserveCuriously)",
+            "display_name": "Random words in title case in the user's language (e.g. CheerfulOttersExplore)",
             "value": "words"
           },
           {
@@ -96,6 +126,10 @@ const manifestStr = `
             "display_name": "Mattermost context specific names. Combination of team name, channel name, and random text in Public and Private channels; personal meeting name in Direct and Group Message channels.",
             "value": "mattermost"
           },
+          {
+            "display_name": "Admin-defined template, set in Meeting Name Template",
+            "value": "template"
+          },
           {
             "display_name": "Allow user to select meeting name",
             "value": "ask"
@@ -104,6 +138,60 @@ const manifestStr = `
         "hosting": "",
         "secret": false
       },
+      {
+        "key": "DigitalSambaNamingTemplate",
+        "display_name": "Meeting Name Template:",
+        "type": "text",
+        "help_text": "Template for meeting names when the naming scheme is 'template'. Placeholders: {team}, {channel}, {user}, {topic}, {date[:layout]}, {time[:layout]} in the user's timezone using Go layouts (e.g. {date:2006-01-02}), {words[:n]} random words, {rand[:n]} random hex characters and {counter}, a per-channel meeting counter. Names may only contain letters, digits, '-' and '_'; names over 32 characters are shortened with a hash suffix. Preview it with '/digitalsamba settings naming_scheme'.",
+        "placeholder": "{team}-{channel}-{rand:4}",
+        "default": "{team}-{channel}-{rand:4}",
+        "hosting": "",
+        "secret": false
+      },
+      {
+        "key": "DigitalSambaWordPattern",
+        "display_name": "Random Word Pattern:",
+        "type": "dropdown",
+        "help_text": "Words that make up random meeting names. Shorter patterns keep names within DigitalSamba's 32 character limit.",
+        "placeholder": "",
+        "default": "adjective-noun-verb",
+        "options": [
+          {
+            "display_name": "Adjective and noun (e.g. CheerfulOtters)",
+            "value": "adjective-noun"
+          },
+          {
+            "display_name": "Adjective, noun and verb (e.g. CheerfulOttersExplore)",
+            "value": "adjective-noun-verb"
+          },
+          {
+            "display_name": "Adjective, noun, verb and adverb (e.g. CheerfulOttersExploreTogether)",
+            "value": "adjective-noun-verb-adverb"
+          }
+        ],
+        "hosting": "",
+        "secret": false
+      },
+      {
+        "key": "DigitalSambaWordLists",
+        "display_name": "Custom Word Lists:",
+        "type": "longtext",
+        "help_text": "JSON object mapping language codes to custom word lists, e.g. {\"en\": {\"adjectives\": [\"Happy\"], \"nouns\": [\"Otters\"], \"verbs\": [\"Meet\"], \"adverbs\": [\"Today\"]}}. Lists replace the built-in ones for that language; parts left out keep the built-in words. Words may only contain letters a-z and digits. Names use the user's Mattermost language: built-in lists exist for English, German, Spanish and French, and other languages fall back to English.",
+        "placeholder": "",
+        "default": "",
+        "hosting": "",
+        "secret": false
+      },
+      {
+        "key": "DigitalSambaWordDenylist",
+        "display_name": "Denied Words:",
+        "type": "longtext",
+        "help_text": "Words, separated by commas or new lines, that random meeting names must never contain. Matching ignores case and also catches words formed across two generated words. Leave empty to use the default list.",
+        "placeholder": "",
+        "default": "fuck, shit, cunt, bitch, bastard, whore, slut, piss, dick, cock, prick, pussy, twat, wank, dildo, penis, porn, jizz, tits, boob, bollocks, damn, rapist, nazi, hitler, nigger, nigga, faggot, retard, kike",
+        "hosting": "",
+        "secret": false
+      },
       {
         "key": "DigitalSambaRoomExpiry",
         "display_name": "Room Expiry Time (minutes):",
@@ -128,7 +216,27 @@ const manifestStr = `
         "key": "DigitalSambaEnableRecording",
         "display_name": "Enable Recording:",
         "type": "bool",
-        "help_text": "Allow meeting hosts to record meetings.",
+        "help_text": "Create rooms with recordings enabled and let meeting hosts start and stop recording with /digitalsamba record or the Record button of the meeting post.",
+        "placeholder": "",
+        "default": false,
+        "hosting": "",
+        "secret": false
+      },
+      {
+        "key": "DigitalSambaRecordingConsentMessage",
+        "display_name": "Recording Consent Message:",
+        "type": "longtext",
+        "help_text": "Optional. The consent message DigitalSamba shows everyone entering a meeting that can be recorded. Leave empty to use the default message in the language of the server, or of each user in Mattermost. Channel admins can override it for their channel.",
+        "placeholder": "This meeting may be recorded. By joining, you consent to being recorded.",
+        "default": "",
+        "hosting": "",
+        "secret": false
+      },
+      {
+        "key": "DigitalSambaRequireRecordingConsent",
+        "display_name": "Require Recording Consent:",
+        "type": "bool",
+        "help_text": "Mattermost users must acknowledge the consent message before they can join a meeting that can be recorded. Every acknowledgment is stored and audited. Channel admins can override it for their channel.",
         "placeholder": "",
         "default": false,
         "hosting": "",
@@ -138,11 +246,121 @@ const manifestStr = `
         "key": "DigitalSambaEnableBreakoutRooms",
         "display_name": "Enable Breakout Rooms:",
         "type": "bool",
-        "help_text": "Allow meeting hosts to create breakout rooms.",
+        "help_text": "Create rooms with breakout rooms enabled and let meeting hosts create breakout rooms with /digitalsamba breakout.",
         "placeholder": "",
         "default": false,
         "hosting": "",
         "secret": false
+      },
+      {
+        "key": "DigitalSambaPrivateTemplates",
+        "display_name": "Private Room Templates:",
+        "type": "text",
+        "help_text": "Comma-separated DigitalSamba template IDs that always create private rooms. Meetings in private channels are private by default. Users who are not members of the meeting's channel must request to join a private room and be admitted by the meeting creator or a channel admin.",
+        "placeholder": "",
+        "default": "",
+        "hosting": "",
+        "secret": false
+      },
+      {
+        "key": "DigitalSambaPasscodeTemplates",
+        "display_name": "Passcode Room Templates:",
+        "type": "text",
+        "help_text": "Comma-separated DigitalSamba template IDs whose meetings always need a passcode. When the creator does not choose one, a random passcode is sent to them in a direct message. Guests enter it on the guest join page; channel members never need it.",
+        "placeholder": "",
+        "default": "",
+        "hosting": "",
+        "secret": false
+      },
+      {
+        "key": "DigitalSambaExportMeetingContent",
+        "display_name": "Export Meeting Chat:",
+        "type": "bool",
+        "help_text": "When a meeting ends, post its chat, poll results and Q&A as a reply in the meeting thread.",
+        "placeholder": "",
+        "default": true,
+        "hosting": "",
+        "secret": false
+      },
+      {
+        "key": "DigitalSambaTranscriptRetentionDays",
+        "display_name": "Transcript Retention (days):",
+        "type": "number",
+        "help_text": "Transcripts posted in meeting threads are deleted after this many days. Set to 0 to keep them forever; transcripts posted while it is 0 are never deleted.",
+        "placeholder": "",
+        "default": 0,
+        "hosting": "",
+        "secret": false
+      },
+      {
+        "key": "DigitalSambaAuditRetentionDays",
+        "display_name": "Audit Log Retention (days):",
+        "type": "number",
+        "help_text": "Audit entries for meetings, tokens, guest links and configuration changes are deleted after this many days. Set to 0 to keep them forever.",
+        "placeholder": "",
+        "default": 90,
+        "hosting": "",
+        "secret": false
+      },
+      {
+        "key": "DigitalSambaCallTimeout",
+        "display_name": "Call Timeout (minutes):",
+        "type": "number",
+        "help_text": "Meetings started in direct and group messages ring the other members for this many minutes, after which the call is marked as missed if nobody joined. Set to 0 to ring until dismissed and never mark calls as missed.",
+        "placeholder": "",
+        "default": 5,
+        "hosting": "",
+        "secret": false
+      },
+      {
+        "key": "DigitalSambaTeamAccounts",
+        "display_name": "Team Accounts:",
+        "type": "longtext",
+        "help_text": "Optional. A JSON object mapping Mattermost team IDs to their own DigitalSamba account, e.g. {\"<team-id>\": {\"api_key\": \"...\", \"dashboard_url\": \"https://api.digitalsamba.com\", \"team_name\": \"myteam\", \"custom_domain\": \"\"}}. Channels in other teams, and direct and group messages, use the account above. When dashboard_url is empty the default Dashboard URL is used. Each account needs a team_name or a custom_domain.",
+        "placeholder": "{\"<team-id>\": {\"api_key\": \"...\", \"team_name\": \"...\"}}",
+        "default": null,
+        "hosting": "",
+        "secret": true
+      },
+      {
+        "key": "DigitalSambaStreamTargets",
+        "display_name": "Stream Targets:",
+        "type": "longtext",
+        "help_text": "Optional. A JSON object mapping stream target names to RTMP servers meetings can be streamed to with /digitalsamba stream start, e.g. {\"townhall\": {\"url\": \"rtmps://video.example.com/live\", \"key\": \"...\"}}. Add \"channels\": [channel IDs] to allow a target only in those channels, or \"admins_only\": true to let only system admins use it. Only the meeting creator or a channel admin can start a stream.",
+        "placeholder": "{\"townhall\": {\"url\": \"rtmps://...\", \"key\": \"...\"}}",
+        "default": "",
+        "hosting": "",
+        "secret": true
+      },
+      {
+        "key": "DigitalSambaOutgoingWebhooks",
+        "display_name": "Outgoing Webhook URLs:",
+        "type": "longtext",
+        "help_text": "Optional. One URL per line. The plugin posts a signed JSON payload to each URL when a meeting starts or ends, a participant joins or leaves, or a recording is ready. Failed deliveries are retried with backoff; run '/digitalsamba admin webhooks' to see deliveries that failed for good.",
+        "placeholder": "https://crm.example.com/hooks/digitalsamba",
+        "default": null,
+        "hosting": "",
+        "secret": false
+      },
+      {
+        "key": "DigitalSambaWebhookSecret",
+        "display_name": "Outgoing Webhook Signing Secret:",
+        "type": "generated",
+        "help_text": "Required to send outgoing webhooks. Used to sign outgoing webhook payloads. Each request carries X-DigitalSamba-Timestamp and X-DigitalSamba-Signature: sha256=HMAC-SHA256(secret, timestamp + \".\" + body).",
+        "placeholder": "",
+        "default": null,
+        "hosting": "",
+        "secret": true
+      },
+      {
+        "key": "DigitalSambaIncomingWebhookSecret",
+        "display_name": "DigitalSamba Webhook Secret:",
+        "type": "generated",
+        "help_text": "Register https://<your-mattermost>/plugins/digitalsamba/api/v1/webhooks/digitalsamba as a webhook in the DigitalSamba dashboard with the header 'Authorization: Bearer <this secret>'. The plugin uses these events for participant and recording notifications.",
+        "placeholder": "",
+        "default": null,
+        "hosting": "",
+        "secret": true
       }
     ],
     "sections": null
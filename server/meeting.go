@@ -3,6 +3,7 @@ package main
 import (
 	"fmt"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
@@ -18,17 +19,35 @@ type MeetingInfo struct {
 	Token     string `json:"token"`
 	TeamName  string `json:"team_name"`
 	RoomName  string `json:"room_name"`
+	ChannelID string `json:"channel_id,omitempty"`
+	PostID    string `json:"post_id,omitempty"`
+	Topic     string `json:"topic,omitempty"`
+	CreatorID string `json:"creator_id,omitempty"`
+	CreatedAt int64  `json:"created_at,omitempty"`
+	EndedAt   int64  `json:"ended_at,omitempty"`
+	Status    string `json:"status,omitempty"`
+	Private   bool   `json:"private,omitempty"`
+	Recording bool   `json:"recording,omitempty"`
+	// GuestURL is the passcode page for people outside Mattermost.
+	GuestURL  string `json:"guest_url,omitempty"`
 }
 
 func boolPtr(b bool) *bool {
 	return &b
 }
 
-func (p *Plugin) startMeeting(user *model.User, channel *model.Channel, meetingID string, meetingTopic string, _ bool, rootID string) (*MeetingInfo, error) {
+func (p *Plugin) startMeeting(user *model.User, channel *model.Channel, meetingID string, meetingTopic string, personal bool, rootID string, passcode string) (*MeetingInfo, error) {
 	l := p.b.GetServerLocalizer()
 	
-	// Generate meeting ID if not provided
-	if meetingID == "" {
+	// Personal rooms keep their own link
+	var personalRoom *PersonalRoom
+	if personal {
+		var err error
+		if personalRoom, err = p.getPersonalRoom(user.Id); err != nil {
+			return nil, fmt.Errorf("failed to get personal room: %w", err)
+		}
+	} else if meetingID == "" {
+		// Generate meeting ID if not provided
 		meetingID = p.generateMeetingID(user, channel, meetingTopic)
 	}
 	
@@ -44,99 +63,103 @@ func (p *Plugin) startMeeting(user *model.User, channel *model.Channel, meetingI
 
 	// Create room in DigitalSamba
 	config := p.getConfiguration()
-	roomExpiry := time.Now().Add(time.Duration(config.DigitalSambaRoomExpiry) * time.Minute)
-	
-	// Ensure friendly URL doesn't exceed 32 character limit
-	friendlyURL := meetingID
-	if len(friendlyURL) > 32 {
-		friendlyURL = friendlyURL[:32]
+	settings := p.resolveMeetingSettings(user.Id, channel)
+	account := p.getAccount(channel.TeamId)
+	accountTeamID := channel.TeamId
+	if personal {
+		settings = p.personalMeetingSettings(user.Id, personalRoom)
+		account = p.getAccount("")
+		accountTeamID = ""
 	}
-	
-	createRoomReq := &CreateRoomRequest{
-		Topic:             meetingTopic,
-		FriendlyURL:       friendlyURL,
-		Privacy:           "public",
-		MaxParticipants:   config.DigitalSambaMaxParticipants,
-		RecordingsEnabled: config.DigitalSambaEnableRecording,
-		ChatEnabled:       true,
-		
-		// Join Settings - optimized for internal teams
-		JoinScreenEnabled: boolPtr(false),  // Skip prejoin screen for faster access
-		MuteOnJoin:        boolPtr(false),  // Don't mute by default for internal meetings
-		CameraOffOnJoin:   boolPtr(false),  // Camera on by default for better engagement
-		
-		// Disable consent messages for internal use
-		ConsentMessage:    "",  // Empty string disables consent message
-		
-		// Layout Settings
-		DefaultLayout:     "auto",       // Smart layout based on content
-		
-		// Enable collaboration features
-		EnableWhiteboard:  true,
-		EnablePolling:     true,
-		EnableQA:          true,
+
+	// Guests outside the channel enter the passcode on the join page. Some
+	// templates always need one, the creator then gets a generated passcode.
+	passcodeHash := ""
+	if personal {
+		passcodeHash = personalRoom.Settings.PasscodeHash
 	}
+	generatedPasscode := ""
+	if passcode == "" && passcodeHash == "" && p.isPasscodeTemplate(settings.TemplateID) {
+		passcode = generatePasscode()
+		generatedPasscode = passcode
+	}
+	if passcode != "" {
+		hash, err := hashPasscode(passcode)
+		if err != nil {
+			return nil, fmt.Errorf("failed to hash passcode: %w", err)
+		}
+		passcodeHash = hash
+	}
+	// A public room opens without a token and would skip the passcode
+	if passcodeHash != "" {
+		settings.GuestAccess = false
+	}
+	roomExpiry := time.Now().Add(time.Duration(config.DigitalSambaRoomExpiry) * time.Minute)
+	
+	createRoomReq := newCreateRoomRequest(meetingTopic, meetingID, settings)
+	createRoomReq.ConsentMessage = p.roomConsentMessage(settings)
 	
-	if config.DigitalSambaRoomExpiry > 0 {
+	// Persistent channel rooms and personal rooms never expire
+	if config.DigitalSambaRoomExpiry > 0 && !settings.PersistentRoom && !personal {
 		createRoomReq.ExpiresAt = &roomExpiry
 	}
 	
-	room, err := p.digitalSambaClient.CreateRoom(createRoomReq)
+	var room *Room
+	var err error
+	if personal {
+		room, err = p.getOrCreatePersonalRoom(user, personalRoom, createRoomReq)
+	} else if settings.PersistentRoom {
+		room, err = p.getOrCreateChannelRoom(account, channel.Id, createRoomReq)
+	} else {
+		room, err = createRoomWithFriendlyURL(account.client, createRoomReq)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to create room: %w", err)
 	}
-
-	// Create moderator token for the meeting creator
-	tokenReq := &CreateTokenRequest{
-		RoomID:    room.ID,
-		UserID:    user.Id,
-		UserName:  user.GetDisplayName(model.ShowNicknameFullName),
-		UserEmail: user.Email,
-		Role:      "moderator", // All internal users are moderators
+	// The post and the meeting info show the URL the room actually got
+	meetingID = room.FriendlyURL
+	// A reused persistent or personal room keeps the privacy it was created
+	// with
+	if passcodeHash != "" && room.Privacy == "public" {
+		if _, err := account.client.UpdateRoom(room.ID, &UpdateRoomRequest{Privacy: "private"}); err != nil {
+			return nil, fmt.Errorf("failed to make the room private: %w", err)
+		}
+		room.Privacy = "private"
 	}
-	
-	// Add avatar URL if available
-	siteURL := p.API.GetConfig().ServiceSettings.SiteURL
-	if siteURL != nil && *siteURL != "" {
-		tokenReq.AvatarURL = fmt.Sprintf("%s/api/v4/users/%s/image?_=%d", *siteURL, user.Id, user.LastPictureUpdate)
+	// and whether it can be recorded, which follows the current settings
+	if (personal || settings.PersistentRoom) && room.RecordingsEnabled != createRoomReq.RecordingsEnabled {
+		if _, err := account.client.UpdateRoom(room.ID, &UpdateRoomRequest{RecordingsEnabled: &createRoomReq.RecordingsEnabled}); err != nil {
+			return nil, fmt.Errorf("failed to change recording of the room: %w", err)
+		}
+		room.RecordingsEnabled = createRoomReq.RecordingsEnabled
 	}
-	
-	hostToken, err := p.digitalSambaClient.CreateToken(tokenReq)
-	if err != nil {
-		// Clean up room if token creation fails
-		_ = p.digitalSambaClient.DeleteRoom(room.ID)
-		return nil, fmt.Errorf("failed to create host token: %w", err)
+	// and its consent message, which must follow the current policy. An empty
+	// message removes the one of a room that can no longer be recorded.
+	if (personal || settings.PersistentRoom) && room.ConsentMessage != createRoomReq.ConsentMessage {
+		if _, err := account.client.UpdateRoom(room.ID, &UpdateRoomRequest{ConsentMessage: &createRoomReq.ConsentMessage}); err != nil {
+			p.API.LogWarn("Failed to update the consent message of the room", "room_id", room.ID, "error", err.Error())
+		}
 	}
-	
+	private := room.Privacy == "private" || (room.Privacy == "" && createRoomReq.Privacy == "private")
+
+	// Persistent and personal rooms outlive every single meeting and must
+	// survive a failed meeting start.
+	cleanupRoom := func() {
+		if !settings.PersistentRoom && !personal {
+			_ = account.client.DeleteRoom(room.ID)
+		}
+	}
+
 	// Debug logging
-	p.API.LogDebug("DigitalSamba token created", 
+	p.API.LogDebug("DigitalSamba room ready", 
 		"room_id", room.ID,
 		"room_friendly_url", room.FriendlyURL,
-		"token_room_url", hostToken.RoomURL,
-		"dashboard_url", config.GetDashboardURL())
+		"dashboard_url", account.dashboardURL)
 
-	// Construct the meeting URL
-	// DigitalSamba meeting URLs follow the pattern: https://TEAM.digitalsamba.com/ROOM
-	meetingURL := ""
-	
-	// Extract team name from the API URL
-	dashboardURL := config.GetDashboardURL()
-	
-	// Try to extract team name from URL like https://myteam.digitalsamba.com/api/v1
-	if strings.Contains(dashboardURL, ".digitalsamba.com") {
-		parts := strings.Split(dashboardURL, ".")
-		if len(parts) > 0 && strings.HasPrefix(dashboardURL, "https://") {
-			teamName := strings.TrimPrefix(parts[0], "https://")
-			meetingURL = fmt.Sprintf("https://%s.digitalsamba.com/%s", teamName, room.FriendlyURL)
-		}
-	}
-	
-	// Fallback if we couldn't extract team name
-	if meetingURL == "" {
-		// Use the room ID as a fallback - user will need to configure team name properly
-		p.API.LogWarn("Could not extract team name from dashboard URL, using placeholder", 
-			"dashboard_url", dashboardURL)
-		meetingURL = fmt.Sprintf("https://CONFIGURE_TEAM_NAME.digitalsamba.com/%s", room.FriendlyURL)
+	meetingURL, err := account.meetingURL(room)
+	if err != nil {
+		cleanupRoom()
+		return nil, err
 	}
 	
 	// Create meeting post
@@ -176,7 +199,7 @@ func (p *Plugin) startMeeting(user *model.User, channel *model.Channel, meetingI
 		}),
 	}
 
-	if config.DigitalSambaRoomExpiry > 0 {
+	if createRoomReq.ExpiresAt != nil {
 		expiryText := p.b.LocalizeWithConfig(l, &i18n.LocalizeConfig{
 			DefaultMessage: &i18n.Message{
 				ID:    "digitalsamba.start_meeting.room_expires",
@@ -189,55 +212,240 @@ func (p *Plugin) startMeeting(user *model.User, channel *model.Channel, meetingI
 		slackAttachment.Text += "\n\n" + expiryText
 	}
 
+	if passcodeHash != "" {
+		guestText := p.b.LocalizeWithConfig(l, &i18n.LocalizeConfig{
+			DefaultMessage: &i18n.Message{
+				ID:    "digitalsamba.start_meeting.guest_link",
+				Other: "Guests join at [the guest page]({{.GuestURL}}) with the meeting passcode. Channel members do not need it.",
+			},
+			TemplateData: map[string]string{
+				"GuestURL": p.guestJoinURL(room.ID),
+			},
+		})
+		slackAttachment.Text += "\n\n" + guestText
+	}
+
+	// The Record button and consent follow the room's own setting
+	recordingAllowed := room.RecordingsEnabled
+	if recordingAllowed {
+		slackAttachment.Actions = p.recordingActions(room.ID, false)
+	}
+
 	post := &model.Post{
 		UserId:    user.Id,
 		ChannelId: channel.Id,
 		Type:      "custom_digitalsamba",
 		Props: map[string]interface{}{
-			"attachments":     []*model.SlackAttachment{&slackAttachment},
-			"meeting_id":      meetingID,
-			"room_id":         room.ID,
-			"meeting_url":     meetingURL,
-			"meeting_topic":   meetingTopic,
-			"room_expires_at": roomExpiry.Unix(),
+			"attachments":       []*model.SlackAttachment{&slackAttachment},
+			"meeting_id":        meetingID,
+			"room_id":           room.ID,
+			"meeting_url":       meetingURL,
+			"meeting_topic":     meetingTopic,
+			"room_expires_at":   roomExpiry.Unix(),
+			"private":           private,
+			"passcode":          passcodeHash != "",
+			"recording_allowed": recordingAllowed,
 		},
 		RootId: rootID,
 	}
 
-	if _, err := p.API.CreatePost(post); err != nil {
+	createdPost, appErr := p.API.CreatePost(post)
+	if appErr != nil {
 		// Clean up room if post creation fails
-		_ = p.digitalSambaClient.DeleteRoom(room.ID)
-		return nil, err
+		cleanupRoom()
+		return nil, appErr
+	}
+
+	record := &MeetingRecord{
+		MeetingID:    meetingID,
+		RoomID:       room.ID,
+		FriendlyURL:  room.FriendlyURL,
+		MeetingURL:   meetingURL,
+		Topic:        meetingTopic,
+		ChannelID:    channel.Id,
+		TeamID:       accountTeamID,
+		CreatorID:    user.Id,
+		PostID:       createdPost.Id,
+		CreatedAt:    model.GetMillis(),
+		Personal:     personal,
+		Private:      private,
+		PasscodeHash: passcodeHash,
+		Invitees:     p.getCallees(channel, user.Id),
+
+		RecordingAllowed: recordingAllowed,
+		RequireConsent:   recordingAllowed && settings.RequireConsent,
+		ConsentMessage:   settings.ConsentMessage,
+	}
+	if err := p.saveMeetingRecord(record); err != nil {
+		p.API.LogWarn("Failed to store meeting record", "room_id", room.ID, "error", err.Error())
+	}
+	if err := p.addChannelMeeting(channel.Id, room.ID); err != nil {
+		p.API.LogWarn("Failed to index channel meeting", "channel_id", channel.Id, "room_id", room.ID, "error", err.Error())
+	}
+
+	p.audit(auditActionMeetingCreate, user.Id, channel.Id, room.ID, map[string]string{
+		"meeting_id":      meetingID,
+		"friendly_url":    room.FriendlyURL,
+		"privacy":         createRoomReq.Privacy,
+		"persistent_room": strconv.FormatBool(settings.PersistentRoom),
+		"personal_room":   strconv.FormatBool(personal),
+	})
+
+	if generatedPasscode != "" {
+		cl := p.b.GetUserLocalizer(user.Id)
+		p.sendDirectMessage(user.Id, p.b.LocalizeWithConfig(cl, &i18n.LocalizeConfig{
+			DefaultMessage: &i18n.Message{
+				ID:    "digitalsamba.start_meeting.generated_passcode",
+				Other: "The template of **{{.Topic}}** requires a passcode for guests: `{{.Passcode}}`\n\nShare it with the [guest link]({{.GuestURL}}). It is not shown again.",
+			},
+			TemplateData: map[string]string{
+				"Topic":    meetingTopic,
+				"Passcode": generatedPasscode,
+				"GuestURL": p.guestJoinURL(room.ID),
+			},
+		}))
+	}
+
+	if len(record.Invitees) > 0 {
+		p.ringCall(record, user)
 	}
 
+	p.emitWebhookEvent(webhookEventMeetingStarted, record, user.Id, nil, nil)
+
 	p.trackMeeting(nil)
-	// Extract team name and room name from the URL
-	// Format: https://TEAM.digitalsamba.com/ROOM
-	teamName := ""
-	roomName := room.FriendlyURL
-	if parts := strings.Split(strings.TrimPrefix(meetingURL, "https://"), "."); len(parts) > 0 {
-		teamName = parts[0]
-	}
-	if idx := strings.LastIndex(meetingURL, "/"); idx != -1 {
-		roomName = meetingURL[idx+1:]
-	}
-
-	return &MeetingInfo{
-		MeetingID: meetingID,
-		RoomID:    room.ID,
-		RoomURL:   meetingURL,
-		Token:     "", // Don't include token - each user should fetch their own
-		TeamName:  teamName,
-		RoomName:  roomName,
-	}, nil
+	return p.meetingInfoFromRecord(record), nil
+}
+
+// endMeeting closes the meeting's room in DigitalSamba, which disconnects
+// everyone in it, and marks the meeting post as ended. Persistent channel
+// rooms are replaced with a new room on the next meeting.
+func (p *Plugin) endMeeting(record *MeetingRecord, userID string) error {
+	if record.EndedAt != 0 {
+		return nil
+	}
+
+	// The content goes away with the room
+	p.exportMeetingContent(record)
+
+	account := p.getAccount(record.TeamID)
+	if record.Personal {
+		// The personal room keeps its link, only the session ends
+		if err := p.endLiveSessions(account, record.RoomID); err != nil {
+			return fmt.Errorf("failed to end session: %w", err)
+		}
+	} else if err := account.client.DeleteRoom(record.RoomID); err != nil {
+		return fmt.Errorf("failed to delete room: %w", err)
+	}
+
+	if channelRoom, err := p.getChannelRoom(record.ChannelID); err == nil && channelRoom != nil && channelRoom.RoomID == record.RoomID {
+		_ = p.API.KVDelete(channelRoomKeyPrefix + record.ChannelID)
+	}
+
+	p.stopRinging(record, pendingCallees(record))
+
+	endedAt := model.GetMillis()
+	if err := p.updateMeetingRecord(record, func(record *MeetingRecord) bool {
+		record.EndedAt = endedAt
+		record.EndedBy = userID
+		// The stream and the recording end with the session
+		record.StreamTarget = ""
+		record.StreamStartedAt = 0
+		record.RecordingStartedAt = 0
+		record.RecordingStartedBy = ""
+		return true
+	}); err != nil {
+		return err
+	}
+
+	p.markMeetingPostEnded(record)
+	p.endBreakoutRooms(record, userID)
+	p.audit(auditActionMeetingEnd, userID, record.ChannelID, record.RoomID, nil)
+	p.emitWebhookEvent(webhookEventMeetingEnded, record, userID, nil, nil)
+	return nil
+}
+
+func (p *Plugin) markMeetingPostEnded(record *MeetingRecord) {
+	if record.PostID == "" {
+		return
+	}
+
+	post, appErr := p.API.GetPost(record.PostID)
+	if appErr != nil {
+		p.API.LogWarn("Failed to get meeting post", "post_id", record.PostID, "error", appErr.Error())
+		return
+	}
+
+	l := p.b.GetServerLocalizer()
+	endedText := p.b.LocalizeDefaultMessage(l, &i18n.Message{
+		ID:    "digitalsamba.end_meeting.ended",
+		Other: "Meeting ended",
+	})
+
+	post.AddProp("meeting_ended", true)
+	post.AddProp("meeting_ended_at", record.EndedAt)
+	post.DelProp("stream_target")
+	post.DelProp("recording")
+	attachments := post.Attachments()
+	for _, attachment := range attachments {
+		attachment.Text = endedText
+		attachment.Actions = nil
+		attachment.Fields = nil
+	}
+	post.AddProp("attachments", attachments)
+
+	if _, appErr := p.API.UpdatePost(post); appErr != nil {
+		p.API.LogWarn("Failed to update meeting post", "post_id", record.PostID, "error", appErr.Error())
+	}
+}
+
+// newCreateRoomRequest returns the request for a meeting room with the
+// resolved settings.
+func newCreateRoomRequest(topic, friendlyURL string, settings *meetingSettings) *CreateRoomRequest {
+	return &CreateRoomRequest{
+		Topic:             topic,
+		FriendlyURL:       friendlyURL,
+		Privacy:           roomPrivacy(settings.GuestAccess),
+		TemplateID:        settings.TemplateID,
+		MaxParticipants:   settings.MaxParticipants,
+		RecordingsEnabled: settings.RecordingEnabled,
+		ChatEnabled:       true,
+		
+		// Join Settings - optimized for internal teams
+		JoinScreenEnabled: boolPtr(false),  // Skip prejoin screen for faster access
+		MuteOnJoin:        boolPtr(false),  // Don't mute by default for internal meetings
+		CameraOffOnJoin:   boolPtr(false),  // Camera on by default for better engagement
+		
+		// Layout Settings
+		DefaultLayout:     "auto",       // Smart layout based on content
+		
+		// Enable collaboration features
+		EnableWhiteboard:  true,
+		EnablePolling:     true,
+		EnableQA:          true,
+		BreakoutsEnabled:  boolPtr(settings.BreakoutRooms),
+	}
+}
+
+// roomPrivacy returns the privacy of a room. Private rooms can only be
+// entered with a token.
+func roomPrivacy(guestAccess bool) string {
+	if guestAccess {
+		return "public"
+	}
+	return "private"
 }
 
 func (p *Plugin) generateMeetingID(user *model.User, channel *model.Channel, meetingTopic string) string {
-	userConfig, _ := p.getUserConfig(user.Id)
-	
-	switch userConfig.NamingScheme {
+	settings := p.resolveMeetingSettings(user.Id, channel)
+	return p.generateMeetingName(settings.NamingScheme, user, channel, meetingTopic, false)
+}
+
+// generateMeetingName names a meeting with the naming scheme. A preview does
+// not consume the channel's meeting counter.
+func (p *Plugin) generateMeetingName(scheme string, user *model.User, channel *model.Channel, meetingTopic string, preview bool) string {
+	switch scheme {
 	case digitalSambaNameSchemeWords:
-		return generateEnglishTitleName()
+		return p.generateWordsName(user.Locale)
 	case digitalSambaNameSchemeUUID:
 		return generateUUIDName()
 	case digitalSambaNameSchemeMattermost:
@@ -248,18 +456,55 @@ func (p *Plugin) generateMeetingID(user *model.User, channel *model.Channel, mee
 		if team != nil {
 			return generateTeamChannelName(team.Name, channel.Name)
 		}
-		return generateEnglishTitleName()
+		return p.generateWordsName(user.Locale)
+	case digitalSambaNameSchemeTemplate:
+		ctx := p.newNamingContext(user, channel, meetingTopic)
+		ctx.Preview = preview
+		name, err := p.renderNamingTemplate(p.getConfiguration().GetNamingTemplate(), ctx)
+		if err != nil || name == "" {
+			p.API.LogWarn("Failed to render meeting name template", "error", fmt.Sprint(err))
+			return p.generateWordsName(user.Locale)
+		}
+		return name
 	default:
 		if meetingTopic != "" {
 			return encodeDigitalSambaMeetingID(meetingTopic)
 		}
-		return generateEnglishTitleName()
+		return p.generateWordsName(user.Locale)
 	}
 }
 
+// getOrCreateChannelRoom returns the channel's persistent room, creating it
+// the first time or when the stored room no longer exists in DigitalSamba.
+func (p *Plugin) getOrCreateChannelRoom(account *digitalSambaAccount, channelID string, createRoomReq *CreateRoomRequest) (*Room, error) {
+	channelRoom, err := p.getChannelRoom(channelID)
+	if err != nil {
+		return nil, err
+	}
+
+	if channelRoom != nil {
+		room, getErr := account.client.GetRoom(channelRoom.RoomID)
+		if getErr == nil {
+			return room, nil
+		}
+		p.API.LogWarn("Persistent channel room is gone, creating a new one", "channel_id", channelID, "room_id", channelRoom.RoomID, "error", getErr.Error())
+	}
+
+	room, err := createRoomWithFriendlyURL(account.client, createRoomReq)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := p.setChannelRoom(channelID, &ChannelRoom{RoomID: room.ID, FriendlyURL: room.FriendlyURL}); err != nil {
+		p.API.LogWarn("Failed to store persistent channel room", "channel_id", channelID, "error", err.Error())
+	}
+
+	return room, nil
+}
+
 func (p *Plugin) askMeetingType(user *model.User, channel *model.Channel, rootID string) error {
 	l := p.b.GetUserLocalizer(user.Id)
-	apiURL := *p.API.GetConfig().ServiceSettings.SiteURL + "/plugins/digitalsamba/api/v1/meetings"
+	apiURL := *p.API.GetConfig().ServiceSettings.SiteURL + "/plugins/digitalsamba/api/v1/actions/start-meeting"
 
 	actions := []*model.PostAction{}
 
@@ -278,9 +523,10 @@ func (p *Plugin) askMeetingType(user *model.User, channel *model.Channel, rootID
 		Integration: &model.PostActionIntegration{
 			URL: apiURL,
 			Context: map[string]interface{}{
-				"meeting_id":    generateEnglishTitleName(),
+				"meeting_id":    p.generateWordsName(user.Locale),
 				"meeting_topic": "DigitalSamba Meeting",
-				"personal":      true,
+				"personal":      false,
+				"root_id":       rootID,
 			},
 		},
 	})
@@ -298,6 +544,7 @@ func (p *Plugin) askMeetingType(user *model.User, channel *model.Channel, rootID
 				"meeting_id":    generatePersonalMeetingName(user.Username),
 				"meeting_topic": fmt.Sprintf("%s's Meeting", user.GetDisplayName(model.ShowNicknameFullName)),
 				"personal":      true,
+				"root_id":       rootID,
 			},
 		},
 	})
@@ -316,6 +563,7 @@ func (p *Plugin) askMeetingType(user *model.User, channel *model.Channel, rootID
 					"meeting_id":    generateTeamChannelName(team.Name, channel.Name),
 					"meeting_topic": fmt.Sprintf("%s Channel Meeting", channel.DisplayName),
 					"personal":      false,
+					"root_id":       rootID,
 				},
 			},
 		})
@@ -334,6 +582,7 @@ func (p *Plugin) askMeetingType(user *model.User, channel *model.Channel, rootID
 				"meeting_id":    generateUUIDName(),
 				"meeting_topic": "DigitalSamba Meeting",
 				"personal":      false,
+				"root_id":       rootID,
 			},
 		},
 	})
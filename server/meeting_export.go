@@ -0,0 +1,228 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+// exportMeetingContent posts the chat, poll results and Q&A of a meeting in
+// its thread, before DigitalSamba forgets them with the room. Content too long
+// for a post is attached as a markdown file instead. The meeting is only
+// marked as exported once everything was fetched and posted, so that a
+// failed export is retried when the meeting ends.
+func (p *Plugin) exportMeetingContent(record *MeetingRecord) {
+	if !p.getConfiguration().DigitalSambaExportMeetingContent || record.ContentExportedAt != 0 {
+		return
+	}
+
+	if err := p.postMeetingContent(record); err != nil {
+		p.API.LogWarn("Failed to export meeting content", "room_id", record.RoomID, "error", err.Error())
+		return
+	}
+
+	if err := p.updateMeetingRecord(record, func(record *MeetingRecord) bool {
+		record.ContentExportedAt = model.GetMillis()
+		return true
+	}); err != nil {
+		p.API.LogWarn("Failed to store meeting record", "room_id", record.RoomID, "error", err.Error())
+	}
+}
+
+// postMeetingContent fetches the meeting content and posts it, if there is
+// any.
+func (p *Plugin) postMeetingContent(record *MeetingRecord) error {
+	client := p.getAccount(record.TeamID).client
+
+	messages, err := client.ListChatMessages(record.RoomID)
+	if err != nil {
+		return fmt.Errorf("failed to get meeting chat: %w", err)
+	}
+
+	polls, err := client.ListPolls(record.RoomID)
+	if err != nil {
+		return fmt.Errorf("failed to get meeting polls: %w", err)
+	}
+	for i := range polls {
+		results, err := client.GetPollResults(record.RoomID, polls[i].ID)
+		if err != nil {
+			return fmt.Errorf("failed to get poll results: %w", err)
+		}
+		polls[i].Options = results.Options
+	}
+
+	questions, err := client.ListQuestions(record.RoomID)
+	if err != nil {
+		return fmt.Errorf("failed to get meeting Q&A: %w", err)
+	}
+
+	// Persistent rooms keep the content of earlier meetings
+	since := time.UnixMilli(record.CreatedAt)
+	content := formatMeetingContent(record, filterChatMessages(messages, since), filterPolls(polls, since), filterQuestions(questions, since))
+	if content == "" {
+		return nil
+	}
+
+	if utf8.RuneCountInString(content) <= model.PostMessageMaxRunesV2 {
+		_, appErr := p.API.CreatePost(&model.Post{
+			UserId:    p.botID,
+			ChannelId: record.ChannelID,
+			RootId:    record.PostID,
+			Message:   content,
+		})
+		if appErr != nil {
+			return fmt.Errorf("failed to create post: %w", appErr)
+		}
+		return nil
+	}
+
+	_, err = p.postThreadFile(record, "The meeting chat, polls and Q&A are attached.", fmt.Sprintf("meeting-%s.md", record.MeetingID), []byte(content))
+	return err
+}
+
+// postThreadFile posts a message from the bot with a file attached in the
+// thread of the meeting post.
+func (p *Plugin) postThreadFile(record *MeetingRecord, message, filename string, data []byte) (*model.Post, error) {
+	fileInfo, appErr := p.API.UploadFile(data, record.ChannelID, filename)
+	if appErr != nil {
+		return nil, fmt.Errorf("failed to upload file: %w", appErr)
+	}
+
+	post, appErr := p.API.CreatePost(&model.Post{
+		UserId:    p.botID,
+		ChannelId: record.ChannelID,
+		RootId:    record.PostID,
+		Message:   message,
+		FileIds:   []string{fileInfo.Id},
+	})
+	if appErr != nil {
+		return nil, fmt.Errorf("failed to create post: %w", appErr)
+	}
+
+	return post, nil
+}
+
+// newParticipantNameResolver returns a function that shows a participant by
+// their Mattermost display name when their external ID, the "ud" of their
+// token, is a Mattermost user, and by the name they joined with otherwise.
+// Names never mention anyone, so that posting them notifies nobody. Lookups
+// are cached for the lifetime of the function.
+func (p *Plugin) newParticipantNameResolver() func(externalID, name string) string {
+	displayNames := map[string]string{}
+	return func(externalID, name string) string {
+		if externalID == "" {
+			return escapeMentions(name)
+		}
+
+		displayName, ok := displayNames[externalID]
+		if !ok {
+			if user, appErr := p.API.GetUser(externalID); appErr == nil {
+				displayName = user.GetDisplayName(model.ShowNicknameFullName)
+			}
+			displayNames[externalID] = displayName
+		}
+
+		if displayName != "" {
+			return escapeMentions(displayName)
+		}
+		return escapeMentions(name)
+	}
+}
+
+func filterChatMessages(messages []ChatMessage, since time.Time) []ChatMessage {
+	var filtered []ChatMessage
+	for _, message := range messages {
+		if message.CreatedAt == nil || !message.CreatedAt.Before(since) {
+			filtered = append(filtered, message)
+		}
+	}
+	return filtered
+}
+
+func filterPolls(polls []Poll, since time.Time) []Poll {
+	var filtered []Poll
+	for _, poll := range polls {
+		if poll.CreatedAt == nil || !poll.CreatedAt.Before(since) {
+			filtered = append(filtered, poll)
+		}
+	}
+	return filtered
+}
+
+func filterQuestions(questions []Question, since time.Time) []Question {
+	var filtered []Question
+	for _, question := range questions {
+		if question.CreatedAt == nil || !question.CreatedAt.Before(since) {
+			filtered = append(filtered, question)
+		}
+	}
+	return filtered
+}
+
+// escapeMentions keeps text written in the meeting from mentioning anyone
+// when it is posted, @all and @channel included. A zero width space after
+// each @ breaks the mention without changing how the text reads.
+func escapeMentions(text string) string {
+	return strings.ReplaceAll(text, "@", "@\u200b")
+}
+
+// formatMeetingContent renders the meeting content as markdown. It returns
+// an empty string when the meeting had none. Everything participants wrote
+// has its mentions escaped.
+func formatMeetingContent(record *MeetingRecord, messages []ChatMessage, polls []Poll, questions []Question) string {
+	if len(messages) == 0 && len(polls) == 0 && len(questions) == 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "### %s\n", record.Topic)
+
+	if len(messages) > 0 {
+		sb.WriteString("\n#### Chat\n\n")
+		for _, message := range messages {
+			timestamp := ""
+			if message.CreatedAt != nil {
+				timestamp = " " + message.CreatedAt.UTC().Format("15:04")
+			}
+			// Two trailing spaces keep line breaks inside a message
+			fmt.Fprintf(&sb, "**%s**%s: %s  \n", escapeMentions(message.ParticipantName), timestamp, strings.ReplaceAll(escapeMentions(message.Message), "\n", "  \n"))
+		}
+	}
+
+	if len(polls) > 0 {
+		sb.WriteString("\n#### Polls\n")
+		for _, poll := range polls {
+			total := 0
+			for _, option := range poll.Options {
+				total += option.Votes
+			}
+
+			fmt.Fprintf(&sb, "\n**%s**\n", escapeMentions(poll.Question))
+			for _, option := range poll.Options {
+				percent := 0
+				if total > 0 {
+					percent = option.Votes * 100 / total
+				}
+				fmt.Fprintf(&sb, "- %s: %d votes (%d%%)\n", escapeMentions(option.Text), option.Votes, percent)
+			}
+		}
+	}
+
+	if len(questions) > 0 {
+		sb.WriteString("\n#### Q&A\n")
+		for _, question := range questions {
+			fmt.Fprintf(&sb, "\n**%s** (%s)\n", escapeMentions(question.Question), escapeMentions(question.ParticipantName))
+			if len(question.Answers) == 0 {
+				sb.WriteString("- _Unanswered_\n")
+			}
+			for _, answer := range question.Answers {
+				fmt.Fprintf(&sb, "- %s (%s)\n", escapeMentions(answer.Answer), escapeMentions(answer.ParticipantName))
+			}
+		}
+	}
+
+	return sb.String()
+}
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+)
+
+const meetingRecordKeyPrefix = "meeting_"
+const channelMeetingsKeyPrefix = "channel_meetings_"
+
+const (
+	meetingStatusActive = "active"
+	meetingStatusEnded  = "ended"
+)
+
+// maxChannelMeetings bounds the list of recent rooms kept per channel.
+const maxChannelMeetings = 50
+
+// MeetingRecord is what the plugin remembers about a meeting it started,
+// keyed by the DigitalSamba room ID.
+type MeetingRecord struct {
+	MeetingID   string `json:"meeting_id"`
+	RoomID      string `json:"room_id"`
+	FriendlyURL string `json:"friendly_url"`
+	MeetingURL  string `json:"meeting_url"`
+	Topic       string `json:"topic"`
+	ChannelID   string `json:"channel_id"`
+	// TeamID selects the DigitalSamba account of the room. It is empty for
+	// personal rooms, which live in the default account.
+	TeamID    string `json:"team_id"`
+	CreatorID string `json:"creator_id"`
+	PostID    string `json:"post_id"`
+	CreatedAt int64  `json:"created_at"`
+	EndedAt   int64  `json:"ended_at,omitempty"`
+	EndedBy   string `json:"ended_by,omitempty"`
+	Personal  bool   `json:"personal,omitempty"`
+	// Private rooms admit only the members of the channel. Everyone else
+	// must ask a moderator to let them in.
+	Private bool `json:"private,omitempty"`
+	// PasscodeHash is the bcrypt hash of the passcode guests enter on the
+	// join page. Channel members never need it.
+	PasscodeHash string `json:"passcode_hash,omitempty"`
+
+	// Breakouts are the breakout rooms of the meeting. A breakout room has
+	// its own record pointing back with ParentRoomID.
+	Breakouts    []string `json:"breakouts,omitempty"`
+	ParentRoomID string   `json:"parent_room_id,omitempty"`
+
+	// StreamTarget names the stream target the meeting is streamed to, if
+	// any.
+	StreamTarget    string `json:"stream_target,omitempty"`
+	StreamStartedAt int64  `json:"stream_started_at,omitempty"`
+
+	// RecordingAllowed is set when the room was created with recordings
+	// enabled, which is the only time DigitalSamba lets us allow them.
+	RecordingAllowed   bool   `json:"recording_allowed,omitempty"`
+	RecordingStartedAt int64  `json:"recording_started_at,omitempty"`
+	RecordingStartedBy string `json:"recording_started_by,omitempty"`
+	// RequireConsent makes users consent to recording before they get a
+	// token. ConsentMessage is the custom consent message, empty for the
+	// localized default.
+	RequireConsent bool   `json:"require_consent,omitempty"`
+	ConsentMessage string `json:"consent_message,omitempty"`
+
+	// Invitees are the other members of the DM or group channel the
+	// meeting rang.
+	Invitees   []string `json:"invitees,omitempty"`
+	Declined   []string `json:"declined,omitempty"`
+	AnsweredAt int64    `json:"answered_at,omitempty"`
+	Unanswered bool     `json:"unanswered,omitempty"`
+
+	ContentExportedAt int64  `json:"content_exported_at,omitempty"`
+	ReportedSessionID string `json:"reported_session_id,omitempty"`
+}
+
+func (p *Plugin) getMeetingRecord(roomID string) (*MeetingRecord, error) {
+	data, appErr := p.API.KVGet(meetingRecordKeyPrefix + roomID)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	if data == nil {
+		return nil, nil
+	}
+
+	var record MeetingRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		return nil, err
+	}
+
+	return &record, nil
+}
+
+// getPostActionMeeting returns the meeting a post action was made for. A
+// reused persistent or personal room belongs to its latest meeting, so the
+// buttons of an earlier meeting's posts find no meeting.
+func (p *Plugin) getPostActionMeeting(roomID, postID string) (*MeetingRecord, error) {
+	record, err := p.getMeetingRecord(roomID)
+	if err != nil || record == nil || record.PostID != postID {
+		return nil, err
+	}
+	return record, nil
+}
+
+func (p *Plugin) saveMeetingRecord(record *MeetingRecord) error {
+	b, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	if appErr := p.API.KVSet(meetingRecordKeyPrefix+record.RoomID, b); appErr != nil {
+		return appErr
+	}
+
+	return nil
+}
+
+// updateMeetingRecord applies a change to the stored meeting record and
+// copies the result into record. The change is applied to the latest stored
+// record and retried when another update raced it, so that commands and
+// DigitalSamba events don't lose each other's changes. update returns false
+// to leave the record as it is, and must not have other effects since it may
+// run more than once.
+func (p *Plugin) updateMeetingRecord(record *MeetingRecord, update func(record *MeetingRecord) bool) error {
+	key := meetingRecordKeyPrefix + record.RoomID
+
+	for i := 0; i < 5; i++ {
+		oldData, appErr := p.API.KVGet(key)
+		if appErr != nil {
+			return appErr
+		}
+		if oldData == nil {
+			return errors.New("the meeting no longer exists")
+		}
+
+		var current MeetingRecord
+		if err := json.Unmarshal(oldData, &current); err != nil {
+			return err
+		}
+
+		if !update(&current) {
+			*record = current
+			return nil
+		}
+
+		newData, err := json.Marshal(&current)
+		if err != nil {
+			return err
+		}
+
+		ok, appErr := p.API.KVCompareAndSet(key, oldData, newData)
+		if appErr != nil {
+			return appErr
+		}
+		if ok {
+			*record = current
+			return nil
+		}
+	}
+
+	return errors.New("too many concurrent updates to the meeting record")
+}
+
+// getChannelMeetings returns the IDs of the most recent rooms started in the
+// channel, oldest first.
+func (p *Plugin) getChannelMeetings(channelID string) ([]string, error) {
+	data, appErr := p.API.KVGet(channelMeetingsKeyPrefix + channelID)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	if data == nil {
+		return nil, nil
+	}
+
+	var roomIDs []string
+	if err := json.Unmarshal(data, &roomIDs); err != nil {
+		return nil, err
+	}
+
+	return roomIDs, nil
+}
+
+// addChannelMeeting appends a room to the channel's list of recent rooms.
+func (p *Plugin) addChannelMeeting(channelID, roomID string) error {
+	key := channelMeetingsKeyPrefix + channelID
+
+	for i := 0; i < 5; i++ {
+		oldData, appErr := p.API.KVGet(key)
+		if appErr != nil {
+			return appErr
+		}
+
+		var roomIDs []string
+		if oldData != nil {
+			if err := json.Unmarshal(oldData, &roomIDs); err != nil {
+				return err
+			}
+		}
+
+		for _, id := range roomIDs {
+			if id == roomID {
+				return nil
+			}
+		}
+
+		roomIDs = append(roomIDs, roomID)
+		if len(roomIDs) > maxChannelMeetings {
+			roomIDs = roomIDs[len(roomIDs)-maxChannelMeetings:]
+		}
+
+		newData, err := json.Marshal(roomIDs)
+		if err != nil {
+			return err
+		}
+
+		if bytes.Equal(oldData, newData) {
+			return nil
+		}
+
+		ok, appErr := p.API.KVCompareAndSet(key, oldData, newData)
+		if appErr != nil {
+			return appErr
+		}
+		if ok {
+			return nil
+		}
+	}
+
+	return errors.New("too many concurrent updates to the channel meeting list")
+}
+
+func (p *Plugin) meetingInfoFromRecord(record *MeetingRecord) *MeetingInfo {
+	info := &MeetingInfo{
+		MeetingID: record.MeetingID,
+		RoomID:    record.RoomID,
+		RoomURL:   record.MeetingURL,
+		TeamName:  p.getAccount(record.TeamID).teamName,
+		RoomName:  record.FriendlyURL,
+		ChannelID: record.ChannelID,
+		PostID:    record.PostID,
+		Topic:     record.Topic,
+		CreatorID: record.CreatorID,
+		CreatedAt: record.CreatedAt,
+		EndedAt:   record.EndedAt,
+		Status:    meetingStatus(record),
+		Private:   record.Private,
+		Recording: record.RecordingStartedAt != 0,
+	}
+	if record.PasscodeHash != "" {
+		info.GuestURL = p.guestJoinURL(record.RoomID)
+	}
+	return info
+}
+
+// meetingStatus is the status reported in MeetingInfo.Status.
+func meetingStatus(record *MeetingRecord) string {
+	if record.EndedAt != 0 {
+		return meetingStatusEnded
+	}
+	return meetingStatusActive
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+var teamNameRegexp = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]*[a-z0-9])?$`)
+
+// meetingURL builds the join URL of a room. The room URL returned by the API
+// wins; otherwise the URL is built from the account's team name
+// (https://TEAM.digitalsamba.com/ROOM) and then from its custom domain.
+func (a *digitalSambaAccount) meetingURL(room *Room) (string, error) {
+	if room.RoomURL != "" {
+		return room.RoomURL, nil
+	}
+
+	if a.teamName != "" {
+		return fmt.Sprintf("https://%s.digitalsamba.com/%s", a.teamName, room.FriendlyURL), nil
+	}
+
+	if a.customDomain != "" {
+		return fmt.Sprintf("%s/%s", a.customDomain, room.FriendlyURL), nil
+	}
+
+	return "", fmt.Errorf("the DigitalSamba API returned no room URL and neither a team name nor a custom domain is configured")
+}
+
+// normalizeCustomDomain turns a custom domain such as meet.example.com into a
+// base URL without a trailing slash. A missing scheme defaults to https.
+func normalizeCustomDomain(domain string) string {
+	domain = strings.TrimRight(strings.TrimSpace(domain), "/")
+	if domain == "" {
+		return ""
+	}
+
+	if !strings.HasPrefix(domain, "http://") && !strings.HasPrefix(domain, "https://") {
+		domain = "https://" + domain
+	}
+
+	return domain
+}
+
+// validateMeetingURLSettings checks the team name and custom domain used to
+// build meeting URLs when the API returns none. One of them is required, so
+// that such a room never gets a broken link.
+func validateMeetingURLSettings(teamName, customDomain string) error {
+	teamName = strings.TrimSpace(teamName)
+	customDomain = normalizeCustomDomain(customDomain)
+
+	if teamName == "" && customDomain == "" {
+		return fmt.Errorf("a DigitalSamba team name or a custom meeting domain is required to build meeting links")
+	}
+
+	if teamName != "" && !teamNameRegexp.MatchString(teamName) {
+		return fmt.Errorf("DigitalSamba team name %q is invalid; use the subdomain of your team, e.g. myteam for myteam.digitalsamba.com", teamName)
+	}
+
+	if customDomain != "" {
+		u, err := url.Parse(customDomain)
+		if err != nil || u.Host == "" || (u.Path != "" && u.Path != "/") || u.RawQuery != "" {
+			return fmt.Errorf("DigitalSamba custom domain %q is invalid; use a host name such as meet.example.com", customDomain)
+		}
+	}
+
+	return nil
+}
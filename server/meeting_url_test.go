@@ -0,0 +1,84 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateMeetingURLSettings(t *testing.T) {
+	tests := []struct {
+		name         string
+		teamName     string
+		customDomain string
+		wantErr      string
+	}{
+		{name: "team name", teamName: "myteam"},
+		{name: "custom domain", customDomain: "meet.example.com"},
+		{name: "both", teamName: "myteam", customDomain: "https://meet.example.com/"},
+		{name: "neither", teamName: " ", wantErr: "is required"},
+		{name: "invalid team name", teamName: "My Team", wantErr: "team name"},
+		{name: "custom domain with a path", customDomain: "meet.example.com/rooms", wantErr: "custom domain"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateMeetingURLSettings(tt.teamName, tt.customDomain)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("validateMeetingURLSettings(%q, %q) = %v, want no error", tt.teamName, tt.customDomain, err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("validateMeetingURLSettings(%q, %q) = %v, want an error containing %q", tt.teamName, tt.customDomain, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMeetingURL(t *testing.T) {
+	tests := []struct {
+		name         string
+		teamName     string
+		customDomain string
+		room         *Room
+		want         string
+	}{
+		{
+			name:     "room URL from the API",
+			teamName: "myteam",
+			room:     &Room{RoomURL: "https://other.digitalsamba.com/standup", FriendlyURL: "standup"},
+			want:     "https://other.digitalsamba.com/standup",
+		},
+		{
+			name:         "team name before custom domain",
+			teamName:     "myteam",
+			customDomain: "https://meet.example.com",
+			room:         &Room{FriendlyURL: "standup"},
+			want:         "https://myteam.digitalsamba.com/standup",
+		},
+		{
+			name:         "custom domain",
+			customDomain: "https://meet.example.com",
+			room:         &Room{FriendlyURL: "standup"},
+			want:         "https://meet.example.com/standup",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			account := &digitalSambaAccount{teamName: tt.teamName, customDomain: tt.customDomain}
+			got, err := account.meetingURL(tt.room)
+			if err != nil {
+				t.Fatalf("meetingURL() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("meetingURL() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := (&digitalSambaAccount{}).meetingURL(&Room{FriendlyURL: "standup"}); err == nil {
+		t.Error("meetingURL() without a room URL, team name or custom domain returned no error")
+	}
+}
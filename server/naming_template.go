@@ -0,0 +1,391 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+const defaultNamingTemplate = "{team}-{channel}-{rand:4}"
+
+const namingCounterKeyPrefix = "naming_counter_"
+
+var (
+	namingPlaceholderRegexp = regexp.MustCompile(`\{([a-z]+)(?::([^{}]*))?\}`)
+	friendlyURLRegexp       = regexp.MustCompile(`^[a-zA-Z0-9_-]*$`)
+)
+
+// namingContext is what a naming template can refer to.
+type namingContext struct {
+	Team      string
+	Channel   string
+	ChannelID string
+	User      string
+	Topic     string
+	Locale    string
+	Location  *time.Location
+	Now       time.Time
+
+	// Preview renders without consuming a counter value.
+	Preview bool
+}
+
+// namingPlaceholder renders one placeholder. Fixed placeholders always
+// render to the same length, which validation uses to check the 32
+// character limit.
+type namingPlaceholder struct {
+	render func(p *Plugin, ctx *namingContext, arg string) (string, error)
+
+	// fixedLength returns the rendered length, or -1 when it depends on
+	// the context.
+	fixedLength func(arg string) int
+}
+
+var namingPlaceholders = map[string]namingPlaceholder{
+	"team": {
+		render:      func(_ *Plugin, ctx *namingContext, _ string) (string, error) { return ctx.Team, nil },
+		fixedLength: variableLength,
+	},
+	"channel": {
+		render:      func(_ *Plugin, ctx *namingContext, _ string) (string, error) { return ctx.Channel, nil },
+		fixedLength: variableLength,
+	},
+	"user": {
+		render:      func(_ *Plugin, ctx *namingContext, _ string) (string, error) { return ctx.User, nil },
+		fixedLength: variableLength,
+	},
+	"topic": {
+		render:      func(_ *Plugin, ctx *namingContext, _ string) (string, error) { return ctx.Topic, nil },
+		fixedLength: variableLength,
+	},
+	"date": {
+		render: func(_ *Plugin, ctx *namingContext, arg string) (string, error) {
+			return ctx.Now.In(ctx.Location).Format(layoutOrDefault(arg, "2006-01-02")), nil
+		},
+		fixedLength: func(arg string) int { return maxLayoutLength(layoutOrDefault(arg, "2006-01-02")) },
+	},
+	"time": {
+		render: func(_ *Plugin, ctx *namingContext, arg string) (string, error) {
+			return ctx.Now.In(ctx.Location).Format(layoutOrDefault(arg, "1504")), nil
+		},
+		fixedLength: func(arg string) int { return maxLayoutLength(layoutOrDefault(arg, "1504")) },
+	},
+	"words": {
+		render: func(p *Plugin, ctx *namingContext, arg string) (string, error) {
+			n, err := placeholderCount(arg, 2, 4)
+			if err != nil {
+				return "", err
+			}
+			return p.generateWords(ctx.Locale, wordPatterns["adjective-noun-verb-adverb"][:n]), nil
+		},
+		fixedLength: variableLength,
+	},
+	"rand": {
+		render: func(_ *Plugin, _ *namingContext, arg string) (string, error) {
+			n, err := placeholderCount(arg, 4, 16)
+			if err != nil {
+				return "", err
+			}
+			return randomHex(n), nil
+		},
+		fixedLength: func(arg string) int {
+			n, _ := placeholderCount(arg, 4, 16)
+			return n
+		},
+	},
+	"counter": {
+		render: func(p *Plugin, ctx *namingContext, _ string) (string, error) {
+			n, err := p.nextNamingCounter(ctx.ChannelID, ctx.Preview)
+			if err != nil {
+				return "", err
+			}
+			return strconv.Itoa(n), nil
+		},
+		fixedLength: variableLength,
+	},
+}
+
+func variableLength(string) int {
+	return -1
+}
+
+// maxLayoutZone stands in for the time zone of a user, with the longest
+// rendering a zone can have: DigitalSamba meeting IDs drop the sign of
+// "+0545" but keep the digits.
+var maxLayoutZone = time.FixedZone("", 5*3600+45*60)
+
+// maxLayoutLength returns the longest a time layout renders to, which
+// depends on the names of months and weekdays, on numbers without padding
+// and on the zone. The calendar repeats every 28 years, so rendering every
+// day of 28 years at the last nanosecond before 1 PM covers every
+// combination of month, weekday and day.
+func maxLayoutLength(layout string) int {
+	maxLength := 0
+	start := time.Date(2001, 1, 1, 12, 59, 59, 999999999, maxLayoutZone)
+	for day := start; day.Year() < 2029; day = day.AddDate(0, 0, 1) {
+		maxLength = max(maxLength, meetingIDLength(day.Format(layout)))
+	}
+	return maxLength
+}
+
+// meetingIDLength is the length of the value once encodeDigitalSambaMeetingID
+// dropped the characters a meeting ID cannot have.
+func meetingIDLength(value string) int {
+	n := 0
+	for _, c := range value {
+		if c == ' ' || c == '-' || c == '_' || c < unicode.MaxASCII && (unicode.IsLetter(c) || unicode.IsDigit(c)) {
+			n++
+		}
+	}
+	return n
+}
+
+func layoutOrDefault(layout, defaultLayout string) string {
+	if layout == "" {
+		return defaultLayout
+	}
+	return layout
+}
+
+func placeholderCount(arg string, defaultCount, maxCount int) (int, error) {
+	if arg == "" {
+		return defaultCount, nil
+	}
+
+	n, err := strconv.Atoi(arg)
+	if err != nil || n < 1 || n > maxCount {
+		return 0, fmt.Errorf("count must be a number from 1 to %d", maxCount)
+	}
+
+	return n, nil
+}
+
+// validateNamingTemplate checks that the template only uses known
+// placeholders with valid arguments, that its literal text is allowed in a
+// friendly URL and that its fixed parts leave room within the 32 character
+// limit.
+func validateNamingTemplate(template string) error {
+	if strings.TrimSpace(template) == "" {
+		return fmt.Errorf("naming template cannot be empty")
+	}
+
+	fixedLength := 0
+	variable := false
+	for _, part := range splitNamingTemplate(template) {
+		if part.name == "" {
+			if !friendlyURLRegexp.MatchString(part.text) {
+				return fmt.Errorf("naming template text %q can only contain letters, digits, '-' and '_'", part.text)
+			}
+			fixedLength += len(part.text)
+			continue
+		}
+
+		placeholder, ok := namingPlaceholders[part.name]
+		if !ok {
+			return fmt.Errorf("unknown naming template placeholder {%s}", part.name)
+		}
+
+		switch part.name {
+		case "date", "time":
+			// An empty layout falls back to a default that is always valid
+			if part.arg != "" && !friendlyURLRegexp.MatchString(time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC).Format(part.arg)) {
+				return fmt.Errorf("the layout of {%s:%s} can only produce letters, digits, '-' and '_'", part.name, part.arg)
+			}
+		case "words":
+			if _, err := placeholderCount(part.arg, 2, 4); err != nil {
+				return fmt.Errorf("invalid {words:%s}: %w", part.arg, err)
+			}
+		case "rand":
+			if _, err := placeholderCount(part.arg, 4, 16); err != nil {
+				return fmt.Errorf("invalid {rand:%s}: %w", part.arg, err)
+			}
+		}
+
+		if n := placeholder.fixedLength(part.arg); n >= 0 {
+			fixedLength += n
+		} else {
+			variable = true
+		}
+	}
+
+	if fixedLength > maxFriendlyURLLength || (variable && fixedLength >= maxFriendlyURLLength) {
+		return fmt.Errorf("naming template is too long, meeting names are limited to %d characters", maxFriendlyURLLength)
+	}
+
+	return nil
+}
+
+type namingTemplatePart struct {
+	text string
+	name string
+	arg  string
+}
+
+// splitNamingTemplate splits a template into literal text and placeholders.
+func splitNamingTemplate(template string) []namingTemplatePart {
+	var parts []namingTemplatePart
+	last := 0
+	for _, match := range namingPlaceholderRegexp.FindAllStringSubmatchIndex(template, -1) {
+		if match[0] > last {
+			parts = append(parts, namingTemplatePart{text: template[last:match[0]]})
+		}
+		part := namingTemplatePart{name: template[match[2]:match[3]]}
+		if match[4] >= 0 {
+			part.arg = template[match[4]:match[5]]
+		}
+		parts = append(parts, part)
+		last = match[1]
+	}
+	if last < len(template) {
+		parts = append(parts, namingTemplatePart{text: template[last:]})
+	}
+
+	return parts
+}
+
+// renderNamingTemplate fills in the template. Placeholder values are reduced
+// to the friendly URL charset.
+func (p *Plugin) renderNamingTemplate(template string, ctx *namingContext) (string, error) {
+	var sb strings.Builder
+	for _, part := range splitNamingTemplate(template) {
+		if part.name == "" {
+			sb.WriteString(part.text)
+			continue
+		}
+
+		placeholder, ok := namingPlaceholders[part.name]
+		if !ok {
+			return "", fmt.Errorf("unknown naming template placeholder {%s}", part.name)
+		}
+
+		value, err := placeholder.render(p, ctx, part.arg)
+		if err != nil {
+			return "", fmt.Errorf("failed to render {%s}: %w", part.name, err)
+		}
+		sb.WriteString(encodeDigitalSambaMeetingID(value))
+	}
+
+	return sb.String(), nil
+}
+
+// newNamingContext collects the values a template can use for a meeting
+// started by the user in the channel.
+func (p *Plugin) newNamingContext(user *model.User, channel *model.Channel, topic string) *namingContext {
+	ctx := &namingContext{
+		ChannelID: channel.Id,
+		User:      user.Username,
+		Topic:     topic,
+		Locale:    user.Locale,
+		Location:  time.UTC,
+		Now:       time.Now(),
+	}
+
+	if location, err := time.LoadLocation(user.GetPreferredTimezone()); err == nil {
+		ctx.Location = location
+	}
+
+	if channel.IsGroupOrDirect() {
+		ctx.Channel = "dm"
+	} else {
+		ctx.Channel = channel.Name
+	}
+
+	if channel.TeamId != "" {
+		if team, appErr := p.API.GetTeam(channel.TeamId); appErr == nil {
+			ctx.Team = team.Name
+		}
+	}
+
+	return ctx
+}
+
+// nextNamingCounter returns the next value of the channel's meeting counter.
+// A preview returns the value without consuming it.
+func (p *Plugin) nextNamingCounter(channelID string, preview bool) (int, error) {
+	key := namingCounterKeyPrefix + channelID
+	for i := 0; i < 5; i++ {
+		oldData, appErr := p.API.KVGet(key)
+		if appErr != nil {
+			return 0, appErr
+		}
+
+		count := 0
+		if oldData != nil {
+			if err := json.Unmarshal(oldData, &count); err != nil {
+				return 0, err
+			}
+		}
+		count++
+
+		if preview {
+			return count, nil
+		}
+
+		newData, err := json.Marshal(count)
+		if err != nil {
+			return 0, err
+		}
+
+		if ok, appErr := p.API.KVCompareAndSet(key, oldData, newData); appErr != nil {
+			return 0, appErr
+		} else if ok {
+			return count, nil
+		}
+	}
+
+	return 0, fmt.Errorf("too many concurrent updates")
+}
+
+func randomHex(n int) string {
+	b := make([]byte, (n+1)/2)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)[:n]
+}
+
+// runPreviewNamingSchemesCommand shows a meeting name of each naming scheme
+// as it would be generated in the current channel.
+func (p *Plugin) runPreviewNamingSchemesCommand(args *model.CommandArgs) (*model.CommandResponse, *model.AppError) {
+	user, appErr := p.API.GetUser(args.UserId)
+	if appErr != nil {
+		return p.sendEphemeralResponse(args, "Failed to get user information")
+	}
+
+	channel, appErr := p.API.GetChannel(args.ChannelId)
+	if appErr != nil {
+		return p.sendEphemeralResponse(args, "Failed to get channel information")
+	}
+
+	current := p.resolveMeetingSettings(user.Id, channel).NamingScheme
+
+	var sb strings.Builder
+	sb.WriteString("Meeting names in this channel:\n")
+	for _, scheme := range validNamingSchemes {
+		if scheme == digitalSambaNameSchemeAsk {
+			continue
+		}
+
+		name := p.generateMeetingName(scheme, user, channel, "Weekly Sync", true)
+		fmt.Fprintf(&sb, "* %s: `%s`", scheme, name)
+		if scheme == digitalSambaNameSchemeTemplate {
+			fmt.Fprintf(&sb, " from `%s`", p.getConfiguration().GetNamingTemplate())
+		}
+		if friendlyURL := shortenFriendlyURL(name); friendlyURL != name {
+			fmt.Fprintf(&sb, " (shortened to `%s`)", friendlyURL)
+		}
+		if scheme == current {
+			sb.WriteString(" **(current)**")
+		}
+		sb.WriteString("\n")
+	}
+	sb.WriteString("\nPlaceholders use the topic \"Weekly Sync\". Set your naming scheme with `/digitalsamba settings naming_scheme [scheme]`.")
+
+	return p.sendEphemeralResponse(args, sb.String())
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSplitNamingTemplate(t *testing.T) {
+	tests := []struct {
+		template string
+		want     []namingTemplatePart
+	}{
+		{template: "", want: nil},
+		{template: "standup", want: []namingTemplatePart{{text: "standup"}}},
+		{
+			template: "{team}-{channel}-{rand:4}",
+			want: []namingTemplatePart{
+				{name: "team"},
+				{text: "-"},
+				{name: "channel"},
+				{text: "-"},
+				{name: "rand", arg: "4"},
+			},
+		},
+		{
+			template: "daily_{date:20060102}{time}",
+			want: []namingTemplatePart{
+				{text: "daily_"},
+				{name: "date", arg: "20060102"},
+				{name: "time"},
+			},
+		},
+		{
+			template: "{date:}x",
+			want:     []namingTemplatePart{{name: "date"}, {text: "x"}},
+		},
+		{
+			// Only lowercase names are placeholders, the rest is text
+			template: "{Team}-{a{b}}",
+			want: []namingTemplatePart{
+				{text: "{Team}-{a"},
+				{name: "b"},
+				{text: "}"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.template, func(t *testing.T) {
+			if got := splitNamingTemplate(tt.template); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("splitNamingTemplate(%q) = %+v, want %+v", tt.template, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateNamingTemplate(t *testing.T) {
+	tests := []struct {
+		template string
+		wantErr  string
+	}{
+		{template: defaultNamingTemplate},
+		{template: "{team}-{channel}-{date}-{time}"},
+		{template: "{words:4}-{counter}"},
+		{template: "{date:Jan02}-{rand:16}"},
+		{template: "   ", wantErr: "cannot be empty"},
+		{template: "{team}.{channel}", wantErr: "can only contain"},
+		{template: "{unknown}", wantErr: "unknown naming template placeholder"},
+		{template: "{words:5}", wantErr: "invalid {words:5}"},
+		{template: "{rand:x}", wantErr: "invalid {rand:x}"},
+		{template: "{date:2006/01/02}", wantErr: "can only produce"},
+		{template: strings.Repeat("a", 32)},
+		{template: strings.Repeat("a", 33), wantErr: "too long"},
+		{template: strings.Repeat("a", 31) + "{team}"},
+		{template: strings.Repeat("a", 32) + "{team}", wantErr: "too long"},
+		// "September" is 9 characters, not the 7 of "January"
+		{template: strings.Repeat("a", 23) + "{date:January}"},
+		{template: strings.Repeat("a", 24) + "{date:January}", wantErr: "too long"},
+		// "Wednesday" and "September" are the longest names
+		{template: strings.Repeat("a", 13) + "{date:Monday-January}"},
+		{template: strings.Repeat("a", 14) + "{date:Monday-January}", wantErr: "too long"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.template, func(t *testing.T) {
+			err := validateNamingTemplate(tt.template)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("validateNamingTemplate(%q) = %v, want no error", tt.template, err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("validateNamingTemplate(%q) = %v, want an error containing %q", tt.template, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMaxLayoutLength(t *testing.T) {
+	tests := []struct {
+		layout string
+		want   int
+	}{
+		{layout: "2006-01-02", want: 10},
+		{layout: "1504", want: 4},
+		{layout: "Jan", want: 3},
+		{layout: "January", want: 9},
+		{layout: "Monday", want: 9},
+		{layout: "Mon", want: 3},
+		{layout: "1-2", want: 5},
+		{layout: "3PM", want: 4},
+		{layout: "MST", want: 4},
+		{layout: "Monday-January-2", want: 22},
+		{layout: "Jan 2", want: 6},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.layout, func(t *testing.T) {
+			if got := maxLayoutLength(tt.layout); got != tt.want {
+				t.Errorf("maxLayoutLength(%q) = %d, want %d", tt.layout, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderNamingTemplate(t *testing.T) {
+	ctx := &namingContext{
+		Team:     "eng",
+		Channel:  "town square",
+		User:     "alice",
+		Topic:    "Q3 plan!",
+		Location: time.UTC,
+		Now:      time.Date(2024, 9, 4, 9, 5, 0, 0, time.UTC),
+	}
+
+	tests := []struct {
+		template string
+		want     string
+	}{
+		{template: "{team}-{channel}", want: "eng-town-square"},
+		{template: "{user}_{topic}", want: "alice_Q3-plan"},
+		{template: "{date}-{time}", want: "2024-09-04-0905"},
+		{template: "{date:Monday}", want: "Wednesday"},
+	}
+
+	var p *Plugin
+	for _, tt := range tests {
+		t.Run(tt.template, func(t *testing.T) {
+			got, err := p.renderNamingTemplate(tt.template, ctx)
+			if err != nil {
+				t.Fatalf("renderNamingTemplate(%q) error = %v", tt.template, err)
+			}
+			if got != tt.want {
+				t.Errorf("renderNamingTemplate(%q) = %q, want %q", tt.template, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,410 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"math/big"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/gorilla/mux"
+	"github.com/mattermost/mattermost/server/public/model"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const passcodeAttemptsKeyPrefix = "passcode_attempts_"
+
+const (
+	minPasscodeLength       = 4
+	maxPasscodeLength       = 32
+	generatedPasscodeLength = 6
+)
+
+// A client is locked out of a room after maxPasscodeAttempts wrong passcodes.
+// The room itself is locked for everyone after maxRoomPasscodeAttempts, so
+// that changing addresses does not help.
+const (
+	maxPasscodeAttempts     = 5
+	maxRoomPasscodeAttempts = 50
+	passcodeLockout         = 15 * time.Minute
+)
+
+// maxGuestNameLength bounds the name a guest enters on the join page.
+const maxGuestNameLength = 64
+
+// passcodeAttempts counts the wrong passcodes entered within the lockout
+// window.
+type passcodeAttempts struct {
+	Failures    int   `json:"failures"`
+	LockedUntil int64 `json:"locked_until,omitempty"`
+}
+
+// validatePasscode checks a passcode chosen by a user.
+func validatePasscode(passcode string) error {
+	if len(passcode) < minPasscodeLength || len(passcode) > maxPasscodeLength {
+		return fmt.Errorf("the passcode must have between %d and %d characters", minPasscodeLength, maxPasscodeLength)
+	}
+	if strings.IndexFunc(passcode, unicode.IsSpace) >= 0 {
+		return fmt.Errorf("the passcode must not contain spaces")
+	}
+	return nil
+}
+
+func hashPasscode(passcode string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(passcode), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+func checkPasscode(hash, passcode string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(passcode)) == nil
+}
+
+// generatePasscode returns a random numeric passcode, easy to read out on a
+// call.
+func generatePasscode() string {
+	var sb strings.Builder
+	for i := 0; i < generatedPasscodeLength; i++ {
+		n, err := rand.Int(rand.Reader, big.NewInt(10))
+		if err != nil {
+			n = big.NewInt(int64(time.Now().UnixNano() % 10))
+		}
+		sb.WriteByte(byte('0' + n.Int64()))
+	}
+	return sb.String()
+}
+
+// isPasscodeTemplate reports whether rooms with the template always need a
+// passcode.
+func (p *Plugin) isPasscodeTemplate(templateID string) bool {
+	return templateID != "" && slices.Contains(p.getConfiguration().GetPasscodeTemplates(), templateID)
+}
+
+// guestJoinURL is the page where people outside Mattermost enter the
+// passcode of a meeting.
+func (p *Plugin) guestJoinURL(roomID string) string {
+	return fmt.Sprintf("%s/plugins/digitalsamba/join/%s", *p.API.GetConfig().ServiceSettings.SiteURL, url.PathEscape(roomID))
+}
+
+// clientAddress identifies the client entering a passcode. The address is
+// hashed, so that the KV store never holds it. Only the remote address
+// counts: Mattermost already resolved it from its trusted proxies, while the
+// X-Forwarded-For header is whatever the client sent.
+func clientAddress(r *http.Request) string {
+	address := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(address); err == nil {
+		address = host
+	}
+
+	sum := sha256.Sum256([]byte(address))
+	return hex.EncodeToString(sum[:8])
+}
+
+func passcodeAttemptsKey(roomID, client string) string {
+	return passcodeAttemptsKeyPrefix + roomID + "_" + client
+}
+
+func (p *Plugin) getPasscodeAttempts(key string) (*passcodeAttempts, []byte, error) {
+	data, appErr := p.API.KVGet(key)
+	if appErr != nil {
+		return nil, nil, appErr
+	}
+
+	attempts := &passcodeAttempts{}
+	if data != nil {
+		if err := json.Unmarshal(data, attempts); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return attempts, data, nil
+}
+
+// isPasscodeLocked reports whether the client or the whole room is locked out.
+func (p *Plugin) isPasscodeLocked(roomID, client string) bool {
+	now := model.GetMillis()
+	for _, key := range []string{passcodeAttemptsKey(roomID, client), passcodeAttemptsKey(roomID, "")} {
+		attempts, _, err := p.getPasscodeAttempts(key)
+		if err != nil {
+			p.API.LogWarn("Failed to get passcode attempts", "room_id", roomID, "error", err.Error())
+			// Fail closed, a broken store must not open the room
+			return true
+		}
+		if attempts.LockedUntil > now {
+			return true
+		}
+	}
+	return false
+}
+
+// recordPasscodeFailure counts a wrong passcode for the client and the room.
+// It reports whether the client is now locked out.
+func (p *Plugin) recordPasscodeFailure(roomID, client string) (bool, error) {
+	locked, err := p.incrementPasscodeFailures(passcodeAttemptsKey(roomID, client), maxPasscodeAttempts)
+	if err != nil {
+		return false, err
+	}
+
+	roomLocked, err := p.incrementPasscodeFailures(passcodeAttemptsKey(roomID, ""), maxRoomPasscodeAttempts)
+	if err != nil {
+		return false, err
+	}
+
+	return locked || roomLocked, nil
+}
+
+func (p *Plugin) incrementPasscodeFailures(key string, limit int) (bool, error) {
+	for i := 0; i < 5; i++ {
+		attempts, oldData, err := p.getPasscodeAttempts(key)
+		if err != nil {
+			return false, err
+		}
+
+		attempts.Failures++
+		if attempts.Failures >= limit {
+			attempts.LockedUntil = model.GetMillis() + passcodeLockout.Milliseconds()
+		}
+
+		newData, err := json.Marshal(attempts)
+		if err != nil {
+			return false, err
+		}
+
+		ok, appErr := p.API.KVSetWithOptions(key, newData, model.PluginKVSetOptions{
+			Atomic:          true,
+			OldValue:        oldData,
+			ExpireInSeconds: int64(passcodeLockout.Seconds()),
+		})
+		if appErr != nil {
+			return false, appErr
+		}
+		if ok {
+			return attempts.LockedUntil != 0, nil
+		}
+	}
+
+	return false, fmt.Errorf("too many concurrent updates")
+}
+
+func (p *Plugin) clearPasscodeFailures(roomID, client string) {
+	if appErr := p.API.KVDelete(passcodeAttemptsKey(roomID, client)); appErr != nil {
+		p.API.LogWarn("Failed to clear passcode attempts", "room_id", roomID, "error", appErr.Error())
+	}
+}
+
+var guestJoinPage = template.Must(template.New("join").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Topic}}</title>
+<style>
+body { font-family: "Open Sans", sans-serif; background: #f4f4f6; color: #3f4350; display: flex; justify-content: center; padding-top: 10vh; }
+form { background: #fff; border-radius: 8px; box-shadow: 0 2px 8px rgba(0,0,0,.12); padding: 32px; width: 320px; }
+h1 { font-size: 20px; margin: 0 0 24px; }
+label { display: block; font-size: 14px; margin-bottom: 4px; }
+input { box-sizing: border-box; width: 100%; padding: 8px; margin-bottom: 16px; border: 1px solid #c4c5cc; border-radius: 4px; font-size: 14px; }
+button { width: 100%; padding: 10px; border: 0; border-radius: 4px; background: #1c58d9; color: #fff; font-size: 14px; cursor: pointer; }
+.error { color: #d24b4e; font-size: 14px; margin-bottom: 16px; }
+.consent { display: flex; gap: 8px; align-items: flex-start; }
+.consent input { width: auto; margin: 2px 0 0; }
+</style>
+</head>
+<body>
+<form method="post">
+<h1>{{.Topic}}</h1>
+{{if .Error}}<div class="error">{{.Error}}</div>{{end}}
+{{if .Open}}
+{{if .Invite}}<input name="invite" type="hidden" value="{{.Invite}}">{{else}}
+<label for="name">Your name</label>
+<input id="name" name="name" value="{{.Name}}" maxlength="64" required autofocus>
+<label for="passcode">Passcode</label>
+<input id="passcode" name="passcode" type="password" maxlength="32" required>
+{{end}}
+{{if .ConsentMessage}}<label class="consent"><input name="consent" type="checkbox" required>{{.ConsentMessage}}</label>{{end}}
+<button type="submit">Join meeting</button>
+{{end}}
+</form>
+</body>
+</html>
+`))
+
+type guestJoinPageData struct {
+	Topic string
+	Name  string
+	Error string
+	Open  bool
+	// ConsentMessage asks the guest to consent to recording.
+	ConsentMessage string
+	// Invite is the guest's invite to a meeting without a passcode. The
+	// guest only consents, their name is the one of the invite.
+	Invite string
+}
+
+// guestConsentMessage is the consent message of the join page, if the
+// meeting asks for consent. Guests have no Mattermost locale.
+func (p *Plugin) guestConsentMessage(record *MeetingRecord) string {
+	if !record.RequireConsent {
+		return ""
+	}
+	return p.recordingConsentMessage(p.b.GetServerLocalizer(), record.ConsentMessage)
+}
+
+func writeGuestJoinPage(w http.ResponseWriter, status int, data *guestJoinPageData) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Frame-Options", "DENY")
+	w.WriteHeader(status)
+	_ = guestJoinPage.Execute(w, data)
+}
+
+// getGuestMeeting loads the meeting of the join page, which is passcode
+// protected or the guest has an invite to. It writes the page itself when
+// the meeting cannot be joined.
+func (p *Plugin) getGuestMeeting(w http.ResponseWriter, r *http.Request) (*MeetingRecord, *GuestInvite, bool) {
+	roomID := mux.Vars(r)["id"]
+	record, err := p.getMeetingRecord(roomID)
+	var invite *GuestInvite
+	if err == nil && record != nil && record.PasscodeHash == "" {
+		invite, err = p.getGuestInvite(roomID, r.FormValue("invite"))
+	}
+	if err != nil {
+		p.API.LogError("Failed to get meeting", "error", err.Error())
+		writeGuestJoinPage(w, http.StatusInternalServerError, &guestJoinPageData{Topic: "Meeting", Error: "Something went wrong. Please try again later."})
+		return nil, nil, false
+	}
+
+	// Meetings without a passcode are joined with invite links only
+	if record == nil || (record.PasscodeHash == "" && invite == nil) {
+		writeGuestJoinPage(w, http.StatusNotFound, &guestJoinPageData{Topic: "Meeting", Error: "This meeting does not exist."})
+		return nil, nil, false
+	}
+
+	if record.EndedAt != 0 {
+		writeGuestJoinPage(w, http.StatusGone, &guestJoinPageData{Topic: record.Topic, Error: "This meeting has ended."})
+		return nil, nil, false
+	}
+
+	return record, invite, true
+}
+
+func (p *Plugin) handleGuestJoinPage(w http.ResponseWriter, r *http.Request) {
+	record, invite, ok := p.getGuestMeeting(w, r)
+	if !ok {
+		return
+	}
+
+	data := &guestJoinPageData{
+		Topic:          record.Topic,
+		Name:           r.URL.Query().Get("name"),
+		Open:           true,
+		ConsentMessage: p.guestConsentMessage(record),
+	}
+	if invite != nil {
+		data.Invite = r.URL.Query().Get("invite")
+	}
+
+	writeGuestJoinPage(w, http.StatusOK, data)
+}
+
+// handleGuestJoin checks the passcode entered on the join page and sends the
+// guest into the meeting with an attendee token.
+func (p *Plugin) handleGuestJoin(w http.ResponseWriter, r *http.Request) {
+	record, invite, ok := p.getGuestMeeting(w, r)
+	if !ok {
+		return
+	}
+
+	if invite != nil {
+		p.joinInvitedGuest(w, r, record, invite)
+		return
+	}
+
+	name := strings.TrimSpace(r.PostFormValue("name"))
+	passcode := r.PostFormValue("passcode")
+	data := &guestJoinPageData{Topic: record.Topic, Name: name, Open: true, ConsentMessage: p.guestConsentMessage(record)}
+
+	client := clientAddress(r)
+	if p.isPasscodeLocked(record.RoomID, client) {
+		data.Error = "Too many wrong passcodes. Please try again later."
+		data.Open = false
+		writeGuestJoinPage(w, http.StatusTooManyRequests, data)
+		return
+	}
+
+	if name == "" || len(name) > maxGuestNameLength {
+		data.Error = "Please enter your name."
+		writeGuestJoinPage(w, http.StatusBadRequest, data)
+		return
+	}
+
+	if !checkPasscode(record.PasscodeHash, passcode) {
+		locked, err := p.recordPasscodeFailure(record.RoomID, client)
+		if err != nil {
+			p.API.LogWarn("Failed to count passcode failure", "room_id", record.RoomID, "error", err.Error())
+		}
+		if locked {
+			p.audit(auditActionPasscodeLockout, "", record.ChannelID, record.RoomID, map[string]string{"client": client})
+			data.Error = "Too many wrong passcodes. Please try again later."
+			data.Open = false
+			writeGuestJoinPage(w, http.StatusTooManyRequests, data)
+			return
+		}
+		data.Error = "Wrong passcode."
+		writeGuestJoinPage(w, http.StatusForbidden, data)
+		return
+	}
+
+	p.clearPasscodeFailures(record.RoomID, client)
+
+	p.sendGuestIn(w, r, record, name, data)
+}
+
+// joinInvitedGuest sends a guest with an invite to a meeting asking for
+// consent into it, once they consented.
+func (p *Plugin) joinInvitedGuest(w http.ResponseWriter, r *http.Request, record *MeetingRecord, invite *GuestInvite) {
+	data := &guestJoinPageData{
+		Topic:          record.Topic,
+		Open:           true,
+		ConsentMessage: p.guestConsentMessage(record),
+		Invite:         r.PostFormValue("invite"),
+	}
+
+	p.sendGuestIn(w, r, record, invite.Name, data)
+}
+
+// sendGuestIn checks the guest's consent and redirects them into the meeting
+// with an attendee token.
+func (p *Plugin) sendGuestIn(w http.ResponseWriter, r *http.Request, record *MeetingRecord, name string, data *guestJoinPageData) {
+	consented := false
+	if data.ConsentMessage != "" {
+		if !guestConsentGiven(r) {
+			data.Error = "Please consent to recording to join."
+			writeGuestJoinPage(w, http.StatusBadRequest, data)
+			return
+		}
+		p.audit(auditActionRecordingConsent, "", record.ChannelID, p.consentMeeting(record).RoomID, map[string]string{"guest_name": name})
+		consented = true
+	}
+
+	invite, err := p.createInvite(record, name, consented)
+	if err != nil {
+		p.API.LogError("Failed to create guest token", "room_id", record.RoomID, "error", err.Error())
+		data.Error = "Something went wrong. Please try again later."
+		writeGuestJoinPage(w, http.StatusInternalServerError, data)
+		return
+	}
+
+	http.Redirect(w, r, invite.URL, http.StatusSeeOther)
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientAddressIgnoresForwardedFor(t *testing.T) {
+	r := httptest.NewRequest("POST", "/join/room", nil)
+	r.RemoteAddr = "203.0.113.7:51234"
+	want := clientAddress(r)
+
+	r.Header.Set("X-Forwarded-For", "198.51.100.1")
+	if got := clientAddress(r); got != want {
+		t.Errorf("clientAddress() = %q with X-Forwarded-For, want %q", got, want)
+	}
+
+	// Another port of the same address is the same client
+	r.RemoteAddr = "203.0.113.7:40000"
+	if got := clientAddress(r); got != want {
+		t.Errorf("clientAddress() = %q for another port, want %q", got, want)
+	}
+
+	r.RemoteAddr = "203.0.113.8:51234"
+	if got := clientAddress(r); got == want {
+		t.Errorf("clientAddress() = %q for another address, want a different client", got)
+	}
+}
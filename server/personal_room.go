@@ -0,0 +1,422 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin"
+)
+
+const personalRoomKeyPrefix = "personal_room_"
+
+// rotatedLinkRandomLength is the number of random hex characters in a
+// rotated personal room link, 80 bits that nobody can guess. The username
+// keeps the rest of the 32 characters.
+const rotatedLinkRandomLength = 20
+
+const personalRoomCommandUsage = "Use `/digitalsamba me [topic]` to start a meeting in your personal room, `/digitalsamba me link` to get its link, `/digitalsamba me rotate` to replace its link or `/digitalsamba me settings [lobby|recording|passcode] [value]` to change its settings."
+
+// PersonalRoom is the DigitalSamba room kept for one user. It lives in the
+// default account, so that its link works from every team.
+type PersonalRoom struct {
+	RoomID      string               `json:"room_id,omitempty"`
+	FriendlyURL string               `json:"friendly_url,omitempty"`
+	CreatedAt   int64                `json:"created_at,omitempty"`
+	Settings    PersonalRoomSettings `json:"settings"`
+
+	// DeleteRequestedAt is set while the room of a deactivated user could
+	// not be deleted in DigitalSamba. The retention job tries again.
+	DeleteRequestedAt int64 `json:"delete_requested_at,omitempty"`
+}
+
+// PersonalRoomSettings are the user's own settings for their personal room.
+// Unset fields fall through to the server configuration.
+type PersonalRoomSettings struct {
+	// Lobby makes the room private, so that only people with a
+	// Mattermost-issued token can enter.
+	Lobby     bool  `json:"lobby,omitempty"`
+	Recording *bool `json:"recording,omitempty"`
+
+	// PasscodeHash is the bcrypt hash of the passcode guests enter on the
+	// join page of the user's personal meetings.
+	PasscodeHash string `json:"passcode_hash,omitempty"`
+}
+
+// getPersonalRoom returns the user's personal room. A user who never used
+// one gets an empty room without a DigitalSamba room ID.
+func (p *Plugin) getPersonalRoom(userID string) (*PersonalRoom, error) {
+	data, appErr := p.API.KVGet(personalRoomKeyPrefix + userID)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	if data == nil {
+		return &PersonalRoom{}, nil
+	}
+
+	var room PersonalRoom
+	if err := json.Unmarshal(data, &room); err != nil {
+		return nil, err
+	}
+
+	return &room, nil
+}
+
+func (p *Plugin) setPersonalRoom(userID string, room *PersonalRoom) error {
+	b, err := json.Marshal(room)
+	if err != nil {
+		return err
+	}
+
+	if appErr := p.API.KVSet(personalRoomKeyPrefix+userID, b); appErr != nil {
+		return appErr
+	}
+
+	return nil
+}
+
+// applyPersonalRoomSettings overrides the resolved meeting settings with the
+// settings of the user's personal room.
+func applyPersonalRoomSettings(settings *meetingSettings, personalSettings PersonalRoomSettings) {
+	settings.PersistentRoom = false
+	if personalSettings.Lobby || personalSettings.PasscodeHash != "" {
+		settings.GuestAccess = false
+	}
+	if personalSettings.Recording != nil {
+		settings.RecordingEnabled = *personalSettings.Recording
+	}
+}
+
+// personalMeetingSettings resolves the settings of a meeting in the user's
+// personal room.
+func (p *Plugin) personalMeetingSettings(userID string, personalRoom *PersonalRoom) *meetingSettings {
+	settings := p.resolveMeetingSettings(userID, nil)
+	applyPersonalRoomSettings(settings, personalRoom.Settings)
+	return settings
+}
+
+// isRoomNotFound reports whether DigitalSamba answered that the room does
+// not exist.
+func isRoomNotFound(err error) bool {
+	var apiErr *DigitalSambaAPIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound
+}
+
+// getOrCreatePersonalRoom returns the user's personal room, creating it the
+// first time or when the stored room no longer exists in DigitalSamba. Any
+// other failure to get the room is returned, so that a passing API outage
+// never replaces the user's link.
+func (p *Plugin) getOrCreatePersonalRoom(user *model.User, personalRoom *PersonalRoom, createRoomReq *CreateRoomRequest) (*Room, error) {
+	account := p.getAccount("")
+
+	if personalRoom.RoomID != "" {
+		room, getErr := account.client.GetRoom(personalRoom.RoomID)
+		if getErr == nil {
+			return room, nil
+		}
+		if !isRoomNotFound(getErr) {
+			return nil, fmt.Errorf("failed to get the personal room: %w", getErr)
+		}
+		p.API.LogWarn("Personal room is gone, creating a new one", "user_id", user.Id, "room_id", personalRoom.RoomID)
+	}
+
+	if personalRoom.FriendlyURL != "" {
+		createRoomReq.FriendlyURL = personalRoom.FriendlyURL
+	} else {
+		createRoomReq.FriendlyURL = generatePersonalMeetingName(user.Username)
+	}
+	createRoomReq.ExpiresAt = nil
+
+	room, err := createRoomWithFriendlyURL(account.client, createRoomReq)
+	if err != nil {
+		return nil, err
+	}
+
+	personalRoom.RoomID = room.ID
+	personalRoom.FriendlyURL = room.FriendlyURL
+	personalRoom.CreatedAt = model.GetMillis()
+	if err := p.setPersonalRoom(user.Id, personalRoom); err != nil {
+		p.API.LogWarn("Failed to store personal room", "user_id", user.Id, "error", err.Error())
+	}
+
+	return room, nil
+}
+
+// ensurePersonalRoom returns the user's personal room, creating it with the
+// user's settings when needed.
+func (p *Plugin) ensurePersonalRoom(user *model.User) (*PersonalRoom, *Room, error) {
+	personalRoom, err := p.getPersonalRoom(user.Id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	settings := p.personalMeetingSettings(user.Id, personalRoom)
+	createRoomReq := newCreateRoomRequest(fmt.Sprintf("%s's Meeting", user.GetDisplayName(model.ShowNicknameFullName)), "", settings)
+
+	room, err := p.getOrCreatePersonalRoom(user, personalRoom, createRoomReq)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return personalRoom, room, nil
+}
+
+// rotatePersonalRoom replaces the user's personal room with a new room under
+// a new, unguessable link. The old link stops working.
+func (p *Plugin) rotatePersonalRoom(user *model.User) (*Room, error) {
+	personalRoom, err := p.getPersonalRoom(user.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	oldRoomID := personalRoom.RoomID
+	if oldRoomID != "" {
+		if err := p.getAccount("").client.DeleteRoom(oldRoomID); err != nil && !isRoomNotFound(err) {
+			return nil, fmt.Errorf("failed to delete the old room: %w", err)
+		}
+	}
+
+	personalRoom.RoomID = ""
+	personalRoom.FriendlyURL = withFriendlyURLSuffix(encodeDigitalSambaMeetingID(user.Username), randomHex(rotatedLinkRandomLength))
+
+	settings := p.personalMeetingSettings(user.Id, personalRoom)
+	createRoomReq := newCreateRoomRequest(fmt.Sprintf("%s's Meeting", user.GetDisplayName(model.ShowNicknameFullName)), "", settings)
+
+	room, err := p.getOrCreatePersonalRoom(user, personalRoom, createRoomReq)
+	if err != nil {
+		return nil, err
+	}
+
+	p.audit(auditActionPersonalRoomRotate, user.Id, "", room.ID, map[string]string{"old_room_id": oldRoomID})
+
+	return room, nil
+}
+
+// deletePersonalRoom deletes the user's personal room in DigitalSamba and
+// forgets it. A room that could not be deleted is kept and marked, so that
+// its link does not outlive the user unnoticed.
+func (p *Plugin) deletePersonalRoom(userID string) error {
+	personalRoom, err := p.getPersonalRoom(userID)
+	if err != nil {
+		return err
+	}
+
+	if personalRoom.RoomID != "" {
+		if err := p.getAccount("").client.DeleteRoom(personalRoom.RoomID); err != nil && !isRoomNotFound(err) {
+			if personalRoom.DeleteRequestedAt == 0 {
+				personalRoom.DeleteRequestedAt = model.GetMillis()
+				if saveErr := p.setPersonalRoom(userID, personalRoom); saveErr != nil {
+					p.API.LogWarn("Failed to mark personal room for deletion", "user_id", userID, "error", saveErr.Error())
+				}
+			}
+			return fmt.Errorf("failed to delete personal room %s: %w", personalRoom.RoomID, err)
+		}
+	}
+
+	if appErr := p.API.KVDelete(personalRoomKeyPrefix + userID); appErr != nil {
+		return appErr
+	}
+
+	if personalRoom.RoomID != "" {
+		p.audit(auditActionPersonalRoomDelete, userID, "", personalRoom.RoomID, nil)
+	}
+
+	return nil
+}
+
+// retryPersonalRoomDeletions deletes the personal rooms that could not be
+// deleted when their user was deactivated.
+func (p *Plugin) retryPersonalRoomDeletions() {
+	keys, err := p.listKeys(personalRoomKeyPrefix)
+	if err != nil {
+		p.API.LogWarn("Failed to list personal rooms", "error", err.Error())
+		return
+	}
+
+	for _, key := range keys {
+		userID := strings.TrimPrefix(key, personalRoomKeyPrefix)
+		personalRoom, err := p.getPersonalRoom(userID)
+		if err != nil || personalRoom.DeleteRequestedAt == 0 {
+			continue
+		}
+		if err := p.deletePersonalRoom(userID); err != nil {
+			p.API.LogWarn("Failed to delete personal room", "user_id", userID, "error", err.Error())
+		}
+	}
+}
+
+// UserHasBeenDeactivated deletes the personal room of a deactivated user, so
+// that its link stops working, and drops their cached tokens.
+func (p *Plugin) UserHasBeenDeactivated(c *plugin.Context, user *model.User) {
+	p.invalidateUserTokens(user.Id)
+	if err := p.deletePersonalRoom(user.Id); err != nil {
+		p.API.LogWarn("Failed to clean up personal room", "user_id", user.Id, "error", err.Error())
+	}
+}
+
+// updatePersonalRoomSetting applies a single "setting value" pair from the
+// slash command.
+func updatePersonalRoomSetting(settings *PersonalRoomSettings, setting, value string) error {
+	switch setting {
+	case "lobby":
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid lobby value. Use 'true' or 'false'")
+		}
+		settings.Lobby = b
+	case "recording":
+		if value == "default" {
+			settings.Recording = nil
+			return nil
+		}
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid recording value. Use 'true', 'false' or 'default'")
+		}
+		settings.Recording = &b
+	case "passcode":
+		if value == "off" {
+			settings.PasscodeHash = ""
+			return nil
+		}
+		if err := validatePasscode(value); err != nil {
+			return err
+		}
+		hash, err := hashPasscode(value)
+		if err != nil {
+			return fmt.Errorf("failed to set the passcode")
+		}
+		settings.PasscodeHash = hash
+	default:
+		return fmt.Errorf("invalid setting. Valid settings are: lobby, recording, passcode")
+	}
+
+	return nil
+}
+
+func (p *Plugin) runPersonalRoomCommand(args *model.CommandArgs, fields []string) (*model.CommandResponse, *model.AppError) {
+	user, appErr := p.API.GetUser(args.UserId)
+	if appErr != nil {
+		return p.sendEphemeralResponse(args, "Failed to get user information")
+	}
+
+	if len(fields) == 0 {
+		return p.runStartPersonalMeetingCommand(args, user, "")
+	}
+
+	switch fields[0] {
+	case "link":
+		personalRoom, room, err := p.ensurePersonalRoom(user)
+		if err != nil {
+			return p.sendEphemeralResponse(args, fmt.Sprintf("Failed to get your personal room: %v", err))
+		}
+		meetingURL, err := p.getAccount("").meetingURL(room)
+		if err != nil {
+			return p.sendEphemeralResponse(args, err.Error())
+		}
+		message := fmt.Sprintf("Your personal room: %s\n\nThe link stays the same until you run `/digitalsamba me rotate`.", meetingURL)
+		if personalRoom.Settings.PasscodeHash != "" {
+			message += fmt.Sprintf("\n\nGuests join with your passcode at %s while a meeting is running.", p.guestJoinURL(room.ID))
+		}
+		return p.sendEphemeralResponse(args, message)
+	case "rotate":
+		room, err := p.rotatePersonalRoom(user)
+		if err != nil {
+			return p.sendEphemeralResponse(args, fmt.Sprintf("Failed to rotate your personal room: %v", err))
+		}
+		meetingURL, err := p.getAccount("").meetingURL(room)
+		if err != nil {
+			return p.sendEphemeralResponse(args, err.Error())
+		}
+		return p.sendEphemeralResponse(args, fmt.Sprintf("Your personal room has a new link: %s\n\nThe old link no longer works.", meetingURL))
+	case "settings":
+		return p.runPersonalRoomSettingsCommand(args, fields[1:])
+	default:
+		return p.runStartPersonalMeetingCommand(args, user, strings.Join(fields, " "))
+	}
+}
+
+func (p *Plugin) runStartPersonalMeetingCommand(args *model.CommandArgs, user *model.User, topic string) (*model.CommandResponse, *model.AppError) {
+	channel, appErr := p.API.GetChannel(args.ChannelId)
+	if appErr != nil {
+		return p.sendEphemeralResponse(args, "Failed to get channel information")
+	}
+
+	meetingInfo, err := p.startMeeting(user, channel, "", topic, true, args.RootId, "")
+	if err != nil {
+		return p.sendEphemeralResponse(args, fmt.Sprintf("Failed to start meeting: %v", err))
+	}
+
+	return &model.CommandResponse{
+		Text: fmt.Sprintf("Meeting started: %s", meetingInfo.MeetingID),
+	}, nil
+}
+
+func (p *Plugin) runPersonalRoomSettingsCommand(args *model.CommandArgs, fields []string) (*model.CommandResponse, *model.AppError) {
+	personalRoom, err := p.getPersonalRoom(args.UserId)
+	if err != nil {
+		return p.sendEphemeralResponse(args, "Failed to get your personal room")
+	}
+
+	if len(fields) == 0 {
+		return p.sendEphemeralResponse(args, fmt.Sprintf("Personal Room Settings:\n* Lobby: %v\n* Recording: %s\n* Passcode: %s",
+			personalRoom.Settings.Lobby, formatOptionalBool(personalRoom.Settings.Recording), formatPasscodeSet(personalRoom.Settings.PasscodeHash)))
+	}
+
+	if len(fields) != 2 {
+		return p.sendEphemeralResponse(args, personalRoomCommandUsage)
+	}
+
+	if err := updatePersonalRoomSetting(&personalRoom.Settings, fields[0], fields[1]); err != nil {
+		return p.sendEphemeralResponse(args, err.Error())
+	}
+
+	if err := p.setPersonalRoom(args.UserId, personalRoom); err != nil {
+		return p.sendEphemeralResponse(args, "Failed to update your personal room settings")
+	}
+
+	// An existing room picks up the change right away
+	if personalRoom.RoomID != "" {
+		settings := p.personalMeetingSettings(args.UserId, personalRoom)
+		if _, err := p.getAccount("").client.UpdateRoom(personalRoom.RoomID, &UpdateRoomRequest{
+			Privacy:           roomPrivacy(settings.GuestAccess),
+			RecordingsEnabled: boolPtr(settings.RecordingEnabled),
+			ConsentMessage:    model.NewPointer(p.roomConsentMessage(settings)),
+		}); err != nil {
+			p.API.LogWarn("Failed to update personal room", "user_id", args.UserId, "room_id", personalRoom.RoomID, "error", err.Error())
+		}
+	}
+
+	return p.sendEphemeralResponse(args, "Personal room settings updated successfully")
+}
+
+func formatPasscodeSet(passcodeHash string) string {
+	if passcodeHash == "" {
+		return "off"
+	}
+	return "set"
+}
+
+// endLiveSessions ends the sessions still running in a room.
+func (p *Plugin) endLiveSessions(account *digitalSambaAccount, roomID string) error {
+	sessions, err := account.client.ListSessions(roomID)
+	if err != nil {
+		return err
+	}
+
+	for _, session := range sessions {
+		if !session.Live {
+			continue
+		}
+		if err := account.client.EndSession(session.ID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
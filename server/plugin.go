@@ -1,14 +1,19 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
 	"path/filepath"
+	"strings"
 	"sync"
+	"time"
 
+	"github.com/gorilla/mux"
 	"github.com/mattermost/mattermost/server/public/model"
 	"github.com/mattermost/mattermost/server/public/plugin"
 	"github.com/mattermost/mattermost/server/public/pluginapi"
+	"github.com/mattermost/mattermost/server/public/pluginapi/cluster"
 	"github.com/mattermost/mattermost/server/public/pluginapi/experimental/telemetry"
 	"github.com/mattermost/mattermost/server/public/pluginapi/i18n"
 	"github.com/pkg/errors"
@@ -18,8 +23,20 @@ const digitalSambaNameSchemeAsk = "ask"
 const digitalSambaNameSchemeWords = "words"
 const digitalSambaNameSchemeUUID = "uuid"
 const digitalSambaNameSchemeMattermost = "mattermost"
+const digitalSambaNameSchemeTemplate = "template"
 const configChangeEvent = "config_update"
 
+var validNamingSchemes = []string{digitalSambaNameSchemeWords, digitalSambaNameSchemeUUID, digitalSambaNameSchemeMattermost, digitalSambaNameSchemeTemplate, digitalSambaNameSchemeAsk}
+
+func isValidNamingScheme(scheme string) bool {
+	for _, s := range validNamingSchemes {
+		if s == scheme {
+			return true
+		}
+	}
+	return false
+}
+
 type UserConfig struct {
 	NamingScheme    string `json:"naming_scheme"`
 	Embedded        bool   `json:"embedded"`
@@ -42,10 +59,35 @@ type Plugin struct {
 
 	b *i18n.Bundle
 
+	router *mux.Router
+
+	// webhookClient delivers outgoing webhooks. webhookCtx is cancelled on
+	// deactivation, which stops pending retries, and webhookDeliveries
+	// tracks the deliveries still running.
+	webhookClient     *http.Client
+	webhookCtx        context.Context
+	cancelWebhooks    context.CancelFunc
+	webhookDeliveries sync.WaitGroup
+
 	botID string
 
-	// DigitalSamba API client
-	digitalSambaClient *DigitalSambaClient
+	// accountsLock synchronizes access to the DigitalSamba accounts.
+	accountsLock sync.RWMutex
+
+	// defaultAccount is used for teams without an account of their own.
+	defaultAccount *digitalSambaAccount
+
+	// teamAccounts holds the DigitalSamba accounts of teams with their own
+	// subscription, keyed by team ID.
+	teamAccounts map[string]*digitalSambaAccount
+
+	// retentionJob deletes expired transcripts and audit entries, and the
+	// personal rooms of deactivated users, on one server of the cluster.
+	retentionJob *cluster.Job
+
+	// ringTimeoutJob marks calls nobody answered in time as missed, on one
+	// server of the cluster.
+	ringTimeoutJob *cluster.Job
 }
 
 func (p *Plugin) OnActivate() error {
@@ -69,6 +111,10 @@ func (p *Plugin) OnActivate() error {
 	}
 	p.b = i18nBundle
 
+	p.router = p.initRouter()
+	p.webhookClient = &http.Client{Timeout: 10 * time.Second}
+	p.webhookCtx, p.cancelWebhooks = context.WithCancel(context.Background())
+
 	digitalSambaBot := &model.Bot{
 		Username:    "digitalsamba",
 		DisplayName: "DigitalSamba",
@@ -86,8 +132,27 @@ func (p *Plugin) OnActivate() error {
 
 	p.botID = botID
 
-	// Initialize DigitalSamba client
-	p.digitalSambaClient = NewDigitalSambaClient(config.GetDashboardURL(), config.DigitalSambaAPIKey)
+	// Initialize DigitalSamba clients
+	if err = p.initAccounts(config); err != nil {
+		return err
+	}
+
+	// Check the credentials in the background so a slow API does not block activation
+	go p.checkConnectivity()
+
+	p.retentionJob, err = cluster.Schedule(p.API, "Retention", cluster.MakeWaitForRoundedInterval(time.Hour), func() {
+		p.deleteExpiredTranscripts()
+		p.deleteExpiredAuditEntries()
+		p.retryPersonalRoomDeletions()
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to schedule retention job")
+	}
+
+	p.ringTimeoutJob, err = cluster.Schedule(p.API, "RingTimeout", cluster.MakeWaitForInterval(ringTimeoutCheckInterval), p.checkRingTimeouts)
+	if err != nil {
+		return errors.Wrap(err, "failed to schedule ring timeout job")
+	}
 
 	p.telemetryClient, err = telemetry.NewRudderClient()
 	if err != nil {
@@ -101,6 +166,16 @@ func (p *Plugin) OnDeactivate() error {
 	if p.telemetryClient != nil {
 		_ = p.telemetryClient.Close()
 	}
+	if p.retentionJob != nil {
+		_ = p.retentionJob.Close()
+	}
+	if p.ringTimeoutJob != nil {
+		_ = p.ringTimeoutJob.Close()
+	}
+	if p.cancelWebhooks != nil {
+		p.cancelWebhooks()
+		p.webhookDeliveries.Wait()
+	}
 	return nil
 }
 
@@ -137,29 +212,43 @@ func (p *Plugin) OnConfigurationChange() error {
 		return errors.Wrap(err, "configuration is invalid")
 	}
 
+	// The first load at activation is not a change
+	if oldConfiguration := p.configuration; oldConfiguration != nil {
+		if changed := changedSettings(oldConfiguration, configuration); len(changed) > 0 {
+			p.audit(auditActionConfigUpdate, "", "", "", map[string]string{"settings": strings.Join(changed, ", ")})
+		}
+	}
+
 	p.setConfiguration(configuration)
 
-	// Update DigitalSamba client with new configuration
-	if p.digitalSambaClient != nil {
-		p.digitalSambaClient = NewDigitalSambaClient(configuration.GetDashboardURL(), configuration.DigitalSambaAPIKey)
+	// Update DigitalSamba clients with new configuration
+	if err := p.initAccounts(configuration); err != nil {
+		return errors.Wrap(err, "failed to initialize DigitalSamba accounts")
+	}
+
+	// OnActivate runs the first check once the bot exists
+	if p.botID != "" {
+		go p.checkConnectivity()
 	}
 
 	return nil
 }
 
-func (p *Plugin) ServeHTTP(c *plugin.Context, w http.ResponseWriter, r *http.Request) {
-	switch r.URL.Path {
-	case "/api/v1/meetings":
-		p.handleStartMeeting(w, r)
-	case "/api/v1/token":
-		p.handleGetToken(w, r)
-	case "/api/v1/config":
-		p.handleConfig(w, r)
-	case "/api/v1/user-config":
-		p.handleUserConfig(w, r)
-	default:
-		http.NotFound(w, r)
-	}
+// UserHasLeftChannel drops the cached tokens of a user removed from a
+// channel, who may no longer join its meetings.
+func (p *Plugin) UserHasLeftChannel(c *plugin.Context, channelMember *model.ChannelMember, actor *model.User) {
+	p.invalidateUserTokens(channelMember.UserId)
+}
+
+// UserHasJoinedChannel drops the cached tokens of a user added to a channel,
+// whose role in its meetings changes.
+func (p *Plugin) UserHasJoinedChannel(c *plugin.Context, channelMember *model.ChannelMember, actor *model.User) {
+	p.invalidateUserTokens(channelMember.UserId)
+}
+
+// UserHasLeftTeam drops the cached tokens of a user removed from a team.
+func (p *Plugin) UserHasLeftTeam(c *plugin.Context, teamMember *model.TeamMember, actor *model.User) {
+	p.invalidateUserTokens(teamMember.UserId)
 }
 
 func (p *Plugin) getUserConfig(userID string) (*UserConfig, error) {
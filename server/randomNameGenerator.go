@@ -4,84 +4,180 @@ import (
 	"crypto/rand"
 	"fmt"
 	"math/big"
+	"regexp"
 	"strings"
 )
 
 const LETTERS = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
 
-var adjectives = []string{
-	"Adorable", "Beautiful", "Clean", "Drab", "Elegant", "Fancy", "Glamorous", "Handsome", "Immaculate",
-	"Jolly", "Keen", "Lively", "Magnificent", "Nice", "Open", "Plain", "Quaint", "Rich", "Sparkling",
-	"Talented", "Unsightly", "Vast", "Wide", "Zealous",
-	"Big", "Colossal", "Fat", "Gigantic", "Great", "Huge", "Immense", "Large", "Little", "Mammoth",
-	"Massive", "Miniature", "Petite", "Puny", "Scrawny", "Short", "Small", "Tall", "Teeny", "Tiny",
-	"Boiling", "Breeze", "Broken", "Bumpy", "Chilly", "Cold", "Cool", "Creepy", "Crooked", "Cuddly",
-	"Curly", "Damaged", "Damp", "Dirty", "Dry", "Dusty", "Filthy", "Flaky", "Fluffy", "Freezing",
-	"Hot", "Warm", "Wet",
-	"Ancient", "Brief", "Early", "Fast", "Late", "Long", "Modern", "Old", "Quick", "Rapid",
-	"Short", "Slow", "Swift", "Young",
-	"Alive", "Better", "Careful", "Clever", "Dead", "Easy", "Famous", "Gifted", "Helpful", "Important",
-	"Inexpensive", "Mushy", "Odd", "Powerful", "Rich", "Shy", "Tender", "Unimportant", "Uninterested", "Wrong",
-	"Angry", "Bewildered", "Clumsy", "Defeated", "Embarrassed", "Fierce", "Grumpy", "Helpless", "Itchy", "Jealous",
-	"Lazy", "Mysterious", "Nervous", "Obnoxious", "Panicky", "Repulsive", "Scary", "Thoughtless", "Uptight", "Worried",
-	"Agreeable", "Brave", "Calm", "Delightful", "Eager", "Faithful", "Gentle", "Happy", "Jolly", "Kind",
-	"Lively", "Nice", "Obedient", "Proud", "Relieved", "Silly", "Thankful", "Victorious", "Witty", "Zealous",
-	"Broad", "Chubby", "Crooked", "Curved", "Deep", "Flat", "High", "Hollow", "Low", "Narrow",
-	"Round", "Shallow", "Skinny", "Square", "Steep", "Straight", "Wide",
-	"Abundant", "Empty", "Few", "Full", "Heavy", "Light", "Many", "Numerous", "Sparse", "Substantial",
+// Parts of a random word name.
+const (
+	wordPartAdjective = "adjective"
+	wordPartNoun      = "noun"
+	wordPartVerb      = "verb"
+	wordPartAdverb    = "adverb"
+)
+
+const defaultWordPattern = "adjective-noun-verb"
+
+// wordPatterns are the orders of word parts admins can choose from.
+var wordPatterns = map[string][]string{
+	"adjective-noun":             {wordPartAdjective, wordPartNoun},
+	"adjective-noun-verb":        {wordPartAdjective, wordPartNoun, wordPartVerb},
+	"adjective-noun-verb-adverb": {wordPartAdjective, wordPartNoun, wordPartVerb, wordPartAdverb},
 }
 
-var nouns = []string{
-	"Dragons", "Unicorns", "Wizards", "Knights", "Pirates", "Ninjas", "Robots", "Aliens", "Zombies", "Vampires",
-	"Phoenixes", "Griffins", "Centaurs", "Mermaids", "Fairies", "Elves", "Dwarves", "Giants", "Trolls", "Goblins",
-	"Lions", "Tigers", "Bears", "Wolves", "Eagles", "Hawks", "Owls", "Ravens", "Dolphins", "Whales",
-	"Sharks", "Octopuses", "Squids", "Jellyfish", "Starfish", "Crabs", "Lobsters", "Turtles", "Penguins", "Pandas",
-	"Mountains", "Rivers", "Oceans", "Forests", "Deserts", "Islands", "Valleys", "Canyons", "Glaciers", "Volcanoes",
-	"Stars", "Moons", "Suns", "Planets", "Galaxies", "Comets", "Asteroids", "Meteors", "Nebulas", "Blackholes",
-	"Heroes", "Villains", "Champions", "Legends", "Titans", "Warriors", "Guardians", "Defenders", "Hunters", "Scouts",
-	"Artists", "Musicians", "Dancers", "Actors", "Writers", "Poets", "Painters", "Sculptors", "Photographers", "Directors",
-	"Scientists", "Engineers", "Doctors", "Teachers", "Students", "Professors", "Researchers", "Inventors", "Explorers", "Astronauts",
-	"Storms", "Thunder", "Lightning", "Rain", "Snow", "Wind", "Clouds", "Sunshine", "Moonlight", "Starlight",
+// maxWordNameAttempts bounds the draws for a name that passes the denylist
+// and fits into a friendly URL.
+const maxWordNameAttempts = 20
+
+var wordRegexp = regexp.MustCompile(`^[a-zA-Z0-9]+$`)
+
+// wordList holds the words of one language. Words only use characters that
+// are valid in a friendly URL.
+type wordList struct {
+	Adjectives []string `json:"adjectives,omitempty"`
+	Nouns      []string `json:"nouns,omitempty"`
+	Verbs      []string `json:"verbs,omitempty"`
+	Adverbs    []string `json:"adverbs,omitempty"`
 }
 
-var verbs = []string{
-	"Accept", "Achieve", "Add", "Admire", "Admit", "Advise", "Afford", "Agree", "Alert", "Allow",
-	"Amuse", "Analyze", "Announce", "Annoy", "Answer", "Apologize", "Appear", "Applaud", "Appreciate", "Approve",
-	"Argue", "Arrange", "Arrest", "Arrive", "Ask", "Attach", "Attack", "Attempt", "Attend", "Attract",
-	"Avoid", "Back", "Bake", "Balance", "Ban", "Bang", "Bare", "Bat", "Bathe", "Battle",
-	"Beam", "Beg", "Behave", "Belong", "Bleach", "Bless", "Blind", "Blink", "Blot", "Blush",
-	"Boast", "Boil", "Bolt", "Bomb", "Book", "Bore", "Borrow", "Bounce", "Bow", "Box",
-	"Brake", "Branch", "Breathe", "Bruise", "Brush", "Bubble", "Build", "Bump", "Burn", "Bury",
-	"Buzz", "Calculate", "Call", "Camp", "Care", "Carry", "Carve", "Cause", "Challenge", "Change",
-	"Charge", "Chase", "Cheat", "Check", "Cheer", "Chew", "Choke", "Chop", "Claim", "Clap",
-	"Clean", "Clear", "Clip", "Close", "Coach", "Coil", "Collect", "Color", "Comb", "Command",
-	"Communicate", "Compare", "Compete", "Complain", "Complete", "Concentrate", "Concern", "Confess", "Confuse", "Connect",
-	"Consider", "Consist", "Contain", "Continue", "Copy", "Correct", "Cough", "Count", "Cover", "Crack",
-	"Crash", "Crawl", "Cross", "Crush", "Cry", "Cure", "Curl", "Curve", "Cycle", "Dam",
-	"Damage", "Dance", "Dare", "Decay", "Deceive", "Decide", "Decorate", "Delay", "Delight", "Deliver",
-	"Depend", "Describe", "Desert", "Deserve", "Destroy", "Detect", "Develop", "Disagree", "Disappear", "Disapprove",
-	"Disarm", "Discover", "Dislike", "Divide", "Double", "Doubt", "Drag", "Drain", "Dream", "Dress",
-	"Drip", "Drop", "Drown", "Drum", "Dry", "Dust", "Earn", "Educate", "Embarrass", "Employ",
-	"Empty", "Encourage", "End", "Enjoy", "Enter", "Entertain", "Escape", "Examine", "Excite", "Excuse",
-	"Exercise", "Exist", "Expand", "Expect", "Explain", "Explode", "Express", "Extend", "Face", "Fade",
+func (w *wordList) words(part string) []string {
+	switch part {
+	case wordPartAdjective:
+		return w.Adjectives
+	case wordPartNoun:
+		return w.Nouns
+	case wordPartVerb:
+		return w.Verbs
+	case wordPartAdverb:
+		return w.Adverbs
+	default:
+		return nil
+	}
 }
 
-var adverbs = []string{
-	"Abnormally", "Absentmindedly", "Accidentally", "Acidly", "Actually", "Adventurously", "Afterwards", "Almost", "Always", "Angrily",
-	"Annually", "Anxiously", "Arrogantly", "Awkwardly", "Badly", "Bashfully", "Beautifully", "Bitterly", "Bleakly", "Blindly",
-	"Blissfully", "Boastfully", "Boldly", "Bravely", "Briefly", "Brightly", "Briskly", "Broadly", "Busily", "Calmly",
-	"Carefully", "Carelessly", "Cautiously", "Certainly", "Cheerfully", "Clearly", "Cleverly", "Closely", "Coaxingly", "Colorfully",
-	"Commonly", "Continually", "Coolly", "Correctly", "Courageously", "Crossly", "Cruelly", "Curiously", "Daily", "Daintily",
-	"Dearly", "Deceivingly", "Delightfully", "Deeply", "Defiantly", "Deliberately", "Delightfully", "Diligently", "Dimly", "Doubtfully",
-	"Dreamily", "Easily", "Elegantly", "Energetically", "Enormously", "Enthusiastically", "Equally", "Especially", "Eventually", "Exactly",
-	"Excitedly", "Extremely", "Fairly", "Faithfully", "Famously", "Far", "Fast", "Fatally", "Ferociously", "Fervently",
-	"Few", "Fiercely", "Fondly", "Foolishly", "Fortunately", "Frankly", "Frantically", "Freely", "Frenetically", "Frightfully",
-	"Fully", "Furiously", "Generally", "Generously", "Gently", "Gladly", "Gleefully", "Gracefully", "Gratefully", "Greatly",
-	"Greedily", "Happily", "Hastily", "Healthily", "Heavily", "Helpfully", "Helplessly", "Highly", "Honestly", "Hopelessly",
-	"Hourly", "Hungrily", "Immediately", "Innocently", "Inquisitively", "Instantly", "Intensely", "Intently", "Interestingly", "Inwardly",
-	"Irritably", "Jaggedly", "Jealously", "Joshingly", "Joyfully", "Joyously", "Jubilantly", "Judgmentally", "Justly", "Keenly",
-	"Kiddingly", "Kindheartedly", "Kindly", "Kissingly", "Knavishly", "Knottily", "Knowingly", "Knowledgeably", "Lazily", "Less",
-	"Lightly", "Likely", "Limply", "Lively", "Loftily", "Longingly", "Loosely", "Lovingly", "Loudly", "Loyally",
+// merge returns the list with the non-empty parts of custom replacing its own.
+func (w *wordList) merge(custom *wordList) *wordList {
+	merged := *w
+	if custom == nil {
+		return &merged
+	}
+	if len(custom.Adjectives) > 0 {
+		merged.Adjectives = custom.Adjectives
+	}
+	if len(custom.Nouns) > 0 {
+		merged.Nouns = custom.Nouns
+	}
+	if len(custom.Verbs) > 0 {
+		merged.Verbs = custom.Verbs
+	}
+	if len(custom.Adverbs) > 0 {
+		merged.Adverbs = custom.Adverbs
+	}
+	return &merged
+}
+
+func (w *wordList) validate() error {
+	for _, words := range [][]string{w.Adjectives, w.Nouns, w.Verbs, w.Adverbs} {
+		for _, word := range words {
+			if !wordRegexp.MatchString(word) {
+				return fmt.Errorf("word %q can only contain letters a-z and digits", word)
+			}
+		}
+	}
+	return nil
+}
+
+const defaultWordLanguage = "en"
+
+// defaultWordLists are curated to be neutral in a business setting. Words
+// with accents are spelled without them, as friendly URLs are ASCII.
+var defaultWordLists = map[string]*wordList{
+	"en": {
+		Adjectives: []string{
+			"Agile", "Amber", "Azure", "Bold", "Brave", "Bright", "Brisk", "Calm", "Candid", "Cheerful",
+			"Clever", "Cosmic", "Crisp", "Curious", "Eager", "Fresh", "Friendly", "Gentle", "Golden", "Grand",
+			"Happy", "Honest", "Jolly", "Keen", "Kind", "Lively", "Lucky", "Mellow", "Merry", "Mighty",
+			"Neat", "Nimble", "Noble", "Polite", "Proud", "Quick", "Quiet", "Radiant", "Rapid", "Sunny",
+			"Steady", "Swift", "Tidy", "Vivid", "Warm", "Wise", "Witty", "Young", "Zesty",
+		},
+		Nouns: []string{
+			"Otters", "Falcons", "Pandas", "Dolphins", "Owls", "Foxes", "Badgers", "Herons", "Koalas", "Beavers",
+			"Penguins", "Robins", "Turtles", "Whales", "Lynxes", "Sparrows", "Rivers", "Mountains", "Meadows", "Forests",
+			"Islands", "Valleys", "Canyons", "Harbors", "Gardens", "Orchards", "Comets", "Planets", "Galaxies", "Stars",
+			"Clouds", "Rainbows", "Breezes", "Lanterns", "Bridges", "Compasses", "Rockets", "Sailboats", "Kites", "Maples",
+			"Cedars", "Willows", "Pebbles", "Crystals", "Beacons", "Pioneers", "Explorers", "Builders", "Makers", "Scouts",
+		},
+		Verbs: []string{
+			"Build", "Create", "Discover", "Explore", "Gather", "Imagine", "Invent", "Launch", "Learn", "Meet",
+			"Plan", "Share", "Sketch", "Solve", "Design", "Wonder", "Travel", "Climb", "Sail", "Glide",
+			"Dance", "Sing", "Paint", "Write", "Listen", "Read", "Garden", "Wander", "Ponder", "Shine",
+			"Sparkle", "Thrive", "Grow", "Bloom", "Cheer", "Connect", "Collaborate", "Celebrate", "Improve", "Focus",
+		},
+		Adverbs: []string{
+			"Boldly", "Brightly", "Briskly", "Calmly", "Carefully", "Cheerfully", "Cleverly", "Curiously", "Eagerly", "Gently",
+			"Gladly", "Gracefully", "Happily", "Honestly", "Joyfully", "Kindly", "Neatly", "Nimbly", "Openly", "Patiently",
+			"Politely", "Proudly", "Quickly", "Quietly", "Smoothly", "Softly", "Steadily", "Swiftly", "Together", "Warmly",
+			"Wisely", "Brilliantly", "Creatively", "Freely", "Playfully",
+		},
+	},
+	"de": {
+		Adjectives: []string{
+			"Bunte", "Flinke", "Frohe", "Freundliche", "Helle", "Heitere", "Kluge", "Leise", "Mutige", "Ruhige",
+			"Schnelle", "Sonnige", "Starke", "Stolze", "Wache", "Warme", "Weise", "Wilde", "Zarte", "Neue",
+			"Goldene", "Blaue", "Gruene", "Lustige", "Tapfere", "Treue", "Edle", "Muntere", "Feine", "Klare",
+		},
+		Nouns: []string{
+			"Adler", "Baeren", "Biber", "Delfine", "Eulen", "Falken", "Fuechse", "Igel", "Koalas", "Luchse",
+			"Otter", "Pandas", "Pinguine", "Reiher", "Wale", "Berge", "Fluesse", "Waelder", "Wiesen", "Inseln",
+			"Taeler", "Gaerten", "Kometen", "Planeten", "Sterne", "Wolken", "Bruecken", "Laternen", "Drachen", "Segler",
+		},
+		Verbs: []string{
+			"Bauen", "Denken", "Entdecken", "Erkunden", "Gestalten", "Lernen", "Malen", "Planen", "Reisen", "Segeln",
+			"Singen", "Spielen", "Staunen", "Tanzen", "Teilen", "Wandern", "Wachsen", "Leuchten", "Erfinden", "Schreiben",
+		},
+		Adverbs: []string{
+			"Frohgemut", "Gemeinsam", "Gern", "Geschickt", "Heiter", "Leise", "Munter", "Mutig", "Ruhig", "Schnell",
+			"Sanft", "Stolz", "Klug", "Flink", "Herzlich", "Sorgsam", "Zuegig", "Freudig", "Offen", "Weise",
+		},
+	},
+	"es": {
+		Adjectives: []string{
+			"Agiles", "Alegres", "Amables", "Audaces", "Brillantes", "Elegantes", "Felices", "Firmes", "Fuertes", "Gentiles",
+			"Grandes", "Inteligentes", "Libres", "Nobles", "Radiantes", "Leales", "Sonrientes", "Valientes", "Veloces", "Jovenes",
+		},
+		Nouns: []string{
+			"Delfines", "Halcones", "Pandas", "Zorros", "Buhos", "Castores", "Tortugas", "Ballenas", "Pinguinos", "Linces",
+			"Rios", "Montes", "Bosques", "Valles", "Islas", "Jardines", "Cometas", "Planetas", "Luceros", "Faros",
+		},
+		Verbs: []string{
+			"Bailan", "Cantan", "Construyen", "Crean", "Descubren", "Disenan", "Exploran", "Imaginan", "Inventan", "Navegan",
+			"Pintan", "Planean", "Aprenden", "Comparten", "Escriben", "Suenan", "Viajan", "Brillan", "Crecen", "Celebran",
+		},
+		Adverbs: []string{
+			"Alegremente", "Amablemente", "Calmadamente", "Juntos", "Libremente", "Rapidamente", "Sabiamente", "Serenamente",
+			"Suavemente", "Tranquilamente", "Felizmente", "Hoy", "Siempre", "Bien", "Pronto",
+		},
+	},
+	"fr": {
+		Adjectives: []string{
+			"Agiles", "Aimables", "Braves", "Calmes", "Celebres", "Fideles", "Habiles", "Libres", "Magiques", "Nobles",
+			"Pacifiques", "Rapides", "Sages", "Solides", "Sympathiques", "Tranquilles", "Uniques", "Utiles", "Superbes", "Dynamiques",
+		},
+		Nouns: []string{
+			"Dauphins", "Faucons", "Pandas", "Renards", "Hiboux", "Castors", "Tortues", "Baleines", "Pingouins", "Lynx",
+			"Fleuves", "Monts", "Forets", "Vallons", "Iles", "Jardins", "Cometes", "Planetes", "Phares", "Voiliers",
+		},
+		Verbs: []string{
+			"Dansent", "Chantent", "Construisent", "Creent", "Decouvrent", "Dessinent", "Explorent", "Imaginent", "Inventent", "Naviguent",
+			"Peignent", "Planifient", "Apprennent", "Partagent", "Ecrivent", "Revent", "Voyagent", "Brillent", "Grandissent", "Celebrent",
+		},
+		Adverbs: []string{
+			"Calmement", "Doucement", "Ensemble", "Gaiement", "Joyeusement", "Librement", "Rapidement", "Sagement", "Sereinement",
+			"Vivement", "Aujourdhui", "Toujours", "Bien", "Bientot", "Volontiers",
+		},
+	},
 }
 
 func randomString(letters string, n int) string {
@@ -93,16 +189,87 @@ func randomString(letters string, n int) string {
 	return string(b)
 }
 
-func generateEnglishTitleName() string {
-	adjNum, _ := rand.Int(rand.Reader, big.NewInt(int64(len(adjectives))))
-	nounNum, _ := rand.Int(rand.Reader, big.NewInt(int64(len(nouns))))
-	verbNum, _ := rand.Int(rand.Reader, big.NewInt(int64(len(verbs))))
-	adverbNum, _ := rand.Int(rand.Reader, big.NewInt(int64(len(adverbs))))
+// wordLanguage returns the language of a Mattermost locale such as "pt-BR".
+func wordLanguage(locale string) string {
+	language, _, _ := strings.Cut(strings.ToLower(locale), "-")
+	language, _, _ = strings.Cut(language, "_")
+	return language
+}
 
-	adjective := adjectives[adjNum.Int64()]
-	noun := nouns[nounNum.Int64()]
-	verb := verbs[verbNum.Int64()]
-	adverb := adverbs[adverbNum.Int64()]
+// getWordList returns the words for the locale: the admin's custom list for
+// the language over the built-in one, falling back to English.
+func (p *Plugin) getWordList(locale string) *wordList {
+	language := wordLanguage(locale)
 
-	return fmt.Sprintf("%s%s%s%s", strings.Title(adjective), strings.Title(noun), strings.Title(verb), strings.Title(adverb))
-}
\ No newline at end of file
+	customLists, err := p.getConfiguration().GetWordLists()
+	if err != nil {
+		customLists = nil
+	}
+
+	base, ok := defaultWordLists[language]
+	if !ok {
+		if _, hasCustom := customLists[language]; !hasCustom {
+			language = defaultWordLanguage
+		}
+		base = defaultWordLists[defaultWordLanguage]
+	}
+
+	return base.merge(customLists[language])
+}
+
+// generateWordsName returns a name of random words in the user's language,
+// following the configured word pattern.
+func (p *Plugin) generateWordsName(locale string) string {
+	return p.generateWords(locale, p.getConfiguration().GetWordPattern())
+}
+
+// generateWords draws title case words for the parts. Names with a denied
+// word anywhere in them are drawn again, and so are names too long for a
+// friendly URL as long as attempts remain.
+func (p *Plugin) generateWords(locale string, parts []string) string {
+	list := p.getWordList(locale)
+	denylist := p.getConfiguration().GetWordDenylist()
+
+	fallback := ""
+	for attempt := 0; attempt < maxWordNameAttempts; attempt++ {
+		var sb strings.Builder
+		for _, part := range parts {
+			if words := list.words(part); len(words) > 0 {
+				sb.WriteString(strings.Title(pickWord(words)))
+			}
+		}
+
+		name := sb.String()
+		if name == "" || isDeniedName(name, denylist) {
+			continue
+		}
+		if len(name) <= maxFriendlyURLLength {
+			return name
+		}
+		if fallback == "" {
+			fallback = name
+		}
+	}
+
+	if fallback == "" {
+		return "meeting-" + randomHex(friendlyURLSuffixLength)
+	}
+	return fallback
+}
+
+// isDeniedName reports whether the name contains a denied word, ignoring
+// case, so that combinations of harmless words are caught too.
+func isDeniedName(name string, denylist []string) bool {
+	name = strings.ToLower(name)
+	for _, denied := range denylist {
+		if strings.Contains(name, denied) {
+			return true
+		}
+	}
+	return false
+}
+
+func pickWord(words []string) string {
+	i, _ := rand.Int(rand.Reader, big.NewInt(int64(len(words))))
+	return words[i.Int64()]
+}
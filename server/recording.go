@@ -0,0 +1,348 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+const recordCommandUsage = "Use `/digitalsamba record start` or `/digitalsamba record stop` in the channel or thread of a running meeting."
+
+// meetingPostRecordingField is the title of the recording status on the
+// meeting post.
+const meetingPostRecordingField = "Recording"
+
+// recordingActionIDPrefix starts the IDs of the recording buttons, which
+// tells them apart from the other buttons of the meeting post.
+const recordingActionIDPrefix = "recording"
+
+var (
+	errRecordingNotAllowed = errors.New("recording is not enabled for this meeting")
+	errAlreadyRecording    = errors.New("the meeting is already being recorded")
+	errNotRecording        = errors.New("the meeting is not being recorded")
+	errMeetingEnded        = errors.New("the meeting has ended")
+)
+
+// recordingActions are the Record or Stop button of the meeting post. Only
+// moderators can use them, which the action handler checks.
+func (p *Plugin) recordingActions(roomID string, recording bool) []*model.PostAction {
+	action, name := "start", "Record"
+	if recording {
+		action, name = "stop", "Stop recording"
+	}
+
+	return []*model.PostAction{{
+		Id:   recordingActionIDPrefix + action,
+		Name: name,
+		Integration: &model.PostActionIntegration{
+			URL: *p.API.GetConfig().ServiceSettings.SiteURL + "/plugins/digitalsamba/api/v1/actions/recording",
+			Context: map[string]interface{}{
+				"room_id": roomID,
+				"action":  action,
+			},
+		},
+	}}
+}
+
+// startRecording records the meeting and tells the channel, which everyone
+// in the meeting must know about.
+func (p *Plugin) startRecording(record *MeetingRecord, userID string) error {
+	switch {
+	case record.EndedAt != 0:
+		return errMeetingEnded
+	case !record.RecordingAllowed:
+		return errRecordingNotAllowed
+	case record.RecordingStartedAt != 0:
+		return errAlreadyRecording
+	}
+
+	if err := p.getAccount(record.TeamID).client.StartRecording(record.RoomID); err != nil {
+		return fmt.Errorf("failed to start the recording: %w", err)
+	}
+
+	if err := p.setRecordingStatus(record, userID, true); err != nil {
+		return err
+	}
+
+	p.audit(auditActionRecordingStart, userID, record.ChannelID, record.RoomID, nil)
+
+	return nil
+}
+
+// stopRecording stops recording the meeting.
+func (p *Plugin) stopRecording(record *MeetingRecord, userID string) error {
+	switch {
+	case record.EndedAt != 0:
+		return errMeetingEnded
+	case record.RecordingStartedAt == 0:
+		return errNotRecording
+	}
+
+	if err := p.getAccount(record.TeamID).client.StopRecording(record.RoomID); err != nil {
+		return fmt.Errorf("failed to stop the recording: %w", err)
+	}
+
+	if err := p.setRecordingStatus(record, userID, false); err != nil {
+		return err
+	}
+
+	p.audit(auditActionRecordingStop, userID, record.ChannelID, record.RoomID, nil)
+
+	return nil
+}
+
+// setRecordingStatus stores whether the meeting is being recorded. When that
+// changed, it shows the status on the meeting post and tells the channel.
+// userID is who started or stopped the recording, empty when it is unknown.
+func (p *Plugin) setRecordingStatus(record *MeetingRecord, userID string, recording bool) error {
+	changed := false
+	if err := p.updateMeetingRecord(record, func(record *MeetingRecord) bool {
+		changed = record.EndedAt == 0 && (record.RecordingStartedAt != 0) != recording
+		if !changed {
+			return false
+		}
+		if recording {
+			record.RecordingStartedAt = model.GetMillis()
+			record.RecordingStartedBy = userID
+		} else {
+			record.RecordingStartedAt = 0
+			record.RecordingStartedBy = ""
+		}
+		return true
+	}); err != nil {
+		return err
+	}
+
+	// The command and the DigitalSamba event of the same recording tell the
+	// channel once
+	if changed {
+		p.showRecordingStatus(record)
+		p.postRecordingNotice(record, userID, recording)
+	}
+
+	return nil
+}
+
+// showRecordingStatus shows the 🔴 Recording marker and the matching button
+// on the meeting post.
+func (p *Plugin) showRecordingStatus(record *MeetingRecord) {
+	recording := record.RecordingStartedAt != 0
+	value, text := "", ""
+	if recording {
+		value, text = "true", "🔴 Recording"
+	}
+
+	p.updateMeetingPost(record, func(post *model.Post, attachments []*model.SlackAttachment) {
+		setMeetingPostField(post, attachments, "recording", meetingPostRecordingField, value, text)
+		for _, attachment := range attachments {
+			attachment.Actions = append(withoutRecordingActions(attachment.Actions), p.recordingActions(record.RoomID, recording)...)
+		}
+	})
+}
+
+// withoutRecordingActions returns the buttons of the meeting post other than
+// the recording buttons.
+func withoutRecordingActions(actions []*model.PostAction) []*model.PostAction {
+	kept := make([]*model.PostAction, 0, len(actions)+1)
+	for _, action := range actions {
+		if !strings.HasPrefix(action.Id, recordingActionIDPrefix) {
+			kept = append(kept, action)
+		}
+	}
+	return kept
+}
+
+// postRecordingNotice replies in the meeting thread, so that every member of
+// the channel sees when recording starts or stops.
+func (p *Plugin) postRecordingNotice(record *MeetingRecord, userID string, recording bool) {
+	username := ""
+	if userID != "" {
+		if user, appErr := p.API.GetUser(userID); appErr == nil {
+			username = user.Username
+		}
+	}
+
+	var message string
+	switch {
+	case username != "" && recording:
+		message = fmt.Sprintf("🔴 @%s started recording **%s**. Everyone in the meeting is being recorded.", username, record.Topic)
+	case username != "":
+		message = fmt.Sprintf("⏹️ @%s stopped recording **%s**.", username, record.Topic)
+	case recording:
+		message = fmt.Sprintf("🔴 Recording of **%s** started. Everyone in the meeting is being recorded.", record.Topic)
+	default:
+		message = fmt.Sprintf("⏹️ Recording of **%s** stopped.", record.Topic)
+	}
+
+	post := &model.Post{
+		UserId:    p.botID,
+		ChannelId: record.ChannelID,
+		RootId:    p.meetingThreadID(record),
+		Message:   message,
+	}
+	if _, appErr := p.API.CreatePost(post); appErr != nil {
+		p.API.LogWarn("Failed to post recording notice", "room_id", record.RoomID, "error", appErr.Error())
+	}
+}
+
+// meetingThreadID is the root of the thread replies about a meeting go to.
+// A meeting started in a thread is itself a reply.
+func (p *Plugin) meetingThreadID(record *MeetingRecord) string {
+	if record.PostID == "" {
+		return ""
+	}
+	post, appErr := p.API.GetPost(record.PostID)
+	if appErr == nil && post.RootId != "" {
+		return post.RootId
+	}
+	return record.PostID
+}
+
+// clearRecordingStatus forgets the recording of a meeting whose session
+// ended, which stops the recording in DigitalSamba.
+func (p *Plugin) clearRecordingStatus(record *MeetingRecord) {
+	cleared := false
+	if err := p.updateMeetingRecord(record, func(record *MeetingRecord) bool {
+		cleared = record.RecordingStartedAt != 0
+		record.RecordingStartedAt = 0
+		record.RecordingStartedBy = ""
+		return cleared
+	}); err != nil {
+		p.API.LogWarn("Failed to clear recording status", "room_id", record.RoomID, "error", err.Error())
+		return
+	}
+
+	if cleared {
+		p.showRecordingStatus(record)
+	}
+}
+
+// handleRecordingEvent shows a recording started or stopped in DigitalSamba
+// like one started or stopped from Mattermost. The event names the
+// participant by the user ID their token was issued for, if any.
+func (p *Plugin) handleRecordingEvent(record *MeetingRecord, userID string, recording bool) {
+	if userID != "" && !model.IsValidId(userID) {
+		userID = ""
+	}
+	if err := p.setRecordingStatus(record, userID, recording); err != nil {
+		p.API.LogWarn("Failed to update recording status", "room_id", record.RoomID, "error", err.Error())
+	}
+}
+
+// setRecording starts or stops recording the meeting for a moderator.
+func (p *Plugin) setRecording(record *MeetingRecord, userID string, start bool) error {
+	if start {
+		return p.startRecording(record, userID)
+	}
+	return p.stopRecording(record, userID)
+}
+
+// isRecordingUserError reports whether the error is the user's to fix rather
+// than a failure.
+func isRecordingUserError(err error) bool {
+	return errors.Is(err, errRecordingNotAllowed) || errors.Is(err, errAlreadyRecording) ||
+		errors.Is(err, errNotRecording) || errors.Is(err, errMeetingEnded)
+}
+
+func (p *Plugin) runRecordCommand(args *model.CommandArgs, fields []string) (*model.CommandResponse, *model.AppError) {
+	if len(fields) == 0 || (fields[0] != "start" && fields[0] != "stop") {
+		return p.sendEphemeralResponse(args, recordCommandUsage)
+	}
+
+	record, err := p.findActiveMeeting(args.ChannelId, args.RootId)
+	if err != nil {
+		return p.sendEphemeralResponse(args, "Failed to find the meeting")
+	}
+	if record == nil {
+		return p.sendEphemeralResponse(args, "There is no running meeting here. "+recordCommandUsage)
+	}
+
+	if !p.canManageMeeting(args.UserId, record) {
+		return p.sendEphemeralResponse(args, "Only the meeting creator or a channel admin can record the meeting")
+	}
+
+	start := fields[0] == "start"
+	if err := p.setRecording(record, args.UserId, start); err != nil {
+		if !isRecordingUserError(err) {
+			p.API.LogError("Failed to change recording", "room_id", record.RoomID, "error", err.Error())
+		}
+		if start {
+			return p.sendEphemeralResponse(args, fmt.Sprintf("Failed to start recording: %v", err))
+		}
+		return p.sendEphemeralResponse(args, fmt.Sprintf("Failed to stop recording: %v", err))
+	}
+
+	return &model.CommandResponse{}, nil
+}
+
+// handleRecordingAction handles the Record and Stop buttons of the meeting
+// post.
+func (p *Plugin) handleRecordingAction(w http.ResponseWriter, r *http.Request) {
+	userID := r.Header.Get("Mattermost-User-Id")
+
+	var actionReq model.PostActionIntegrationRequest
+	if !decodeJSON(w, r, &actionReq) {
+		return
+	}
+
+	roomID, _ := actionReq.Context["room_id"].(string)
+	action, _ := actionReq.Context["action"].(string)
+
+	// The button is on the meeting post
+	record, err := p.getPostActionMeeting(roomID, actionReq.PostId)
+	if err != nil || record == nil {
+		writeJSON(w, http.StatusOK, &model.PostActionIntegrationResponse{EphemeralText: "The meeting no longer exists"})
+		return
+	}
+
+	if !p.canManageMeeting(userID, record) {
+		writeJSON(w, http.StatusOK, &model.PostActionIntegrationResponse{EphemeralText: "Only the meeting creator or a channel admin can record the meeting"})
+		return
+	}
+
+	if err := p.setRecording(record, userID, action == "start"); err != nil {
+		if !isRecordingUserError(err) {
+			p.API.LogError("Failed to change recording", "room_id", roomID, "error", err.Error())
+		}
+		writeJSON(w, http.StatusOK, &model.PostActionIntegrationResponse{EphemeralText: fmt.Sprintf("Failed to change the recording: %v", err)})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, &model.PostActionIntegrationResponse{})
+}
+
+func (p *Plugin) handleStartRecording(w http.ResponseWriter, r *http.Request) {
+	p.handleSetRecording(w, r, true)
+}
+
+func (p *Plugin) handleStopRecording(w http.ResponseWriter, r *http.Request) {
+	p.handleSetRecording(w, r, false)
+}
+
+func (p *Plugin) handleSetRecording(w http.ResponseWriter, r *http.Request, start bool) {
+	userID := r.Header.Get("Mattermost-User-Id")
+
+	record, ok := p.getMeetingForRequest(w, r)
+	if !ok {
+		return
+	}
+
+	if !p.canManageMeeting(userID, record) {
+		writeError(w, http.StatusForbidden, errorCodeForbidden, "Only the meeting creator or a channel admin can record the meeting")
+		return
+	}
+
+	if err := p.setRecording(record, userID, start); err != nil {
+		if isRecordingUserError(err) {
+			writeError(w, http.StatusBadRequest, errorCodeBadRequest, err.Error())
+			return
+		}
+		p.writeInternalError(w, "Failed to change recording", err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, p.meetingInfoFromRecord(record))
+}
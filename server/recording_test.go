@@ -0,0 +1,39 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+func TestWithoutRecordingActions(t *testing.T) {
+	tests := []struct {
+		name    string
+		actions []string
+		want    []string
+	}{
+		{name: "no actions", actions: nil, want: nil},
+		{name: "only recording", actions: []string{"recordingstart"}, want: nil},
+		{name: "recording stopped", actions: []string{"join", "recordingstop", "end"}, want: []string{"join", "end"}},
+		{name: "other actions", actions: []string{"join", "end"}, want: []string{"join", "end"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actions := make([]*model.PostAction, 0, len(tt.actions))
+			for _, id := range tt.actions {
+				actions = append(actions, &model.PostAction{Id: id})
+			}
+
+			got := withoutRecordingActions(actions)
+			if len(got) != len(tt.want) {
+				t.Fatalf("withoutRecordingActions(%v) kept %d actions, want %v", tt.actions, len(got), tt.want)
+			}
+			for i, action := range got {
+				if action.Id != tt.want[i] {
+					t.Errorf("withoutRecordingActions(%v)[%d] = %q, want %q", tt.actions, i, action.Id, tt.want[i])
+				}
+			}
+		})
+	}
+}
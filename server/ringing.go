@@ -0,0 +1,182 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/pluginapi/i18n"
+)
+
+// Websocket events that make the webapp ring and stop ringing.
+const (
+	incomingCallEvent = "incoming_call"
+	callStoppedEvent  = "call_stopped"
+)
+
+// getCallees returns the members of a DM or group channel to ring, leaving
+// out the caller and bots.
+func (p *Plugin) getCallees(channel *model.Channel, callerID string) []string {
+	if !channel.IsGroupOrDirect() {
+		return nil
+	}
+
+	members, appErr := p.API.GetChannelMembers(channel.Id, 0, maxCallInvitees+1)
+	if appErr != nil {
+		p.API.LogWarn("Failed to get channel members", "channel_id", channel.Id, "error", appErr.Error())
+		return nil
+	}
+
+	var userIDs []string
+	for _, member := range members {
+		if member.UserId != callerID {
+			userIDs = append(userIDs, member.UserId)
+		}
+	}
+	if len(userIDs) == 0 {
+		return nil
+	}
+
+	users, appErr := p.API.GetUsersByIds(userIDs)
+	if appErr != nil {
+		p.API.LogWarn("Failed to get channel members", "channel_id", channel.Id, "error", appErr.Error())
+		return nil
+	}
+
+	var callees []string
+	for _, user := range users {
+		if !user.IsBot && user.DeleteAt == 0 {
+			callees = append(callees, user.Id)
+		}
+	}
+
+	return callees
+}
+
+// ringCall tells the webapp of every invitee that a call is coming in, and
+// schedules the missed call check.
+func (p *Plugin) ringCall(record *MeetingRecord, caller *model.User) {
+	timeout := p.getConfiguration().DigitalSambaCallTimeout
+
+	data := map[string]interface{}{
+		"room_id":         record.RoomID,
+		"meeting_id":      record.MeetingID,
+		"meeting_url":     record.MeetingURL,
+		"topic":           record.Topic,
+		"channel_id":      record.ChannelID,
+		"post_id":         record.PostID,
+		"caller_id":       caller.Id,
+		"caller_username": caller.Username,
+	}
+	if timeout > 0 {
+		data["expires_at"] = record.CreatedAt + (time.Duration(timeout) * time.Minute).Milliseconds()
+	}
+
+	for _, userID := range record.Invitees {
+		p.API.PublishWebSocketEvent(incomingCallEvent, data, &model.WebsocketBroadcast{UserId: userID})
+	}
+
+	if timeout > 0 {
+		deadline := data["expires_at"].(int64)
+		err := p.updateRingingCalls(func(deadlines map[string]int64) {
+			deadlines[record.RoomID] = deadline
+		})
+		if err != nil {
+			p.API.LogWarn("Failed to store the ring deadline", "room_id", record.RoomID, "error", err.Error())
+		}
+	}
+}
+
+// stopRinging dismisses the incoming call in the webapp of the given users,
+// on every device they are logged in on.
+func (p *Plugin) stopRinging(record *MeetingRecord, userIDs []string) {
+	data := map[string]interface{}{
+		"room_id": record.RoomID,
+	}
+	for _, userID := range userIDs {
+		p.API.PublishWebSocketEvent(callStoppedEvent, data, &model.WebsocketBroadcast{UserId: userID})
+	}
+}
+
+// pendingCallees returns the invitees that have not declined the call.
+func pendingCallees(record *MeetingRecord) []string {
+	declined := map[string]bool{}
+	for _, userID := range record.Declined {
+		declined[userID] = true
+	}
+
+	var pending []string
+	for _, userID := range record.Invitees {
+		if !declined[userID] {
+			pending = append(pending, userID)
+		}
+	}
+
+	return pending
+}
+
+func (p *Plugin) handleDeclineCall(w http.ResponseWriter, r *http.Request) {
+	userID := r.Header.Get("Mattermost-User-Id")
+
+	record, ok := p.getMeetingForRequest(w, r)
+	if !ok {
+		return
+	}
+
+	if !slices.Contains(record.Invitees, userID) {
+		writeError(w, http.StatusBadRequest, errorCodeBadRequest, "You were not called to this meeting")
+		return
+	}
+
+	if err := p.declineCall(record, userID); err != nil {
+		p.writeInternalError(w, "Failed to decline call", err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, p.meetingInfoFromRecord(record))
+}
+
+// declineCall records that an invitee declined the call and says so in the
+// meeting thread.
+func (p *Plugin) declineCall(record *MeetingRecord, userID string) error {
+	if record.EndedAt != 0 || slices.Contains(record.Declined, userID) {
+		p.stopRinging(record, []string{userID})
+		return nil
+	}
+
+	user, appErr := p.API.GetUser(userID)
+	if appErr != nil {
+		return appErr
+	}
+
+	declined := false
+	if err := p.updateMeetingRecord(record, func(record *MeetingRecord) bool {
+		declined = !slices.Contains(record.Declined, userID)
+		if declined {
+			record.Declined = append(record.Declined, userID)
+		}
+		return declined
+	}); err != nil {
+		return err
+	}
+
+	p.stopRinging(record, []string{userID})
+	if !declined {
+		return nil
+	}
+
+	l := p.b.GetServerLocalizer()
+	message := p.b.LocalizeWithConfig(l, &i18n.LocalizeConfig{
+		DefaultMessage: &i18n.Message{
+			ID:    "digitalsamba.call.declined",
+			Other: "@{{.Username}} declined the call",
+		},
+		TemplateData: map[string]string{
+			"Username": user.Username,
+		},
+	})
+	p.postThreadReply(record, message)
+
+	return nil
+}
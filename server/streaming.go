@@ -0,0 +1,248 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+const streamCommandUsage = "Use `/digitalsamba stream start [target]` or `/digitalsamba stream stop` in the channel or thread of a running meeting."
+
+// StreamTarget is an RTMP server meetings can be streamed to, configured by
+// a system admin. A target can be limited to meetings in some channels, or
+// to streams started by system admins.
+type StreamTarget struct {
+	URL        string   `json:"url"`
+	Key        string   `json:"key"`
+	Channels   []string `json:"channels,omitempty"`
+	AdminsOnly bool     `json:"admins_only,omitempty"`
+}
+
+// canUseStreamTarget reports whether the user may stream a meeting in the
+// channel to the target.
+func (p *Plugin) canUseStreamTarget(userID, channelID string, target *StreamTarget) bool {
+	if len(target.Channels) > 0 && !slices.Contains(target.Channels, channelID) {
+		return false
+	}
+	if target.AdminsOnly && !p.API.HasPermissionTo(userID, model.PermissionManageSystem) {
+		return false
+	}
+	return true
+}
+
+// meetingPostStreamField is the title of the live stream status on the
+// meeting post.
+const meetingPostStreamField = "Live stream"
+
+// resolveStreamTarget picks the named stream target, or the only one when no
+// name is given, among the targets the user may stream the meeting to.
+func (p *Plugin) resolveStreamTarget(record *MeetingRecord, userID, name string) (string, *StreamTarget, error) {
+	configured, err := p.getConfiguration().GetStreamTargets()
+	if err != nil {
+		return "", nil, err
+	}
+
+	targets := make(map[string]StreamTarget, len(configured))
+	for targetName, target := range configured {
+		if p.canUseStreamTarget(userID, record.ChannelID, &target) {
+			targets[targetName] = target
+		}
+	}
+
+	if _, ok := configured[name]; ok && name != "" {
+		if _, ok := targets[name]; !ok {
+			return "", nil, fmt.Errorf("this meeting cannot be streamed to %s", name)
+		}
+	}
+
+	if len(targets) == 0 {
+		return "", nil, fmt.Errorf("no stream targets are available for this meeting")
+	}
+
+	names := make([]string, 0, len(targets))
+	for targetName := range targets {
+		names = append(names, targetName)
+	}
+	sort.Strings(names)
+
+	if name == "" {
+		if len(targets) > 1 {
+			return "", nil, fmt.Errorf("choose a stream target: %s", strings.Join(names, ", "))
+		}
+		name = names[0]
+	}
+
+	target, ok := targets[name]
+	if !ok {
+		return "", nil, fmt.Errorf("unknown stream target %q. Stream targets: %s", name, strings.Join(names, ", "))
+	}
+
+	return name, &target, nil
+}
+
+// startStreaming streams the meeting to a stream target and shows it on the
+// meeting post.
+func (p *Plugin) startStreaming(record *MeetingRecord, userID, targetName string) error {
+	if record.StreamTarget != "" {
+		return fmt.Errorf("the meeting is already streamed to %s", record.StreamTarget)
+	}
+
+	name, target, err := p.resolveStreamTarget(record, userID, targetName)
+	if err != nil {
+		return err
+	}
+
+	if err := p.getAccount(record.TeamID).client.StartStreaming(record.RoomID, &StartStreamingRequest{
+		RTMPURL:   target.URL,
+		StreamKey: target.Key,
+	}); err != nil {
+		return fmt.Errorf("failed to start the stream: %w", err)
+	}
+
+	if err := p.updateMeetingRecord(record, func(record *MeetingRecord) bool {
+		record.StreamTarget = name
+		record.StreamStartedAt = model.GetMillis()
+		return true
+	}); err != nil {
+		return err
+	}
+
+	p.updateMeetingPostField(record, "stream_target", meetingPostStreamField, name, fmt.Sprintf("Streaming live to %s", name))
+	p.audit(auditActionStreamStart, userID, record.ChannelID, record.RoomID, map[string]string{"target": name})
+
+	return nil
+}
+
+// stopStreaming stops the meeting's live stream.
+func (p *Plugin) stopStreaming(record *MeetingRecord, userID string) error {
+	if record.StreamTarget == "" {
+		return fmt.Errorf("the meeting is not being streamed")
+	}
+
+	if err := p.getAccount(record.TeamID).client.StopStreaming(record.RoomID); err != nil {
+		return fmt.Errorf("failed to stop the stream: %w", err)
+	}
+
+	target := record.StreamTarget
+	if err := p.updateMeetingRecord(record, func(record *MeetingRecord) bool {
+		record.StreamTarget = ""
+		record.StreamStartedAt = 0
+		return true
+	}); err != nil {
+		return err
+	}
+
+	p.updateMeetingPostField(record, "stream_target", meetingPostStreamField, "", "")
+	p.audit(auditActionStreamStop, userID, record.ChannelID, record.RoomID, map[string]string{"target": target})
+
+	return nil
+}
+
+// updateMeetingPostField shows a status of the meeting on its post: as a post
+// property for the webapp and as an attachment field for other clients. An
+// empty value removes the status.
+func (p *Plugin) updateMeetingPostField(record *MeetingRecord, prop, title, value, text string) {
+	p.updateMeetingPost(record, func(post *model.Post, attachments []*model.SlackAttachment) {
+		setMeetingPostField(post, attachments, prop, title, value, text)
+	})
+}
+
+// updateMeetingPost applies a change to the meeting post and its attachments.
+func (p *Plugin) updateMeetingPost(record *MeetingRecord, update func(post *model.Post, attachments []*model.SlackAttachment)) {
+	if record.PostID == "" {
+		return
+	}
+
+	post, appErr := p.API.GetPost(record.PostID)
+	if appErr != nil {
+		p.API.LogWarn("Failed to get meeting post", "post_id", record.PostID, "error", appErr.Error())
+		return
+	}
+
+	attachments := post.Attachments()
+	update(post, attachments)
+	post.AddProp("attachments", attachments)
+
+	if _, appErr := p.API.UpdatePost(post); appErr != nil {
+		p.API.LogWarn("Failed to update meeting post", "post_id", record.PostID, "error", appErr.Error())
+	}
+}
+
+func setMeetingPostField(post *model.Post, attachments []*model.SlackAttachment, prop, title, value, text string) {
+	if value == "" {
+		post.DelProp(prop)
+	} else {
+		post.AddProp(prop, value)
+	}
+
+	for _, attachment := range attachments {
+		fields := make([]*model.SlackAttachmentField, 0, len(attachment.Fields)+1)
+		for _, field := range attachment.Fields {
+			if field.Title != title {
+				fields = append(fields, field)
+			}
+		}
+		if text != "" {
+			fields = append(fields, &model.SlackAttachmentField{Title: title, Value: text, Short: true})
+		}
+		attachment.Fields = fields
+	}
+}
+
+// clearStreamStatus forgets the live stream of a meeting whose session
+// ended, which stops the stream in DigitalSamba.
+func (p *Plugin) clearStreamStatus(record *MeetingRecord) {
+	cleared := false
+	if err := p.updateMeetingRecord(record, func(record *MeetingRecord) bool {
+		cleared = record.StreamTarget != ""
+		record.StreamTarget = ""
+		record.StreamStartedAt = 0
+		return cleared
+	}); err != nil {
+		p.API.LogWarn("Failed to clear stream status", "room_id", record.RoomID, "error", err.Error())
+		return
+	}
+	if !cleared {
+		return
+	}
+
+	p.updateMeetingPostField(record, "stream_target", meetingPostStreamField, "", "")
+}
+
+func (p *Plugin) runStreamCommand(args *model.CommandArgs, fields []string) (*model.CommandResponse, *model.AppError) {
+	if len(fields) == 0 || (fields[0] != "start" && fields[0] != "stop") {
+		return p.sendEphemeralResponse(args, streamCommandUsage)
+	}
+
+	record, err := p.findActiveMeeting(args.ChannelId, args.RootId)
+	if err != nil {
+		return p.sendEphemeralResponse(args, "Failed to find the meeting")
+	}
+	if record == nil {
+		return p.sendEphemeralResponse(args, "There is no running meeting here. "+streamCommandUsage)
+	}
+
+	if !p.canManageMeeting(args.UserId, record) {
+		return p.sendEphemeralResponse(args, "Only the meeting creator or a channel admin can stream the meeting")
+	}
+
+	if fields[0] == "stop" {
+		if err := p.stopStreaming(record, args.UserId); err != nil {
+			return p.sendEphemeralResponse(args, fmt.Sprintf("Failed to stop streaming: %v", err))
+		}
+		return p.sendEphemeralResponse(args, "The live stream has stopped.")
+	}
+
+	targetName := ""
+	if len(fields) > 1 {
+		targetName = fields[1]
+	}
+	if err := p.startStreaming(record, args.UserId, targetName); err != nil {
+		return p.sendEphemeralResponse(args, fmt.Sprintf("Failed to start streaming: %v", err))
+	}
+
+	return p.sendEphemeralResponse(args, fmt.Sprintf("**%s** is streaming live to %s.", record.Topic, record.StreamTarget))
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGetStreamTargets(t *testing.T) {
+	c := &configuration{DigitalSambaStreamTargets: `{
+		"townhall": {"url": "rtmps://video.example.com/live", "key": "secret", "channels": ["c1"]},
+		"press": {"url": "rtmp://press.example.com/live", "key": "k", "admins_only": true}
+	}`}
+
+	got, err := c.GetStreamTargets()
+	if err != nil {
+		t.Fatalf("GetStreamTargets() error = %v", err)
+	}
+
+	want := map[string]StreamTarget{
+		"townhall": {URL: "rtmps://video.example.com/live", Key: "secret", Channels: []string{"c1"}},
+		"press":    {URL: "rtmp://press.example.com/live", Key: "k", AdminsOnly: true},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("GetStreamTargets() = %+v, want %+v", got, want)
+	}
+}
+
+func TestCanUseStreamTargetChannels(t *testing.T) {
+	tests := []struct {
+		name      string
+		channels  []string
+		channelID string
+		want      bool
+	}{
+		{name: "any channel", channelID: "c1", want: true},
+		{name: "allowed channel", channels: []string{"c1", "c2"}, channelID: "c2", want: true},
+		{name: "other channel", channels: []string{"c1"}, channelID: "c3", want: false},
+	}
+
+	var p *Plugin
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target := &StreamTarget{URL: "rtmps://video.example.com/live", Channels: tt.channels}
+			if got := p.canUseStreamTarget("user1", tt.channelID, target); got != tt.want {
+				t.Errorf("canUseStreamTarget(%q) = %v, want %v", tt.channelID, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+const tokenCacheKeyPrefix = "token_"
+
+// tokenGenerationKeyPrefix holds a user's token generation. Cached tokens of
+// an older generation are ignored, which drops all of the user's tokens at
+// once without listing them.
+const tokenGenerationKeyPrefix = "token_generation_"
+
+const tokenRoleModerator = "moderator"
+const tokenRoleAttendee = "attendee"
+
+// tokenRoles are all the roles a cached token can have. They are used to drop
+// a user's tokens for a room when their role changes or they lose access.
+var tokenRoles = []string{tokenRoleModerator, tokenRoleAttendee}
+
+// tokenRefreshMargin is how long before expiry a cached token is replaced, so
+// a client never receives a token that expires while it joins.
+const tokenRefreshMargin = 5 * time.Minute
+
+// defaultTokenTTL is used for tokens the API returns without an expiry.
+const defaultTokenTTL = time.Hour
+
+type cachedToken struct {
+	Token      string `json:"token"`
+	RoomURL    string `json:"room_url"`
+	ExpiresAt  int64  `json:"expires_at"`
+	Generation string `json:"generation,omitempty"`
+}
+
+func tokenCacheKey(roomID, userID, role string) string {
+	return fmt.Sprintf("%s%s_%s_%s", tokenCacheKeyPrefix, roomID, userID, role)
+}
+
+// newTokenRequest builds the token request for a Mattermost user.
+func (p *Plugin) newTokenRequest(user *model.User, roomID, role string) *CreateTokenRequest {
+	tokenReq := &CreateTokenRequest{
+		RoomID:    roomID,
+		UserID:    user.Id,
+		UserName:  user.GetDisplayName(model.ShowNicknameFullName),
+		UserEmail: user.Email,
+		Role:      role,
+	}
+
+	// Add avatar URL if available
+	siteURL := p.API.GetConfig().ServiceSettings.SiteURL
+	if siteURL != nil && *siteURL != "" {
+		tokenReq.AvatarURL = fmt.Sprintf("%s/api/v4/users/%s/image?_=%d", *siteURL, user.Id, user.LastPictureUpdate)
+	}
+
+	return tokenReq
+}
+
+// getToken returns a token for the user in the room, reusing a cached token
+// while it is valid for longer than tokenRefreshMargin.
+func (p *Plugin) getToken(account *digitalSambaAccount, user *model.User, roomID, role string) (*RoomToken, error) {
+	key := tokenCacheKey(roomID, user.Id, role)
+	generation := p.getTokenGeneration(user.Id)
+
+	data, appErr := p.API.KVGet(key)
+	if appErr != nil {
+		p.API.LogWarn("Failed to read cached token", "room_id", roomID, "user_id", user.Id, "error", appErr.Error())
+	}
+	if data != nil {
+		var cached cachedToken
+		if err := json.Unmarshal(data, &cached); err == nil && cached.Generation == generation && time.Until(time.UnixMilli(cached.ExpiresAt)) > tokenRefreshMargin {
+			expiresAt := time.UnixMilli(cached.ExpiresAt)
+			p.audit(auditActionTokenIssue, user.Id, "", roomID, map[string]string{"role": role, "cached": "true"})
+			return &RoomToken{Token: cached.Token, RoomURL: cached.RoomURL, Role: role, ExpiresAt: &expiresAt}, nil
+		}
+	}
+
+	token, err := account.client.CreateToken(p.newTokenRequest(user, roomID, role))
+	if err != nil {
+		return nil, err
+	}
+
+	p.cacheToken(roomID, user.Id, role, generation, token)
+	p.audit(auditActionTokenIssue, user.Id, "", roomID, map[string]string{"role": role})
+
+	return token, nil
+}
+
+// cacheToken stores a token until shortly before it expires and drops the
+// user's tokens for the room with any other role.
+func (p *Plugin) cacheToken(roomID, userID, role, generation string, token *RoomToken) {
+	for _, otherRole := range tokenRoles {
+		if otherRole != role {
+			_ = p.API.KVDelete(tokenCacheKey(roomID, userID, otherRole))
+		}
+	}
+
+	expiresAt := time.Now().Add(defaultTokenTTL)
+	if token.ExpiresAt != nil {
+		expiresAt = *token.ExpiresAt
+	}
+
+	ttl := time.Until(expiresAt) - tokenRefreshMargin
+	if ttl <= 0 {
+		return
+	}
+
+	b, err := json.Marshal(&cachedToken{
+		Token:      token.Token,
+		RoomURL:    token.RoomURL,
+		ExpiresAt:  expiresAt.UnixMilli(),
+		Generation: generation,
+	})
+	if err != nil {
+		return
+	}
+
+	if appErr := p.API.KVSetWithExpiry(tokenCacheKey(roomID, userID, role), b, int64(ttl.Seconds())); appErr != nil {
+		p.API.LogWarn("Failed to cache token", "room_id", roomID, "user_id", userID, "error", appErr.Error())
+	}
+}
+
+// getTokenGeneration returns the user's current token generation, empty
+// until their tokens are first invalidated.
+func (p *Plugin) getTokenGeneration(userID string) string {
+	data, appErr := p.API.KVGet(tokenGenerationKeyPrefix + userID)
+	if appErr != nil {
+		p.API.LogWarn("Failed to read token generation", "user_id", userID, "error", appErr.Error())
+		return ""
+	}
+	return string(data)
+}
+
+// invalidateUserTokens drops the user's cached tokens for every room, for
+// example when their role in a meeting may have changed or they lost access.
+func (p *Plugin) invalidateUserTokens(userID string) {
+	if appErr := p.API.KVSet(tokenGenerationKeyPrefix+userID, []byte(model.NewId())); appErr != nil {
+		p.API.LogWarn("Failed to invalidate cached tokens", "user_id", userID, "error", appErr.Error())
+	}
+}
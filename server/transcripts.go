@@ -0,0 +1,363 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/pluginapi/i18n"
+)
+
+// transcriptPostKeyPrefix holds the transcript posts to delete once the
+// retention period expires, one key per post.
+const transcriptPostKeyPrefix = "transcript_post_"
+
+// TranscriptSegment is one stretch of speech in a transcript.
+type TranscriptSegment struct {
+	Start      time.Duration
+	Speaker    string
+	ExternalID string
+	Text       string
+}
+
+// TranscriptPost is a transcript posted in a meeting thread, kept until the
+// retention period expires.
+type TranscriptPost struct {
+	PostID    string `json:"post_id"`
+	RoomID    string `json:"room_id"`
+	CreatedAt int64  `json:"created_at"`
+}
+
+// transcriptJSONSegment is a segment of a JSON transcript. DigitalSamba puts
+// the "ud" of the participant's token in external_id.
+type transcriptJSONSegment struct {
+	Start           float64 `json:"start"`
+	Speaker         string  `json:"speaker"`
+	ParticipantName string  `json:"participant_name"`
+	ExternalID      string  `json:"external_id"`
+	Text            string  `json:"text"`
+}
+
+var (
+	cueTimeRegexp     = regexp.MustCompile(`^(?:(\d+):)?(\d{1,2}):(\d{2})[.,](\d{1,3})`)
+	voiceTagRegexp    = regexp.MustCompile(`^<v(?:\.[^ >]*)?\s+([^>]+)>(.*?)(?:</v>)?$`)
+	speakerLineRegexp = regexp.MustCompile(`^([^:]{1,40}):\s+(.+)$`)
+)
+
+// handleTranscriptReady downloads the transcript of a session and posts it
+// in the meeting thread as a markdown file, unless the channel opted out.
+func (p *Plugin) handleTranscriptReady(event *DigitalSambaEvent, record *MeetingRecord) {
+	if !p.transcriptsEnabled(record.ChannelID) {
+		return
+	}
+
+	client := p.getAccount(record.TeamID).client
+
+	data, contentType, err := client.ExportTranscript(event.Data.SessionID)
+	if err != nil {
+		p.API.LogWarn("Failed to download transcript", "room_id", record.RoomID, "session_id", event.Data.SessionID, "error", err.Error())
+		return
+	}
+
+	segments, err := parseTranscript(data, contentType)
+	if err != nil {
+		p.API.LogWarn("Failed to parse transcript", "room_id", record.RoomID, "session_id", event.Data.SessionID, "error", err.Error())
+		return
+	}
+	if len(segments) == 0 {
+		return
+	}
+
+	// WebVTT and SRT only carry names, the session participants link them to
+	// the "ud" of their token
+	externalIDs := map[string]string{}
+	if participants, err := client.ListSessionParticipants(event.Data.SessionID); err == nil {
+		for _, participant := range participants {
+			if participant.ExternalID != "" {
+				externalIDs[participant.Name] = participant.ExternalID
+			}
+		}
+	}
+
+	content := p.formatTranscript(record, segments, externalIDs)
+
+	l := p.b.GetServerLocalizer()
+	message := p.b.LocalizeWithConfig(l, &i18n.LocalizeConfig{
+		DefaultMessage: &i18n.Message{
+			ID:    "digitalsamba.transcript.posted",
+			Other: "Transcript of **{{.Topic}}**",
+		},
+		TemplateData: map[string]string{
+			"Topic": record.Topic,
+		},
+	})
+
+	post, err := p.postThreadFile(record, message, fmt.Sprintf("transcript-%s.md", record.MeetingID), []byte(content))
+	if err != nil {
+		p.API.LogWarn("Failed to post transcript", "room_id", record.RoomID, "error", err.Error())
+		return
+	}
+
+	p.addTranscriptPost(&TranscriptPost{
+		PostID:    post.Id,
+		RoomID:    record.RoomID,
+		CreatedAt: post.CreateAt,
+	})
+}
+
+// transcriptsEnabled reports whether transcripts are posted in the channel.
+// Channels opt out with the "transcripts" channel setting.
+func (p *Plugin) transcriptsEnabled(channelID string) bool {
+	settings, err := p.getChannelSettings(channelID)
+	if err != nil {
+		p.API.LogWarn("Failed to load channel settings", "channel_id", channelID, "error", err.Error())
+		return false
+	}
+
+	return settings.Transcripts == nil || *settings.Transcripts
+}
+
+// parseTranscript reads a WebVTT, SRT or JSON transcript. The content type
+// is only a hint, the content decides.
+func parseTranscript(data []byte, contentType string) ([]TranscriptSegment, error) {
+	trimmed := bytes.TrimSpace(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")))
+	if len(trimmed) == 0 {
+		return nil, nil
+	}
+
+	if strings.Contains(contentType, "json") || trimmed[0] == '{' || trimmed[0] == '[' {
+		return parseJSONTranscript(trimmed)
+	}
+
+	return parseCueTranscript(string(trimmed))
+}
+
+// parseJSONTranscript reads either a list of segments or an object with a
+// "segments" list.
+func parseJSONTranscript(data []byte) ([]TranscriptSegment, error) {
+	var jsonSegments []transcriptJSONSegment
+	if data[0] == '[' {
+		if err := json.Unmarshal(data, &jsonSegments); err != nil {
+			return nil, fmt.Errorf("invalid JSON transcript: %w", err)
+		}
+	} else {
+		var transcript struct {
+			Segments []transcriptJSONSegment `json:"segments"`
+		}
+		if err := json.Unmarshal(data, &transcript); err != nil {
+			return nil, fmt.Errorf("invalid JSON transcript: %w", err)
+		}
+		jsonSegments = transcript.Segments
+	}
+
+	segments := make([]TranscriptSegment, 0, len(jsonSegments))
+	for _, s := range jsonSegments {
+		speaker := s.Speaker
+		if speaker == "" {
+			speaker = s.ParticipantName
+		}
+		segments = append(segments, TranscriptSegment{
+			Start:      time.Duration(s.Start * float64(time.Second)),
+			Speaker:    speaker,
+			ExternalID: s.ExternalID,
+			Text:       strings.TrimSpace(s.Text),
+		})
+	}
+
+	return segments, nil
+}
+
+// parseCueTranscript reads WebVTT and SRT, which only differ in details the
+// parser can ignore: the WEBVTT header, cue identifiers and the decimal
+// separator of timestamps.
+func parseCueTranscript(data string) ([]TranscriptSegment, error) {
+	blocks := strings.Split(strings.ReplaceAll(data, "\r\n", "\n"), "\n\n")
+
+	var segments []TranscriptSegment
+	for _, block := range blocks {
+		lines := strings.Split(strings.TrimSpace(block), "\n")
+
+		timing := -1
+		for i, line := range lines {
+			if strings.Contains(line, "-->") {
+				timing = i
+				break
+			}
+		}
+		// Headers, NOTE and STYLE blocks have no timing line
+		if timing == -1 {
+			continue
+		}
+
+		start, err := parseCueTime(strings.TrimSpace(strings.Split(lines[timing], "-->")[0]))
+		if err != nil {
+			return nil, err
+		}
+
+		segment := TranscriptSegment{Start: start}
+		var text []string
+		for _, line := range lines[timing+1:] {
+			line = strings.TrimSpace(line)
+			if match := voiceTagRegexp.FindStringSubmatch(line); match != nil {
+				segment.Speaker = strings.TrimSpace(match[1])
+				line = match[2]
+			} else if match := speakerLineRegexp.FindStringSubmatch(line); match != nil && segment.Speaker == "" {
+				segment.Speaker = strings.TrimSpace(match[1])
+				line = match[2]
+			}
+			if line != "" {
+				text = append(text, line)
+			}
+		}
+		segment.Text = strings.Join(text, " ")
+
+		if segment.Text != "" {
+			segments = append(segments, segment)
+		}
+	}
+
+	return segments, nil
+}
+
+// parseCueTime parses "hh:mm:ss.mmm", "mm:ss.mmm" and "hh:mm:ss,mmm".
+func parseCueTime(value string) (time.Duration, error) {
+	match := cueTimeRegexp.FindStringSubmatch(value)
+	if match == nil {
+		return 0, fmt.Errorf("invalid cue time %q", value)
+	}
+
+	hours := 0
+	if match[1] != "" {
+		hours, _ = strconv.Atoi(match[1])
+	}
+	minutes, _ := strconv.Atoi(match[2])
+	seconds, _ := strconv.Atoi(match[3])
+	millis, _ := strconv.Atoi((match[4] + "00")[:3])
+
+	return time.Duration(hours)*time.Hour +
+		time.Duration(minutes)*time.Minute +
+		time.Duration(seconds)*time.Second +
+		time.Duration(millis)*time.Millisecond, nil
+}
+
+// formatTranscript renders the transcript as markdown, merging consecutive
+// segments of the same speaker. Speakers with a Mattermost account are shown
+// by their display name.
+func (p *Plugin) formatTranscript(record *MeetingRecord, segments []TranscriptSegment, externalIDs map[string]string) string {
+	participantName := p.newParticipantNameResolver()
+	speakerName := func(segment TranscriptSegment) string {
+		externalID := segment.ExternalID
+		if externalID == "" {
+			externalID = externalIDs[segment.Speaker]
+		}
+		if name := participantName(externalID, segment.Speaker); name != "" {
+			return name
+		}
+		return "Unknown speaker"
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "# %s\n\n", record.Topic)
+	fmt.Fprintf(&sb, "_%s_\n", time.UnixMilli(record.CreatedAt).UTC().Format("2006-01-02 15:04 MST"))
+
+	lastSpeaker := ""
+	for _, segment := range segments {
+		speaker := speakerName(segment)
+		if speaker != lastSpeaker {
+			fmt.Fprintf(&sb, "\n**%s** [%s]\n", speaker, formatTranscriptTime(segment.Start))
+			lastSpeaker = speaker
+		}
+		sb.WriteString(segment.Text)
+		sb.WriteString("\n")
+	}
+
+	return sb.String()
+}
+
+func formatTranscriptTime(d time.Duration) string {
+	seconds := int(d / time.Second)
+	return fmt.Sprintf("%02d:%02d:%02d", seconds/3600, seconds/60%60, seconds%60)
+}
+
+// addTranscriptPost keeps the transcript post until the retention period
+// expires. Each post has its own key, so that posting never contends with
+// other servers. Without a retention period nothing is deleted, and nothing
+// is kept.
+func (p *Plugin) addTranscriptPost(post *TranscriptPost) {
+	if p.getConfiguration().DigitalSambaTranscriptRetentionDays <= 0 {
+		return
+	}
+
+	b, err := json.Marshal(post)
+	if err != nil {
+		return
+	}
+
+	if appErr := p.API.KVSet(transcriptPostKeyPrefix+post.PostID, b); appErr != nil {
+		p.API.LogWarn("Failed to record transcript post", "post_id", post.PostID, "error", appErr.Error())
+	}
+}
+
+// getTranscriptPosts returns the transcript posts kept for deletion, with
+// the key each is stored under.
+func (p *Plugin) getTranscriptPosts() (map[string]*TranscriptPost, error) {
+	keys, err := p.listKeys(transcriptPostKeyPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	posts := map[string]*TranscriptPost{}
+	for _, key := range keys {
+		data, appErr := p.API.KVGet(key)
+		if appErr != nil {
+			return nil, appErr
+		}
+		if data == nil {
+			continue
+		}
+
+		var post TranscriptPost
+		if err := json.Unmarshal(data, &post); err != nil {
+			p.API.LogWarn("Failed to read transcript post", "key", key, "error", err.Error())
+			continue
+		}
+		posts[key] = &post
+	}
+
+	return posts, nil
+}
+
+// deleteExpiredTranscripts deletes the transcript posts older than the
+// retention period, together with their files.
+func (p *Plugin) deleteExpiredTranscripts() {
+	retentionDays := p.getConfiguration().DigitalSambaTranscriptRetentionDays
+	if retentionDays <= 0 {
+		return
+	}
+
+	posts, err := p.getTranscriptPosts()
+	if err != nil {
+		p.API.LogWarn("Failed to load transcript posts", "error", err.Error())
+		return
+	}
+
+	cutoff := model.GetMillis() - (time.Duration(retentionDays) * 24 * time.Hour).Milliseconds()
+	for key, post := range posts {
+		if post.CreatedAt >= cutoff {
+			continue
+		}
+		if appErr := p.API.DeletePost(post.PostID); appErr != nil && appErr.StatusCode != http.StatusNotFound {
+			p.API.LogWarn("Failed to delete transcript post", "post_id", post.PostID, "error", appErr.Error())
+			continue
+		}
+		if appErr := p.API.KVDelete(key); appErr != nil {
+			p.API.LogWarn("Failed to forget transcript post", "post_id", post.PostID, "error", appErr.Error())
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseTranscript(t *testing.T) {
+	tests := []struct {
+		name        string
+		data        string
+		contentType string
+		want        []TranscriptSegment
+		wantErr     bool
+	}{
+		{
+			name:        "empty",
+			data:        "  \n",
+			contentType: "text/vtt",
+			want:        nil,
+		},
+		{
+			name:        "WebVTT with voice tags",
+			data:        "WEBVTT\n\nNOTE exported by DigitalSamba\n\n1\n00:00:01.500 --> 00:00:04.000\n<v Alice>Hello everyone</v>\n\n2\n00:01:02.000 --> 00:01:05.000\n<v.loud Bob>Hi Alice\nhow are you?\n",
+			contentType: "text/vtt",
+			want: []TranscriptSegment{
+				{Start: 1500 * time.Millisecond, Speaker: "Alice", Text: "Hello everyone"},
+				{Start: time.Minute + 2*time.Second, Speaker: "Bob", Text: "Hi Alice how are you?"},
+			},
+		},
+		{
+			name:        "WebVTT with a byte order mark and short timestamps",
+			data:        "\xef\xbb\xbfWEBVTT\n\n01:02.250 --> 01:03.000\nAlice: Short\n",
+			contentType: "text/vtt",
+			want: []TranscriptSegment{
+				{Start: time.Minute + 2250*time.Millisecond, Speaker: "Alice", Text: "Short"},
+			},
+		},
+		{
+			name:        "SRT with speaker lines and CRLF",
+			data:        "1\r\n01:00:00,100 --> 01:00:02,000\r\nAlice: First line\r\n\r\n2\r\n01:00:03,000 --> 01:00:04,000\r\nno speaker here\r\n",
+			contentType: "application/x-subrip",
+			want: []TranscriptSegment{
+				{Start: time.Hour + 100*time.Millisecond, Speaker: "Alice", Text: "First line"},
+				{Start: time.Hour + 3*time.Second, Text: "no speaker here"},
+			},
+		},
+		{
+			name:        "cue without text is skipped",
+			data:        "WEBVTT\n\n00:00:01.000 --> 00:00:02.000\n\n00:00:03.000 --> 00:00:04.000\nAlice: Hi\n",
+			contentType: "text/vtt",
+			want: []TranscriptSegment{
+				{Start: 3 * time.Second, Speaker: "Alice", Text: "Hi"},
+			},
+		},
+		{
+			name:        "invalid cue time",
+			data:        "WEBVTT\n\nsoon --> later\nAlice: Hi\n",
+			contentType: "text/vtt",
+			wantErr:     true,
+		},
+		{
+			name:        "JSON list",
+			data:        `[{"start": 1.25, "speaker": "Alice", "external_id": "user1", "text": " Hello "}, {"start": 3, "participant_name": "Bob", "text": "Hi"}]`,
+			contentType: "application/json",
+			want: []TranscriptSegment{
+				{Start: 1250 * time.Millisecond, Speaker: "Alice", ExternalID: "user1", Text: "Hello"},
+				{Start: 3 * time.Second, Speaker: "Bob", Text: "Hi"},
+			},
+		},
+		{
+			name:        "JSON object detected without a content type",
+			data:        `{"segments": [{"start": 0.5, "speaker": "Alice", "text": "Hello"}]}`,
+			contentType: "application/octet-stream",
+			want: []TranscriptSegment{
+				{Start: 500 * time.Millisecond, Speaker: "Alice", Text: "Hello"},
+			},
+		},
+		{
+			name:        "invalid JSON",
+			data:        `{"segments": [`,
+			contentType: "application/json",
+			wantErr:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseTranscript([]byte(tt.data), tt.contentType)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseTranscript() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if len(got) == 0 && len(tt.want) == 0 {
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseTranscript() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseCueTime(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    time.Duration
+		wantErr bool
+	}{
+		{value: "00:00:00.000", want: 0},
+		{value: "01:02:03.004", want: time.Hour + 2*time.Minute + 3*time.Second + 4*time.Millisecond},
+		{value: "12:34.5", want: 12*time.Minute + 34*time.Second + 500*time.Millisecond},
+		{value: "00:00:01,250", want: 1250 * time.Millisecond},
+		{value: "100:00:00.000", want: 100 * time.Hour},
+		{value: "1.5", wantErr: true},
+		{value: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := parseCueTime(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseCueTime(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parseCueTime(%q) = %v, want %v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,288 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+// Events sent to outgoing webhooks.
+const (
+	webhookEventMeetingStarted    = "meeting.started"
+	webhookEventMeetingEnded      = "meeting.ended"
+	webhookEventParticipantJoined = "participant.joined"
+	webhookEventParticipantLeft   = "participant.left"
+	webhookEventRecordingReady    = "recording.ready"
+)
+
+const webhookDeadLettersKey = "webhook_dead_letters"
+
+// maxWebhookDeadLetters bounds the dead-letter log kept for admins.
+const maxWebhookDeadLetters = 100
+
+// webhookRetryDelays are the waits between delivery attempts.
+var webhookRetryDelays = []time.Duration{5 * time.Second, 30 * time.Second, 2 * time.Minute, 10 * time.Minute}
+
+// WebhookPayload is the JSON body sent to outgoing webhooks.
+type WebhookPayload struct {
+	ID          string              `json:"id"`
+	Event       string              `json:"event"`
+	Timestamp   int64               `json:"timestamp"`
+	Meeting     *MeetingInfo        `json:"meeting"`
+	Channel     *WebhookChannel     `json:"channel,omitempty"`
+	User        *WebhookUser        `json:"user,omitempty"`
+	Participant *WebhookParticipant `json:"participant,omitempty"`
+	Recording   *WebhookRecording   `json:"recording,omitempty"`
+}
+
+type WebhookChannel struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	DisplayName string `json:"display_name"`
+	Type        string `json:"type"`
+	TeamID      string `json:"team_id"`
+}
+
+type WebhookUser struct {
+	ID       string `json:"id"`
+	Username string `json:"username"`
+	Email    string `json:"email,omitempty"`
+}
+
+type WebhookParticipant struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Role   string `json:"role,omitempty"`
+	UserID string `json:"user_id,omitempty"`
+}
+
+type WebhookRecording struct {
+	ID  string `json:"id"`
+	URL string `json:"url,omitempty"`
+}
+
+// WebhookDeadLetter is a delivery that failed after every retry.
+type WebhookDeadLetter struct {
+	URL       string `json:"url"`
+	Event     string `json:"event"`
+	PayloadID string `json:"payload_id"`
+	Error     string `json:"error"`
+	Attempts  int    `json:"attempts"`
+	FailedAt  int64  `json:"failed_at"`
+}
+
+// emitWebhookEvent sends an event about a meeting to every configured
+// outgoing webhook. Delivery happens in the background.
+func (p *Plugin) emitWebhookEvent(event string, record *MeetingRecord, userID string, participant *WebhookParticipant, recording *WebhookRecording) {
+	config := p.getConfiguration()
+	urls := config.GetOutgoingWebhookURLs()
+	if len(urls) == 0 {
+		return
+	}
+
+	// Receivers cannot tell an unsigned payload from a forged one
+	if config.DigitalSambaWebhookSecret == "" {
+		p.API.LogWarn("Not sending outgoing webhooks without a signing secret", "event", event)
+		return
+	}
+
+	payload := &WebhookPayload{
+		ID:          model.NewId(),
+		Event:       event,
+		Timestamp:   model.GetMillis(),
+		Meeting:     p.meetingInfoFromRecord(record),
+		Participant: participant,
+		Recording:   recording,
+	}
+
+	if channel, appErr := p.API.GetChannel(record.ChannelID); appErr == nil {
+		payload.Channel = &WebhookChannel{
+			ID:          channel.Id,
+			Name:        channel.Name,
+			DisplayName: channel.DisplayName,
+			Type:        string(channel.Type),
+			TeamID:      channel.TeamId,
+		}
+	}
+
+	if userID != "" {
+		if user, appErr := p.API.GetUser(userID); appErr == nil {
+			payload.User = &WebhookUser{ID: user.Id, Username: user.Username, Email: user.Email}
+		}
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		p.API.LogError("Failed to marshal webhook payload", "event", event, "error", err.Error())
+		return
+	}
+
+	for _, url := range urls {
+		p.webhookDeliveries.Add(1)
+		go func(url string) {
+			defer p.webhookDeliveries.Done()
+			p.deliverWebhook(p.webhookCtx, url, payload, body)
+		}(url)
+	}
+}
+
+// deliverWebhook posts the payload, retrying with increasing delays, and
+// records a dead letter when every attempt fails or the plugin stops.
+func (p *Plugin) deliverWebhook(ctx context.Context, url string, payload *WebhookPayload, body []byte) {
+	var err error
+	attempts := 0
+	for {
+		attempts++
+		// The secret is read again, so a rotated secret applies to retries
+		err = p.postWebhook(ctx, url, p.getConfiguration().DigitalSambaWebhookSecret, payload, body)
+		if err == nil {
+			return
+		}
+
+		if attempts > len(webhookRetryDelays) {
+			break
+		}
+		p.API.LogDebug("Webhook delivery failed, retrying", "url", url, "event", payload.Event, "attempt", attempts, "error", err.Error())
+
+		timer := time.NewTimer(webhookRetryDelays[attempts-1])
+		select {
+		case <-timer.C:
+			continue
+		case <-ctx.Done():
+			timer.Stop()
+			err = fmt.Errorf("plugin stopped before the next attempt: %w", err)
+		}
+		break
+	}
+
+	p.API.LogWarn("Webhook delivery failed", "url", url, "event", payload.Event, "attempts", attempts, "error", err.Error())
+	p.addWebhookDeadLetter(&WebhookDeadLetter{
+		URL:       url,
+		Event:     payload.Event,
+		PayloadID: payload.ID,
+		Error:     err.Error(),
+		Attempts:  attempts,
+		FailedAt:  model.GetMillis(),
+	})
+}
+
+// postWebhook makes one delivery attempt. Each attempt is signed with the
+// time it is sent, so that receivers rejecting stale timestamps accept
+// retries.
+func (p *Plugin) postWebhook(ctx context.Context, url, secret string, payload *WebhookPayload, body []byte) error {
+	if secret == "" {
+		return fmt.Errorf("no webhook signing secret is configured")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	timestamp := strconv.FormatInt(model.GetMillis(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-DigitalSamba-Event", payload.Event)
+	req.Header.Set("X-DigitalSamba-Delivery", payload.ID)
+	req.Header.Set("X-DigitalSamba-Timestamp", timestamp)
+	req.Header.Set("X-DigitalSamba-Signature", "sha256="+signWebhook(secret, timestamp, body))
+
+	resp, err := p.webhookClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// signWebhook signs "<timestamp>.<body>" with HMAC-SHA256, so receivers can
+// reject replays of old payloads.
+func signWebhook(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (p *Plugin) getWebhookDeadLetters() ([]*WebhookDeadLetter, error) {
+	data, appErr := p.API.KVGet(webhookDeadLettersKey)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	if data == nil {
+		return nil, nil
+	}
+
+	var deadLetters []*WebhookDeadLetter
+	if err := json.Unmarshal(data, &deadLetters); err != nil {
+		return nil, err
+	}
+
+	return deadLetters, nil
+}
+
+func (p *Plugin) addWebhookDeadLetter(deadLetter *WebhookDeadLetter) {
+	for i := 0; i < 5; i++ {
+		oldData, appErr := p.API.KVGet(webhookDeadLettersKey)
+		if appErr != nil {
+			break
+		}
+
+		var deadLetters []*WebhookDeadLetter
+		if oldData != nil {
+			if err := json.Unmarshal(oldData, &deadLetters); err != nil {
+				break
+			}
+		}
+
+		deadLetters = append(deadLetters, deadLetter)
+		if len(deadLetters) > maxWebhookDeadLetters {
+			deadLetters = deadLetters[len(deadLetters)-maxWebhookDeadLetters:]
+		}
+
+		newData, err := json.Marshal(deadLetters)
+		if err != nil {
+			break
+		}
+
+		if ok, appErr := p.API.KVCompareAndSet(webhookDeadLettersKey, oldData, newData); appErr == nil && ok {
+			return
+		}
+	}
+
+	p.API.LogError("Failed to record webhook dead letter", "url", deadLetter.URL, "event", deadLetter.Event)
+}
+
+func formatWebhookDeadLetters(deadLetters []*WebhookDeadLetter) string {
+	if len(deadLetters) == 0 {
+		return "No failed webhook deliveries."
+	}
+
+	var sb strings.Builder
+	sb.WriteString("#### Failed webhook deliveries\n\n| Failed at | Event | URL | Attempts | Error |\n| --- | --- | --- | --- | --- |\n")
+	for i := len(deadLetters) - 1; i >= 0; i-- {
+		d := deadLetters[i]
+		fmt.Fprintf(&sb, "| %s | %s | %s | %d | %s |\n",
+			time.UnixMilli(d.FailedAt).UTC().Format(time.RFC3339), d.Event, d.URL, d.Attempts, strings.ReplaceAll(d.Error, "|", "\\|"))
+	}
+
+	return sb.String()
+}
@@ -1,3 +1,7 @@
 export const RECEIVED_USER_CONFIG = 'RECEIVED_USER_CONFIG';
 export const OPEN_MEETING = 'OPEN_MEETING';
-export const CLOSE_MEETING = 'CLOSE_MEETING';
\ No newline at end of file
+export const CLOSE_MEETING = 'CLOSE_MEETING';
+export const RECEIVED_INCOMING_CALL = 'RECEIVED_INCOMING_CALL';
+export const DISMISS_INCOMING_CALL = 'DISMISS_INCOMING_CALL';
+export const RECEIVED_MEETING_ACCESS = 'RECEIVED_MEETING_ACCESS';
+export const RECEIVED_JOIN_REQUEST_UPDATE = 'RECEIVED_JOIN_REQUEST_UPDATE';
\ No newline at end of file
@@ -1,9 +1,9 @@
 import {Dispatch} from 'redux';
 import {GetStateFunc} from 'mattermost-redux/types/actions';
 
-import Client from '../client';
-import {UserConfig} from '../types';
-import {RECEIVED_USER_CONFIG, OPEN_MEETING, CLOSE_MEETING} from '../action_types';
+import Client, {RecordingConsentDeclinedError} from '../client';
+import {IncomingCall, UserConfig} from '../types';
+import {RECEIVED_USER_CONFIG, OPEN_MEETING, CLOSE_MEETING, RECEIVED_INCOMING_CALL, DISMISS_INCOMING_CALL, RECEIVED_MEETING_ACCESS, RECEIVED_JOIN_REQUEST_UPDATE} from '../action_types';
 
 export function startMeeting(channelId: string, topic = '', rootId = '') {
     return async (dispatch: Dispatch, getState: GetStateFunc) => {
@@ -58,4 +58,97 @@ export function closeMeeting(meetingId: string) {
         type: CLOSE_MEETING,
         data: meetingId,
     };
+}
+
+export function receivedIncomingCall(call: IncomingCall) {
+    return {
+        type: RECEIVED_INCOMING_CALL,
+        data: call,
+    };
+}
+
+export function dismissIncomingCall(roomId: string) {
+    return {
+        type: DISMISS_INCOMING_CALL,
+        data: roomId,
+    };
+}
+
+export function declineCall(roomId: string) {
+    return async (dispatch: Dispatch) => {
+        dispatch(dismissIncomingCall(roomId));
+        try {
+            await Client.declineCall(roomId);
+            return {data: true};
+        } catch (error) {
+            return {error};
+        }
+    };
+}
+
+export function loadMeetingAccess(roomId: string) {
+    return async (dispatch: Dispatch) => {
+        try {
+            const access = await Client.getMeetingAccess(roomId);
+            dispatch({
+                type: RECEIVED_MEETING_ACCESS,
+                data: {room_id: roomId, access},
+            });
+            return {data: access};
+        } catch (error) {
+            return {error};
+        }
+    };
+}
+
+// requestToJoin asks the moderators of a private meeting to let the user in.
+export function requestToJoin(roomId: string) {
+    return async (dispatch: Dispatch) => {
+        try {
+            const joinRequest = await Client.requestToJoin(roomId);
+            dispatch(receivedJoinRequestUpdate(roomId, joinRequest.status));
+            return {data: joinRequest};
+        } catch (error) {
+            return {error};
+        }
+    };
+}
+
+export function receivedJoinRequestUpdate(roomId: string, status: string) {
+    return {
+        type: RECEIVED_JOIN_REQUEST_UPDATE,
+        data: {room_id: roomId, status},
+    };
+}
+
+// joinMeeting opens a meeting embedded or in a new tab, following the
+// user's settings.
+export function joinMeeting(call: IncomingCall) {
+    return async (dispatch: Dispatch, getState: GetStateFunc) => {
+        dispatch(dismissIncomingCall(call.room_id));
+
+        let token = '';
+        try {
+            token = await Client.getToken(call.room_id);
+        } catch (error) {
+            if (error instanceof RecordingConsentDeclinedError) {
+                return {error};
+            }
+            console.error('[DigitalSamba] Failed to get token:', error);
+            window.open(call.meeting_url, '_blank');
+            return {error};
+        }
+
+        if ((getState() as any)['plugins-digitalsamba']?.userConfig?.embedded) {
+            dispatch(openMeeting({
+                meeting_id: call.meeting_id,
+                room_id: call.room_id,
+                room_url: call.meeting_url,
+                token,
+            }));
+        } else {
+            window.open(`${call.meeting_url}?token=${encodeURIComponent(token)}`, '_blank');
+        }
+        return {data: true};
+    };
 }
\ No newline at end of file
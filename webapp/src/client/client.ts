@@ -1,5 +1,14 @@
 import {Client4} from 'mattermost-redux/client';
-import {UserConfig} from '../types';
+import {ConnectionStatus, MeetingAccess, UserConfig} from '../types';
+
+// RecordingConsentDeclinedError is thrown when the user declines to be
+// recorded. Callers must not open the meeting another way.
+export class RecordingConsentDeclinedError extends Error {
+    constructor() {
+        super('Recording consent declined');
+        this.name = 'RecordingConsentDeclinedError';
+    }
+}
 
 class Client {
     private serverRoute = '';
@@ -22,7 +31,7 @@ class Client {
         }));
 
         if (!response.ok) {
-            throw new Error('Failed to start meeting');
+            throw new Error(await getErrorMessage(response, 'Failed to start meeting'));
         }
 
         return response.json();
@@ -59,7 +68,7 @@ class Client {
         }
     };
 
-    getToken = async (roomId: string): Promise<string> => {
+    getToken = async (roomId: string, consentAsked = false): Promise<string> => {
         const url = `${this.serverRoute}/api/v1/token`;
         console.log('[DigitalSamba Client] Getting token for room:', roomId, 'URL:', url);
         
@@ -70,16 +79,127 @@ class Client {
 
         console.log('[DigitalSamba Client] Token response status:', response.status);
         
+        if (response.status === 403 && !consentAsked) {
+            const body = await response.clone().json().catch(() => ({}));
+            if (body.code === 'recording_consent_required') {
+                await this.askRecordingConsent(roomId);
+                return this.getToken(roomId, true);
+            }
+        }
+
         if (!response.ok) {
-            const errorText = await response.text();
-            console.error('[DigitalSamba Client] Token error response:', errorText);
-            throw new Error(`Failed to get token: ${response.status} ${errorText}`);
+            const message = await getErrorMessage(response, 'Failed to get token');
+            console.error('[DigitalSamba Client] Token error response:', message);
+            throw new Error(`Failed to get token: ${response.status} ${message}`);
         }
 
         const data = await response.json();
         console.log('[DigitalSamba Client] Token received');
         return data.token;
     };
+
+    declineCall = async (roomId: string) => {
+        const url = `${this.serverRoute}/api/v1/meetings/${encodeURIComponent(roomId)}/decline`;
+
+        const response = await fetch(url, Client4.getOptions({
+            method: 'POST',
+        }));
+
+        if (!response.ok) {
+            throw new Error(await getErrorMessage(response, 'Failed to decline call'));
+        }
+    };
+
+    getMeetingAccess = async (roomId: string): Promise<MeetingAccess> => {
+        const url = `${this.serverRoute}/api/v1/meetings/${encodeURIComponent(roomId)}/access`;
+
+        const response = await fetch(url, Client4.getOptions({
+            method: 'GET',
+        }));
+
+        if (!response.ok) {
+            throw new Error(await getErrorMessage(response, 'Failed to get meeting access'));
+        }
+
+        return response.json();
+    };
+
+    requestToJoin = async (roomId: string) => {
+        const url = `${this.serverRoute}/api/v1/meetings/${encodeURIComponent(roomId)}/join-requests`;
+
+        const response = await fetch(url, Client4.getOptions({
+            method: 'POST',
+        }));
+
+        if (!response.ok) {
+            throw new Error(await getErrorMessage(response, 'Failed to request to join'));
+        }
+
+        return response.json();
+    };
+
+    // askRecordingConsent shows the meeting's consent message and stores the
+    // user's consent, which the server requires before it issues a token.
+    askRecordingConsent = async (roomId: string) => {
+        const access = await this.getMeetingAccess(roomId);
+        if (!access.consent_required) {
+            return;
+        }
+
+        // eslint-disable-next-line no-alert
+        if (!window.confirm(access.consent_message)) {
+            throw new RecordingConsentDeclinedError();
+        }
+
+        const url = `${this.serverRoute}/api/v1/meetings/${encodeURIComponent(roomId)}/consent`;
+
+        const response = await fetch(url, Client4.getOptions({
+            method: 'POST',
+        }));
+
+        if (!response.ok) {
+            throw new Error(await getErrorMessage(response, 'Failed to store recording consent'));
+        }
+    };
+
+    setRecording = async (roomId: string, start: boolean) => {
+        const url = `${this.serverRoute}/api/v1/meetings/${encodeURIComponent(roomId)}/recording/${start ? 'start' : 'stop'}`;
+
+        const response = await fetch(url, Client4.getOptions({
+            method: 'POST',
+        }));
+
+        if (!response.ok) {
+            throw new Error(await getErrorMessage(response, start ? 'Failed to start recording' : 'Failed to stop recording'));
+        }
+
+        return response.json();
+    };
+
+    getConnectionStatus = async (): Promise<ConnectionStatus[]> => {
+        const url = `${this.serverRoute}/api/v1/admin/connection-status`;
+
+        const response = await fetch(url, Client4.getOptions({
+            method: 'GET',
+        }));
+
+        if (!response.ok) {
+            throw new Error('Failed to get connection status');
+        }
+
+        return response.json();
+    };
+}
+
+// getErrorMessage reads the message of an API error response, which has the
+// shape {code, message}.
+async function getErrorMessage(response: Response, fallback: string): Promise<string> {
+    try {
+        const body = await response.json();
+        return body.message || fallback;
+    } catch (e) {
+        return fallback;
+    }
 }
 
 const client = new Client();
@@ -1 +1,2 @@
-export {default} from './client';
\ No newline at end of file
+export {default} from './client';
+export {RecordingConsentDeclinedError} from './client';
\ No newline at end of file
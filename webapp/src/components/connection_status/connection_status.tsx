@@ -0,0 +1,46 @@
+import React, {useEffect, useState} from 'react';
+
+import Client from '../../client';
+import {ConnectionStatus as Status} from '../../types';
+
+export default function ConnectionStatus() {
+    const [statuses, setStatuses] = useState<Status[] | null>(null);
+    const [error, setError] = useState('');
+
+    useEffect(() => {
+        Client.getConnectionStatus().
+            then(setStatuses).
+            catch(() => setError('Failed to load the connection status.'));
+    }, []);
+
+    if (error) {
+        return <div className='alert alert-danger'>{error}</div>;
+    }
+
+    if (!statuses) {
+        return <div>{'Loading...'}</div>;
+    }
+
+    if (statuses.length === 0) {
+        return <div>{'No connectivity check has run yet. Save the configuration to run one.'}</div>;
+    }
+
+    return (
+        <div>
+            {statuses.map((status) => (
+                <div
+                    key={status.account}
+                    className={status.ok ? 'alert alert-success' : 'alert alert-danger'}
+                >
+                    <strong>{status.account}</strong>
+                    {status.team_name ? ` (${status.team_name})` : ''}
+                    {': '}
+                    {status.ok ? `reachable in ${status.latency_ms} ms` : status.error}
+                    <div>
+                        <small>{`Checked at ${new Date(status.checked_at).toLocaleString()}`}</small>
+                    </div>
+                </div>
+            ))}
+        </div>
+    );
+}
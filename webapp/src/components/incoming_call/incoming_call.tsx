@@ -0,0 +1,59 @@
+import React, {useEffect} from 'react';
+import {useDispatch, useSelector} from 'react-redux';
+import {GlobalState} from 'mattermost-redux/types/store';
+
+import {declineCall, dismissIncomingCall, joinMeeting} from '../../actions';
+import {IncomingCall as Call} from '../../types';
+import './incoming_call.scss';
+
+export default function IncomingCall() {
+    const dispatch = useDispatch();
+    const incomingCalls: Call[] = useSelector((state: GlobalState) =>
+        (state as any)['plugins-digitalsamba']?.incomingCalls || []
+    );
+
+    const call = incomingCalls[0];
+
+    // The server also stops the ringing, this covers a lost websocket event
+    useEffect(() => {
+        if (!call || !call.expires_at) {
+            return undefined;
+        }
+
+        const timeout = setTimeout(() => {
+            dispatch(dismissIncomingCall(call.room_id));
+        }, Math.max(call.expires_at - Date.now(), 0));
+
+        return () => clearTimeout(timeout);
+    }, [call]);
+
+    if (!call) {
+        return null;
+    }
+
+    return (
+        <div
+            className='digitalsamba-incoming-call'
+            role='dialog'
+        >
+            <div className='digitalsamba-incoming-call-title'>
+                {`@${call.caller_username} is calling you`}
+            </div>
+            <div className='digitalsamba-incoming-call-topic'>{call.topic}</div>
+            <div className='digitalsamba-incoming-call-actions'>
+                <button
+                    className='btn btn-danger'
+                    onClick={() => dispatch(declineCall(call.room_id))}
+                >
+                    {'Decline'}
+                </button>
+                <button
+                    className='btn btn-primary'
+                    onClick={() => dispatch(joinMeeting(call))}
+                >
+                    {'Accept'}
+                </button>
+            </div>
+        </div>
+    );
+}
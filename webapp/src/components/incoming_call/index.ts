@@ -0,0 +1 @@
+export {default} from './incoming_call';
\ No newline at end of file
@@ -1,10 +1,12 @@
-import React from 'react';
+import React, {useEffect} from 'react';
 import {Post} from 'mattermost-redux/types/posts';
 import {useDispatch, useSelector} from 'react-redux';
 import {GlobalState} from 'mattermost-redux/types/store';
+import {getCurrentUserId} from 'mattermost-redux/selectors/entities/users';
 
-import {openMeeting} from '../../actions';
-import Client from '../../client';
+import {openMeeting, loadMeetingAccess, requestToJoin} from '../../actions';
+import {MeetingAccess} from '../../types';
+import Client, {RecordingConsentDeclinedError} from '../../client';
 
 interface Props {
     post: Post;
@@ -22,6 +24,20 @@ export default function PostTypeDigitalSamba(props: Props) {
     const meetingUrl = props.post.props?.meeting_url;
     const roomId = props.post.props?.room_id;
     const meetingTopic = props.post.props?.meeting_topic || 'DigitalSamba Meeting';
+    const isPrivate = Boolean(props.post.props?.private);
+    const meetingEnded = Boolean(props.post.props?.meeting_ended);
+    const streamTarget = props.post.props?.stream_target;
+    const recordingAllowed = Boolean(props.post.props?.recording_allowed);
+    const recording = Boolean(props.post.props?.recording);
+    const currentUserId = useSelector(getCurrentUserId);
+    const access: MeetingAccess | undefined = useSelector((state: GlobalState) => (state as any)['plugins-digitalsamba']?.meetingAccess?.[roomId]);
+
+    // Users who are not entitled to a private meeting must ask to join it
+    useEffect(() => {
+        if (isPrivate && roomId && !meetingEnded) {
+            dispatch(loadMeetingAccess(roomId));
+        }
+    }, [isPrivate, roomId, meetingEnded]);
     
     const handleJoinMeeting = async () => {
         console.log('[DigitalSamba] Join meeting clicked', {
@@ -70,6 +86,9 @@ export default function PostTypeDigitalSamba(props: Props) {
                 console.log('[DigitalSamba] Dispatching openMeeting with:', meetingInfo);
                 dispatch(openMeeting(meetingInfo));
             } catch (error) {
+                if (error instanceof RecordingConsentDeclinedError) {
+                    return;
+                }
                 console.error('[DigitalSamba] Failed to get token:', error);
                 // Fallback to external link
                 window.open(meetingUrl, '_blank');
@@ -83,6 +102,9 @@ export default function PostTypeDigitalSamba(props: Props) {
                 console.log('[DigitalSamba] Opening meeting with token URL');
                 window.open(urlWithToken, '_blank');
             } catch (error) {
+                if (error instanceof RecordingConsentDeclinedError) {
+                    return;
+                }
                 console.error('[DigitalSamba] Failed to get token, opening without token:', error);
                 window.open(meetingUrl, '_blank');
             }
@@ -93,18 +115,65 @@ export default function PostTypeDigitalSamba(props: Props) {
         return null;
     }
 
+    const handleRequestToJoin = () => {
+        dispatch(requestToJoin(roomId));
+    };
+
+    // The server also lets channel admins record, they can use the command
+    const handleToggleRecording = async () => {
+        try {
+            await Client.setRecording(roomId, !recording);
+        } catch (error) {
+            console.error('[DigitalSamba] Failed to change recording:', error);
+        }
+    };
+
+    let joinControl = (
+        <button
+            className='btn btn-primary'
+            onClick={handleJoinMeeting}
+        >
+            Join Meeting
+        </button>
+    );
+    if (isPrivate && !meetingEnded && access && !access.can_join) {
+        if (access.join_request_status === 'pending') {
+            joinControl = <p>Waiting for a moderator to let you in.</p>;
+        } else if (access.join_request_status === 'denied') {
+            joinControl = <p>Your request to join was declined.</p>;
+        } else {
+            joinControl = (
+                <button
+                    className='btn btn-primary'
+                    onClick={handleRequestToJoin}
+                >
+                    Request to join
+                </button>
+            );
+        }
+    }
+
     return (
         <div className='digitalsamba-post-type'>
             <div className='digitalsamba-post-header'>
                 <h4>{meetingTopic}</h4>
                 <p>Meeting ID: {meetingId}</p>
+                {streamTarget && !meetingEnded && (
+                    <p className='digitalsamba-post-status'>{`📡 Streaming live to ${streamTarget}`}</p>
+                )}
+                {recording && !meetingEnded && (
+                    <p className='digitalsamba-post-status'>{'🔴 Recording'}</p>
+                )}
             </div>
-            <button
-                className='btn btn-primary'
-                onClick={handleJoinMeeting}
-            >
-                Join Meeting
-            </button>
+            {joinControl}
+            {recordingAllowed && !meetingEnded && props.post.user_id === currentUserId && (
+                <button
+                    className='btn btn-tertiary'
+                    onClick={handleToggleRecording}
+                >
+                    {recording ? 'Stop recording' : 'Record'}
+                </button>
+            )}
         </div>
     );
 }
\ No newline at end of file
@@ -3,6 +3,7 @@ import ReactDOM from 'react-dom';
 import {Provider} from 'react-redux';
 
 import Conference from './conference';
+import IncomingCall from './incoming_call';
 
 export default class RootPortal {
     private registry: any;
@@ -30,7 +31,10 @@ export default class RootPortal {
 
         ReactDOM.render(
             <Provider store={this.store}>
-                <Conference/>
+                <>
+                    <Conference/>
+                    <IncomingCall/>
+                </>
             </Provider>,
             this.portalNode
         );
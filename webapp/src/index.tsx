@@ -9,10 +9,11 @@ import Icon from './components/icon';
 import PostTypeDigitalSamba from './components/post_type_digitalsamba';
 import I18nProvider from './components/i18n_provider';
 import RootPortal from './components/root_portal';
+import ConnectionStatus from './components/connection_status';
 import reducer from './reducers';
-import {startMeeting, loadConfig, openMeeting} from './actions';
+import {startMeeting, loadConfig, openMeeting, receivedIncomingCall, dismissIncomingCall, receivedJoinRequestUpdate} from './actions';
 import manifest from './manifest';
-import Client from './client';
+import Client, {RecordingConsentDeclinedError} from './client';
 
 class PluginClass {
     rootPortal?: RootPortal;
@@ -37,6 +38,9 @@ class PluginClass {
                     };
                     store.dispatch(openMeeting(meetingInfo));
                 } catch (error) {
+                    if (error instanceof RecordingConsentDeclinedError) {
+                        return;
+                    }
                     console.error('[DigitalSamba] Failed to get token:', error);
                     // Fallback to external link
                     if (result.data.room_url) {
@@ -50,6 +54,9 @@ class PluginClass {
                     const urlWithToken = `${result.data.room_url}?token=${encodeURIComponent(token)}`;
                     window.open(urlWithToken, '_blank');
                 } catch (error) {
+                    if (error instanceof RecordingConsentDeclinedError) {
+                        return;
+                    }
                     console.error('[DigitalSamba] Failed to get token:', error);
                     window.open(result.data.room_url, '_blank');
                 }
@@ -75,6 +82,16 @@ class PluginClass {
             console.log('[DigitalSamba] WebSocket config update received');
             store.dispatch(loadConfig());
         });
+        registry.registerWebSocketEventHandler('custom_digitalsamba_incoming_call', (msg: any) => {
+            store.dispatch(receivedIncomingCall(msg.data));
+        });
+        registry.registerWebSocketEventHandler('custom_digitalsamba_call_stopped', (msg: any) => {
+            store.dispatch(dismissIncomingCall(msg.data.room_id));
+        });
+        registry.registerWebSocketEventHandler('custom_digitalsamba_join_request_update', (msg: any) => {
+            store.dispatch(receivedJoinRequestUpdate(msg.data.room_id, msg.data.status));
+        });
+        registry.registerAdminConsoleCustomSetting('DigitalSambaConnectionStatus', ConnectionStatus, {showTitle: true});
         console.log('[DigitalSamba] Plugin initialized, loading config...');
         store.dispatch(loadConfig());
     }
@@ -1,7 +1,7 @@
 import {combineReducers} from 'redux';
 
-import {RECEIVED_USER_CONFIG, OPEN_MEETING, CLOSE_MEETING} from '../action_types';
-import {UserConfig, MeetingInfo} from '../types';
+import {RECEIVED_USER_CONFIG, OPEN_MEETING, CLOSE_MEETING, RECEIVED_INCOMING_CALL, DISMISS_INCOMING_CALL, RECEIVED_MEETING_ACCESS, RECEIVED_JOIN_REQUEST_UPDATE} from '../action_types';
+import {UserConfig, MeetingInfo, IncomingCall, MeetingAccess} from '../types';
 
 function userConfig(state: UserConfig | null = {embedded: true, show_prejoin_page: true, naming_scheme: 'words'}, action: any) {
     switch (action.type) {
@@ -31,7 +31,40 @@ function embeddedMeetings(state: MeetingInfo[] = [], action: any) {
     }
 }
 
+function incomingCalls(state: IncomingCall[] = [], action: any) {
+    switch (action.type) {
+    case RECEIVED_INCOMING_CALL:
+        return [...state.filter((call) => call.room_id !== action.data.room_id), action.data];
+    case DISMISS_INCOMING_CALL:
+        return state.filter((call) => call.room_id !== action.data);
+    default:
+        return state;
+    }
+}
+
+function meetingAccess(state: {[roomId: string]: MeetingAccess} = {}, action: any) {
+    switch (action.type) {
+    case RECEIVED_MEETING_ACCESS:
+        return {...state, [action.data.room_id]: action.data.access};
+    case RECEIVED_JOIN_REQUEST_UPDATE: {
+        const access = state[action.data.room_id] || {can_join: false, private: true};
+        return {
+            ...state,
+            [action.data.room_id]: {
+                ...access,
+                join_request_status: action.data.status,
+                can_join: access.can_join || action.data.status === 'admitted',
+            },
+        };
+    }
+    default:
+        return state;
+    }
+}
+
 export default combineReducers({
     userConfig,
     embeddedMeetings,
+    incomingCalls,
+    meetingAccess,
 });
\ No newline at end of file
@@ -13,9 +13,33 @@ export type MeetingInfo = {
     room_name: string;
 }
 
+export type IncomingCall = {
+    room_id: string;
+    meeting_id: string;
+    meeting_url: string;
+    topic: string;
+    channel_id: string;
+    post_id: string;
+    caller_id: string;
+    caller_username: string;
+    expires_at?: number;
+}
+
+export type MeetingAccess = {
+    can_join: boolean;
+    private: boolean;
+    join_request_status?: 'pending' | 'admitted' | 'denied';
+    consent_required?: boolean;
+    consent_message?: string;
+}
+
 export type DigitalSambaState = {
     userConfig: UserConfig | null;
     embeddedMeetings: MeetingInfo[];
+    incomingCalls: IncomingCall[];
+
+    // meetingAccess holds the access to private meetings by room ID.
+    meetingAccess: {[roomId: string]: MeetingAccess};
 }
 
 export type MeetingConfig = {
@@ -24,4 +48,14 @@ export type MeetingConfig = {
     token: string;
     embedded: boolean;
     showPrejoinPage: boolean;
+}
+
+export type ConnectionStatus = {
+    account: string;
+    team_name: string;
+    ok: boolean;
+    error?: string;
+    latency_ms: number;
+    room_count: number;
+    checked_at: number;
 }
\ No newline at end of file